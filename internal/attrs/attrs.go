@@ -21,6 +21,7 @@ package attrs
 
 import (
 	"fmt"
+	"net/netip"
 
 	"github.com/open-traffic-generator/snappi/gosnappi"
 	"github.com/openconfig/featureprofiles/internal/deviations"
@@ -42,6 +43,30 @@ type Attributes struct {
 	IPv4Len uint8  // Prefix length for IPv4.
 	IPv6Len uint8  // Prefix length for IPv6.
 	MTU     uint16
+
+	// Subinterface is the subinterface index.  It is only used for the
+	// entries in Subinterfaces; the attributes above always configure
+	// subinterface 0.
+	Subinterface uint32
+	// VLANID is the VLAN ID of a subinterface.  Zero means untagged.
+	VLANID uint16
+	// SecondaryIPv4 and SecondaryIPv6 are additional addresses in CIDR
+	// notation, only applied to DUT interfaces.  A malformed one is
+	// configured as is, which the DUT rejects; see Validate.
+	SecondaryIPv4 []string
+	SecondaryIPv6 []string
+	// IPv6LinkLocal is an explicit IPv6 link-local address, only applied to
+	// DUT interfaces.
+	IPv6LinkLocal string
+	// IPv6LinkLocalLen is the prefix length of IPv6LinkLocal.  Zero means 64.
+	IPv6LinkLocalLen uint8
+	// NetworkInstance is the network instance the subinterface is bound to,
+	// only applied to DUT interfaces.  Empty means the default network
+	// instance.
+	NetworkInstance string
+	// Subinterfaces are additional subinterfaces, typically VLAN-tagged.  On
+	// the ATE, each of them is emulated by its own device or interface.
+	Subinterfaces []*Attributes
 }
 
 // IPv4CIDR constructs the IPv4 CIDR notation with the given prefix
@@ -73,8 +98,36 @@ func (a *Attributes) ConfigOCInterface(intf *oc.Interface) *oc.Interface {
 		e.MacAddress = ygot.String(a.MAC)
 	}
 
-	s := intf.GetOrCreateSubinterface(0)
-	if a.IPv4 != "" {
+	a.configOCSubinterface(intf.GetOrCreateSubinterface(0))
+	for _, sub := range a.Subinterfaces {
+		s := sub.ConfigOCSubinterface(intf.GetOrCreateSubinterface(sub.Subinterface))
+		if *deviations.InterfaceEnabled {
+			s.Enabled = ygot.Bool(true)
+		}
+	}
+	return intf
+}
+
+// ConfigOCSubinterface configures an OpenConfig subinterface with the
+// VLAN and addresses of these attributes.
+func (a *Attributes) ConfigOCSubinterface(s *oc.Interface_Subinterface) *oc.Interface_Subinterface {
+	if a.Desc != "" {
+		s.Description = ygot.String(a.Desc)
+	}
+	a.configOCSubinterface(s)
+	return s
+}
+
+func (a *Attributes) configOCSubinterface(s *oc.Interface_Subinterface) {
+	if a.VLANID != 0 {
+		if *deviations.DeprecatedVlanID {
+			s.GetOrCreateVlan().VlanId = oc.UnionUint16(a.VLANID)
+		} else {
+			s.GetOrCreateVlan().GetOrCreateMatch().GetOrCreateSingleTagged().VlanId = ygot.Uint16(a.VLANID)
+		}
+	}
+
+	if a.IPv4 != "" || len(a.SecondaryIPv4) > 0 {
 		s4 := s.GetOrCreateIpv4()
 		if *deviations.InterfaceEnabled && !*deviations.IPv4MissingEnabled {
 			s4.Enabled = ygot.Bool(true)
@@ -82,13 +135,22 @@ func (a *Attributes) ConfigOCInterface(intf *oc.Interface) *oc.Interface {
 		if a.MTU > 0 {
 			s4.Mtu = ygot.Uint16(a.MTU)
 		}
-		a4 := s4.GetOrCreateAddress(a.IPv4)
-		if a.IPv4Len > 0 {
-			a4.PrefixLength = ygot.Uint8(a.IPv4Len)
+		if a.IPv4 != "" {
+			a4 := s4.GetOrCreateAddress(a.IPv4)
+			if a.IPv4Len > 0 {
+				a4.PrefixLength = ygot.Uint8(a.IPv4Len)
+			}
+		}
+		for _, cidr := range a.SecondaryIPv4 {
+			if p, err := netip.ParsePrefix(cidr); err == nil {
+				s4.GetOrCreateAddress(p.Addr().String()).PrefixLength = ygot.Uint8(uint8(p.Bits()))
+			} else {
+				s4.GetOrCreateAddress(cidr)
+			}
 		}
 	}
 
-	if a.IPv6 != "" {
+	if a.IPv6 != "" || len(a.SecondaryIPv6) > 0 || a.IPv6LinkLocal != "" {
 		s6 := s.GetOrCreateIpv6()
 		if a.MTU > 0 {
 			s6.Mtu = ygot.Uint32(uint32(a.MTU))
@@ -96,12 +158,110 @@ func (a *Attributes) ConfigOCInterface(intf *oc.Interface) *oc.Interface {
 		if *deviations.InterfaceEnabled {
 			s6.Enabled = ygot.Bool(true)
 		}
-		a6 := s6.GetOrCreateAddress(a.IPv6)
-		if a.IPv6Len > 0 {
-			a6.PrefixLength = ygot.Uint8(a.IPv6Len)
+		if a.IPv6 != "" {
+			a6 := s6.GetOrCreateAddress(a.IPv6)
+			if a.IPv6Len > 0 {
+				a6.PrefixLength = ygot.Uint8(a.IPv6Len)
+			}
+		}
+		for _, cidr := range a.SecondaryIPv6 {
+			if p, err := netip.ParsePrefix(cidr); err == nil {
+				s6.GetOrCreateAddress(p.Addr().String()).PrefixLength = ygot.Uint8(uint8(p.Bits()))
+			} else {
+				s6.GetOrCreateAddress(cidr)
+			}
+		}
+		if a.IPv6LinkLocal != "" {
+			plen := a.IPv6LinkLocalLen
+			if plen == 0 {
+				plen = 64
+			}
+			s6.GetOrCreateAddress(a.IPv6LinkLocal).PrefixLength = ygot.Uint8(plen)
 		}
 	}
-	return intf
+}
+
+// Validate returns an error naming the first malformed secondary or
+// link-local address of these attributes or of their Subinterfaces, which
+// ConfigOCInterface would otherwise configure as is.
+func (a *Attributes) Validate() error {
+	if err := a.validate(); err != nil {
+		return err
+	}
+	for _, sub := range a.Subinterfaces {
+		if err := sub.validate(); err != nil {
+			return fmt.Errorf("subinterface %d: %w", sub.Subinterface, err)
+		}
+	}
+	return nil
+}
+
+func (a *Attributes) validate() error {
+	for i, cidr := range a.SecondaryIPv4 {
+		if p, err := netip.ParsePrefix(cidr); err != nil || !p.Addr().Is4() {
+			return fmt.Errorf("SecondaryIPv4[%d] %q is not an IPv4 address in CIDR notation", i, cidr)
+		}
+	}
+	for i, cidr := range a.SecondaryIPv6 {
+		if p, err := netip.ParsePrefix(cidr); err != nil || !p.Addr().Is6() {
+			return fmt.Errorf("SecondaryIPv6[%d] %q is not an IPv6 address in CIDR notation", i, cidr)
+		}
+	}
+	if a.IPv6LinkLocal != "" {
+		if ip, err := netip.ParseAddr(a.IPv6LinkLocal); err != nil || !ip.Is6() || !ip.IsLinkLocalUnicast() {
+			return fmt.Errorf("IPv6LinkLocal %q is not an IPv6 link-local address", a.IPv6LinkLocal)
+		}
+	}
+	if a.IPv6LinkLocalLen > 128 {
+		return fmt.Errorf("IPv6LinkLocalLen %d is more than 128", a.IPv6LinkLocalLen)
+	}
+	return nil
+}
+
+// ConfigOCNetworkInstances binds subinterface 0 and all Subinterfaces of the
+// named interface to their network instances in root.  Subinterfaces without
+// a network instance are only bound to the default network instance if
+// deviations.ExplicitInterfaceInDefaultVRF is set.
+func (a *Attributes) ConfigOCNetworkInstances(root *oc.Root, name string) {
+	a.configOCNetworkInstance(root, name, 0)
+	for _, sub := range a.Subinterfaces {
+		sub.configOCNetworkInstance(root, name, sub.Subinterface)
+	}
+}
+
+func (a *Attributes) configOCNetworkInstance(root *oc.Root, name string, index uint32) {
+	ni := a.NetworkInstance
+	if ni == "" {
+		if !*deviations.ExplicitInterfaceInDefaultVRF {
+			return
+		}
+		ni = *deviations.DefaultNetworkInstance
+	}
+	id := fmt.Sprintf("%s.%d", name, index)
+	niIntf := root.GetOrCreateNetworkInstance(ni).GetOrCreateInterface(id)
+	niIntf.Interface = ygot.String(name)
+	niIntf.Subinterface = ygot.Uint32(index)
+}
+
+// subinterface returns the attributes of the given subinterface index among
+// Subinterfaces, or an empty Attributes if there is none.
+func (a *Attributes) subinterface(index uint32) *Attributes {
+	if a != nil {
+		for _, sub := range a.Subinterfaces {
+			if sub.Subinterface == index {
+				return sub
+			}
+		}
+	}
+	return &Attributes{}
+}
+
+// subinterfaceName returns the ATE name of a subinterface of a.
+func (a *Attributes) subinterfaceName(sub *Attributes) string {
+	if sub.Name != "" {
+		return sub.Name
+	}
+	return fmt.Sprintf("%s.%d", a.Name, sub.Subinterface)
 }
 
 // NewOCInterface returns a new *oc.Interface configured with these attributes.
@@ -125,19 +285,64 @@ func (a *Attributes) AddToATE(top *ondatra.ATETopology, ap *ondatra.Port, peer *
 			WithAddress(a.IPv6CIDR()).
 			WithDefaultGateway(peer.IPv6)
 	}
+	if a.VLANID != 0 {
+		i.Ethernet().WithVLANID(a.VLANID)
+	}
+	for _, sub := range a.Subinterfaces {
+		sub.addSubinterfaceToATE(top, ap, a.subinterfaceName(sub), peer.subinterface(sub.Subinterface))
+	}
 	return i
 }
 
-// AddToOTG adds basic elements to a gosnappi configuration
+// addSubinterfaceToATE adds a subinterface as a separate ATE interface on the
+// same port.
+func (a *Attributes) addSubinterfaceToATE(top *ondatra.ATETopology, ap *ondatra.Port, name string, peer *Attributes) {
+	i := top.AddInterface(name).WithPort(ap)
+	if a.MTU > 0 {
+		i.Ethernet().WithMTU(a.MTU)
+	}
+	if a.VLANID != 0 {
+		i.Ethernet().WithVLANID(a.VLANID)
+	}
+	if a.IPv4 != "" {
+		i.IPv4().
+			WithAddress(a.IPv4CIDR()).
+			WithDefaultGateway(peer.IPv4)
+	}
+	if a.IPv6 != "" {
+		i.IPv6().
+			WithAddress(a.IPv6CIDR()).
+			WithDefaultGateway(peer.IPv6)
+	}
+}
+
+// AddToOTG adds basic elements to a gosnappi configuration.  Each entry in
+// Subinterfaces becomes a separate device on the same port, with its own
+// VLAN and addresses, using the gateways of the matching subinterface of peer.
 func (a *Attributes) AddToOTG(top gosnappi.Config, ap *ondatra.Port, peer *Attributes) {
 	top.Ports().Add().SetName(ap.ID())
-	dev := top.Devices().Add().SetName(a.Name)
-	eth := dev.Ethernets().Add().SetName(a.Name + ".Eth")
-	eth.SetPortName(ap.ID()).SetMac(a.MAC)
+	a.addDeviceToOTG(top, ap, a.Name, a.MAC, peer)
+	for _, sub := range a.Subinterfaces {
+		mac := sub.MAC
+		if mac == "" {
+			mac = a.MAC
+		}
+		sub.addDeviceToOTG(top, ap, a.subinterfaceName(sub), mac, peer.subinterface(sub.Subinterface))
+	}
+}
+
+// addDeviceToOTG adds an emulated device with these attributes on the port.
+func (a *Attributes) addDeviceToOTG(top gosnappi.Config, ap *ondatra.Port, name, mac string, peer *Attributes) {
+	dev := top.Devices().Add().SetName(name)
+	eth := dev.Ethernets().Add().SetName(name + ".Eth")
+	eth.SetPortName(ap.ID()).SetMac(mac)
 
 	if a.MTU > 0 {
 		eth.SetMtu(int32(a.MTU))
 	}
+	if a.VLANID != 0 {
+		eth.Vlans().Add().SetName(name + ".VLAN").SetId(int32(a.VLANID))
+	}
 	if a.IPv4 != "" {
 		ip := eth.Ipv4Addresses().Add().SetName(dev.Name() + ".IPv4")
 		ip.SetAddress(a.IPv4).SetGateway(peer.IPv4).SetPrefix(int32(a.IPv4Len))
@@ -0,0 +1,160 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attrs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/ondatra/gnmi/oc"
+	"github.com/openconfig/ygot/ygot"
+)
+
+var dutPort = &Attributes{
+	Desc:    "dutPort",
+	IPv4:    "192.0.2.1",
+	IPv4Len: 30,
+	Subinterfaces: []*Attributes{{
+		Desc:            "dutPortVlan10",
+		Subinterface:    1,
+		VLANID:          10,
+		IPv4:            "192.0.2.5",
+		IPv4Len:         30,
+		SecondaryIPv4:   []string{"198.51.100.1/24"},
+		IPv6:            "2001:db8::5",
+		IPv6Len:         126,
+		IPv6LinkLocal:   "fe80::1",
+		NetworkInstance: "vrf1",
+	}},
+}
+
+func TestConfigOCInterfaceSubinterfaces(t *testing.T) {
+	for _, deprecated := range []bool{false, true} {
+		*deviations.DeprecatedVlanID = deprecated
+		intf := dutPort.NewOCInterface("eth1")
+
+		s0 := intf.GetSubinterface(0)
+		if s0.GetVlan() != nil {
+			t.Errorf("Subinterface 0 got VLAN %v, want none", s0.GetVlan())
+		}
+		if got, want := s0.GetIpv4().GetAddress("192.0.2.1").GetPrefixLength(), uint8(30); got != want {
+			t.Errorf("Subinterface 0 IPv4 prefix length got %d, want %d", got, want)
+		}
+
+		s1 := intf.GetSubinterface(1)
+		if s1 == nil {
+			t.Fatalf("Subinterface 1 is missing")
+		}
+		if got, want := s1.GetDescription(), "dutPortVlan10"; got != want {
+			t.Errorf("Subinterface 1 description got %q, want %q", got, want)
+		}
+		if deprecated {
+			if got, want := s1.GetVlan().VlanId, oc.UnionUint16(dutPort.Subinterfaces[0].VLANID); got != want {
+				t.Errorf("Subinterface 1 deprecated vlan-id got %v, want %v", got, want)
+			}
+		} else {
+			if got, want := s1.GetVlan().GetMatch().GetSingleTagged().GetVlanId(), uint16(10); got != want {
+				t.Errorf("Subinterface 1 single-tagged vlan-id got %d, want %d", got, want)
+			}
+		}
+		want := map[string]uint8{
+			"192.0.2.5":    30,
+			"198.51.100.1": 24,
+			"2001:db8::5":  126,
+			"fe80::1":      64,
+		}
+		got := make(map[string]uint8)
+		for ip, a := range s1.GetIpv4().Address {
+			got[ip] = a.GetPrefixLength()
+		}
+		for ip, a := range s1.GetIpv6().Address {
+			got[ip] = a.GetPrefixLength()
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("Subinterface 1 addresses -want, +got:\n%s", diff)
+		}
+	}
+	*deviations.DeprecatedVlanID = false
+}
+
+func TestConfigOCInterfaceIPv6LinkLocalLen(t *testing.T) {
+	a := &Attributes{IPv6LinkLocal: "fe80::1", IPv6LinkLocalLen: 10}
+	s0 := a.NewOCInterface("eth1").GetSubinterface(0)
+	if got, want := s0.GetIpv6().GetAddress("fe80::1").GetPrefixLength(), uint8(10); got != want {
+		t.Errorf("Link-local prefix length got %d, want %d", got, want)
+	}
+}
+
+func TestConfigOCInterfaceMalformedCIDR(t *testing.T) {
+	for _, cidr := range []string{"198.51.100.1", "198.51.100.1/33", "not-an-ip/24"} {
+		a := &Attributes{SecondaryIPv4: []string{cidr}}
+		s0 := a.NewOCInterface("eth1").GetSubinterface(0)
+		if addr := s0.GetIpv4().GetAddress(cidr); addr == nil || addr.PrefixLength != nil {
+			t.Errorf("NewOCInterface with secondary address %q got address %v, want it as is without prefix length", cidr, addr)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := dutPort.Validate(); err != nil {
+		t.Errorf("Validate got error: %v", err)
+	}
+	tests := []struct {
+		a    *Attributes
+		want string
+	}{
+		{&Attributes{SecondaryIPv4: []string{"198.51.100.1/24", "198.51.100.1"}}, `SecondaryIPv4[1] "198.51.100.1"`},
+		{&Attributes{SecondaryIPv4: []string{"2001:db8::1/64"}}, `SecondaryIPv4[0] "2001:db8::1/64"`},
+		{&Attributes{SecondaryIPv6: []string{"2001:db8::1/129"}}, `SecondaryIPv6[0] "2001:db8::1/129"`},
+		{&Attributes{IPv6LinkLocal: "2001:db8::1"}, `IPv6LinkLocal "2001:db8::1"`},
+		{&Attributes{IPv6LinkLocal: "fe80::1", IPv6LinkLocalLen: 129}, "IPv6LinkLocalLen 129"},
+		{&Attributes{Subinterfaces: []*Attributes{{Subinterface: 2, SecondaryIPv6: []string{"bad"}}}}, `subinterface 2: SecondaryIPv6[0] "bad"`},
+	}
+	for _, tc := range tests {
+		err := tc.a.Validate()
+		if err == nil || !strings.HasPrefix(err.Error(), tc.want) {
+			t.Errorf("Validate got error %v, want prefix %q", err, tc.want)
+		}
+	}
+}
+
+func TestConfigOCNetworkInstances(t *testing.T) {
+	for _, explicit := range []bool{false, true} {
+		*deviations.ExplicitInterfaceInDefaultVRF = explicit
+		root := &oc.Root{}
+		dutPort.ConfigOCNetworkInstances(root, "eth1")
+
+		want := map[string]*oc.NetworkInstance_Interface{
+			"vrf1": {Id: ygot.String("eth1.1"), Interface: ygot.String("eth1"), Subinterface: ygot.Uint32(1)},
+		}
+		if explicit {
+			want[*deviations.DefaultNetworkInstance] = &oc.NetworkInstance_Interface{
+				Id: ygot.String("eth1.0"), Interface: ygot.String("eth1"), Subinterface: ygot.Uint32(0),
+			}
+		}
+		got := make(map[string]*oc.NetworkInstance_Interface)
+		for name, ni := range root.NetworkInstance {
+			for _, intf := range ni.Interface {
+				got[name] = intf
+			}
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("ExplicitInterfaceInDefaultVRF=%v network instances -want, +got:\n%s", explicit, diff)
+		}
+	}
+	*deviations.ExplicitInterfaceInDefaultVRF = false
+}
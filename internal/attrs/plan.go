@@ -0,0 +1,352 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attrs
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Pools are the prefix pools that a Plan allocates addresses from.  Each pool
+// is a list of prefixes in CIDR notation which are used up in order.  An empty
+// pool disables allocation of that address family.
+type Pools struct {
+	IPv4         []string // Point-to-point links, split into /30 subnets.
+	IPv6         []string // Point-to-point links, split into /126 subnets.
+	IPv4Loopback []string // Loopbacks, split into /32 addresses.
+	IPv6Loopback []string // Loopbacks, split into /128 addresses.
+}
+
+// DefaultPools follows "IP Addresses Assignment" in CONTRIBUTING.md: control
+// plane links are allocated from TEST-NET-1 and 2001:db8:0::/64, and loopbacks
+// from the end of the BMWG range and 2001:db8:3::/64 so they do not collide
+// with the data plane networks.
+var DefaultPools = Pools{
+	IPv4:         []string{"192.0.2.0/24"},
+	IPv6:         []string{"2001:db8::/64"},
+	IPv4Loopback: []string{"198.19.255.0/24"},
+	IPv6Loopback: []string{"2001:db8:3::/64"},
+}
+
+const (
+	planIPv4Len = 30
+	planIPv6Len = 126
+)
+
+// Link is a point-to-point link between a DUT port and an ATE port, as
+// allocated by a Plan.  The DUT and ATE attributes of a link on a non-zero
+// subinterface can be appended to the Subinterfaces of the port attributes.
+type Link struct {
+	Port         string // Port ID in the testbed, e.g. "port1".
+	Subinterface uint32 // Subinterface index on the port.
+	VRF          string // Network instance of the link, or empty for default.
+	DUT          *Attributes
+	ATE          *Attributes
+}
+
+// LinkOptions are optional parameters to Plan.Link.
+type LinkOptions struct {
+	// Subinterface is the subinterface index on the port.  It is also used
+	// as a suffix of the generated names when non-zero.
+	Subinterface uint32
+	// VLANID is the VLAN ID of the subinterface.
+	VLANID uint16
+	// VRF is the network instance that the link will be placed in.
+	VRF string
+}
+
+// linkKey identifies a link within a Plan.
+type linkKey struct {
+	port string
+	sub  uint32
+}
+
+// Plan is an addressing plan for a test topology.  It hands out
+// point-to-point /30 and /126 subnets for DUT and ATE port pairs, loopback
+// addresses, deterministic ATE MAC addresses and interface names, and it
+// guarantees that no two allocations overlap, even when they are on different
+// subinterfaces or VRFs.
+//
+// Usage:
+//
+//	plan, err := attrs.NewPlan(attrs.DefaultPools)
+//	if err != nil {
+//	  t.Fatal(err)
+//	}
+//	links, err := plan.Links("port1", "port2")
+//	if err != nil {
+//	  t.Fatal(err)
+//	}
+//	dutPort1, atePort1 := links[0].DUT, links[0].ATE
+//
+// The allocations depend only on the pools and the order of calls, so the same
+// test always gets the same addresses.
+type Plan struct {
+	v4, v6, lo4, lo6 *pool
+
+	used      []netip.Prefix
+	links     map[linkKey]*Link
+	loopbacks map[string]*Attributes
+	macs      uint32
+}
+
+// NewPlan creates an addressing plan allocating from the given pools.
+func NewPlan(pools Pools) (*Plan, error) {
+	p := &Plan{
+		links:     make(map[linkKey]*Link),
+		loopbacks: make(map[string]*Attributes),
+	}
+	var err error
+	if p.v4, err = newPool(pools.IPv4, planIPv4Len, true); err != nil {
+		return nil, fmt.Errorf("IPv4 pool: %w", err)
+	}
+	if p.v6, err = newPool(pools.IPv6, planIPv6Len, false); err != nil {
+		return nil, fmt.Errorf("IPv6 pool: %w", err)
+	}
+	if p.lo4, err = newPool(pools.IPv4Loopback, 32, true); err != nil {
+		return nil, fmt.Errorf("IPv4 loopback pool: %w", err)
+	}
+	if p.lo6, err = newPool(pools.IPv6Loopback, 128, false); err != nil {
+		return nil, fmt.Errorf("IPv6 loopback pool: %w", err)
+	}
+	return p, nil
+}
+
+// Reserve marks prefixes in CIDR notation as used, so the plan will not
+// allocate any address inside them.  It is useful for tests that still
+// hand-pick some of their addresses.  It returns an error if a prefix overlaps
+// with anything already allocated or reserved.
+func (p *Plan) Reserve(prefixes ...string) error {
+	for _, s := range prefixes {
+		pfx, err := netip.ParsePrefix(s)
+		if err != nil {
+			return err
+		}
+		if used, ok := p.overlaps(pfx); ok {
+			return fmt.Errorf("prefix %s overlaps with %s", pfx, used)
+		}
+		p.used = append(p.used, pfx.Masked())
+	}
+	return nil
+}
+
+// Link allocates a point-to-point link on the given port.  The DUT gets the
+// first usable address of each subnet and the ATE the second, and the ATE is
+// assigned a locally administered MAC address.  Allocating the same port and
+// subinterface twice is an error.
+func (p *Plan) Link(port string, opts ...*LinkOptions) (*Link, error) {
+	if port == "" {
+		return nil, fmt.Errorf("missing port ID")
+	}
+	l := &Link{Port: port}
+	var vlanID uint16
+	for _, opt := range opts {
+		if opt != nil {
+			l.Subinterface = opt.Subinterface
+			l.VRF = opt.VRF
+			vlanID = opt.VLANID
+		}
+	}
+	key := linkKey{port: port, sub: l.Subinterface}
+	if _, ok := p.links[key]; ok {
+		return nil, fmt.Errorf("port %s subinterface %d is already allocated", port, l.Subinterface)
+	}
+
+	name := strings.ToUpper(port[:1]) + port[1:]
+	if l.Subinterface != 0 {
+		name = fmt.Sprintf("%s.%d", name, l.Subinterface)
+	}
+	l.DUT = &Attributes{
+		Desc:            "dut" + name,
+		Subinterface:    l.Subinterface,
+		VLANID:          vlanID,
+		NetworkInstance: l.VRF,
+	}
+	l.ATE = &Attributes{
+		Name:         "ate" + name,
+		Subinterface: l.Subinterface,
+		VLANID:       vlanID,
+	}
+
+	if p.v4 != nil {
+		pfx, err := p.alloc(p.v4)
+		if err != nil {
+			return nil, fmt.Errorf("port %s: %w", port, err)
+		}
+		l.DUT.IPv4, l.DUT.IPv4Len = nthAddr(pfx, 1).String(), planIPv4Len
+		l.ATE.IPv4, l.ATE.IPv4Len = nthAddr(pfx, 2).String(), planIPv4Len
+	}
+	if p.v6 != nil {
+		pfx, err := p.alloc(p.v6)
+		if err != nil {
+			return nil, fmt.Errorf("port %s: %w", port, err)
+		}
+		l.DUT.IPv6, l.DUT.IPv6Len = nthAddr(pfx, 1).String(), planIPv6Len
+		l.ATE.IPv6, l.ATE.IPv6Len = nthAddr(pfx, 2).String(), planIPv6Len
+	}
+
+	p.macs++
+	l.ATE.MAC = planMAC(p.macs)
+	p.links[key] = l
+	return l, nil
+}
+
+// Links allocates a link on subinterface 0 of each port in the default VRF.
+func (p *Plan) Links(ports ...string) ([]*Link, error) {
+	var links []*Link
+	for _, port := range ports {
+		l, err := p.Link(port)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, nil
+}
+
+// Loopback allocates a /32 and /128 loopback address for the given interface
+// name, which is also used as the description.
+func (p *Plan) Loopback(name string) (*Attributes, error) {
+	if _, ok := p.loopbacks[name]; ok {
+		return nil, fmt.Errorf("loopback %s is already allocated", name)
+	}
+	a := &Attributes{Desc: name}
+	if p.lo4 != nil {
+		pfx, err := p.alloc(p.lo4)
+		if err != nil {
+			return nil, fmt.Errorf("loopback %s: %w", name, err)
+		}
+		a.IPv4, a.IPv4Len = pfx.Addr().String(), 32
+	}
+	if p.lo6 != nil {
+		pfx, err := p.alloc(p.lo6)
+		if err != nil {
+			return nil, fmt.Errorf("loopback %s: %w", name, err)
+		}
+		a.IPv6, a.IPv6Len = pfx.Addr().String(), 128
+	}
+	p.loopbacks[name] = a
+	return a, nil
+}
+
+// overlaps returns the first used prefix that overlaps with pfx.
+func (p *Plan) overlaps(pfx netip.Prefix) (netip.Prefix, bool) {
+	for _, used := range p.used {
+		if used.Overlaps(pfx) {
+			return used, true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// alloc takes the next prefix from the pool that does not overlap with
+// anything used so far, and marks it as used.
+func (p *Plan) alloc(pl *pool) (netip.Prefix, error) {
+	for {
+		pfx, ok := pl.next()
+		if !ok {
+			return netip.Prefix{}, fmt.Errorf("address pool %v is exhausted", pl.prefixes)
+		}
+		if _, ok := p.overlaps(pfx); ok {
+			continue
+		}
+		p.used = append(p.used, pfx)
+		return pfx, nil
+	}
+}
+
+// pool hands out consecutive prefixes of the same length from a list of
+// larger prefixes.
+type pool struct {
+	prefixes []netip.Prefix
+	bits     int
+	cur      int        // index into prefixes.
+	addr     netip.Addr // next candidate within prefixes[cur].
+}
+
+// start returns the first candidate in the i-th prefix.  Host addresses
+// skip the all-zeros address of the prefix.
+func (pl *pool) start(i int) netip.Addr {
+	a := pl.prefixes[i].Addr()
+	if pl.bits == a.BitLen() {
+		return addAddr(a, 1)
+	}
+	return a
+}
+
+// newPool parses the prefixes of a pool.  It returns nil if there are none.
+func newPool(prefixes []string, bits int, is4 bool) (*pool, error) {
+	if len(prefixes) == 0 {
+		return nil, nil
+	}
+	pl := &pool{bits: bits}
+	for _, s := range prefixes {
+		pfx, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		if pfx.Addr().Is4() != is4 {
+			return nil, fmt.Errorf("prefix %s is in the wrong address family", s)
+		}
+		if pfx.Bits() > bits {
+			return nil, fmt.Errorf("prefix %s is smaller than /%d", s, bits)
+		}
+		pl.prefixes = append(pl.prefixes, pfx.Masked())
+	}
+	pl.addr = pl.start(0)
+	return pl, nil
+}
+
+// next returns the next prefix in the pool, or false if the pool is used up.
+func (pl *pool) next() (netip.Prefix, bool) {
+	for pl.cur < len(pl.prefixes) {
+		if pl.addr.IsValid() && pl.prefixes[pl.cur].Contains(pl.addr) {
+			pfx := netip.PrefixFrom(pl.addr, pl.bits)
+			pl.addr = addAddr(pl.addr, 1<<(pl.addr.BitLen()-pl.bits))
+			return pfx, true
+		}
+		pl.cur++
+		if pl.cur < len(pl.prefixes) {
+			pl.addr = pl.start(pl.cur)
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// nthAddr returns the n-th address in the prefix.
+func nthAddr(pfx netip.Prefix, n uint64) netip.Addr {
+	return addAddr(pfx.Addr(), n)
+}
+
+// addAddr adds n to the address.  It returns the zero netip.Addr on overflow.
+func addAddr(a netip.Addr, n uint64) netip.Addr {
+	b := a.AsSlice()
+	for i := len(b) - 1; i >= 0 && n > 0; i-- {
+		sum := uint64(b[i]) + n&0xff
+		b[i] = byte(sum)
+		n = n>>8 + sum>>8
+	}
+	if n > 0 {
+		return netip.Addr{}
+	}
+	ret, _ := netip.AddrFromSlice(b)
+	return ret
+}
+
+// planMAC returns the n-th locally administered unicast MAC address.
+func planMAC(n uint32) string {
+	return fmt.Sprintf("02:00:%02x:%02x:%02x:%02x", byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
@@ -0,0 +1,177 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attrs
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPlanLinks(t *testing.T) {
+	p, err := NewPlan(DefaultPools)
+	if err != nil {
+		t.Fatalf("NewPlan got error: %v", err)
+	}
+	links, err := p.Links("port1", "port2")
+	if err != nil {
+		t.Fatalf("Links got error: %v", err)
+	}
+	want := []*Link{{
+		Port: "port1",
+		DUT: &Attributes{
+			Desc:    "dutPort1",
+			IPv4:    "192.0.2.1",
+			IPv6:    "2001:db8::1",
+			IPv4Len: 30,
+			IPv6Len: 126,
+		},
+		ATE: &Attributes{
+			Name:    "atePort1",
+			MAC:     "02:00:00:00:00:01",
+			IPv4:    "192.0.2.2",
+			IPv6:    "2001:db8::2",
+			IPv4Len: 30,
+			IPv6Len: 126,
+		},
+	}, {
+		Port: "port2",
+		DUT: &Attributes{
+			Desc:    "dutPort2",
+			IPv4:    "192.0.2.5",
+			IPv6:    "2001:db8::5",
+			IPv4Len: 30,
+			IPv6Len: 126,
+		},
+		ATE: &Attributes{
+			Name:    "atePort2",
+			MAC:     "02:00:00:00:00:02",
+			IPv4:    "192.0.2.6",
+			IPv6:    "2001:db8::6",
+			IPv4Len: 30,
+			IPv6Len: 126,
+		},
+	}}
+	if diff := cmp.Diff(want, links); diff != "" {
+		t.Errorf("Links -want, +got:\n%s", diff)
+	}
+}
+
+func TestPlanSubinterfaceAndVRF(t *testing.T) {
+	p, err := NewPlan(Pools{IPv4: []string{"198.51.100.0/24"}})
+	if err != nil {
+		t.Fatalf("NewPlan got error: %v", err)
+	}
+	if _, err := p.Link("port2"); err != nil {
+		t.Fatalf("Link got error: %v", err)
+	}
+	l, err := p.Link("port2", &LinkOptions{Subinterface: 5, VLANID: 50, VRF: "vrf1"})
+	if err != nil {
+		t.Fatalf("Link got error: %v", err)
+	}
+	want := &Link{
+		Port:         "port2",
+		Subinterface: 5,
+		VRF:          "vrf1",
+		DUT: &Attributes{
+			Desc:            "dutPort2.5",
+			IPv4:            "198.51.100.5",
+			IPv4Len:         30,
+			Subinterface:    5,
+			VLANID:          50,
+			NetworkInstance: "vrf1",
+		},
+		ATE: &Attributes{
+			Name:         "atePort2.5",
+			MAC:          "02:00:00:00:00:02",
+			IPv4:         "198.51.100.6",
+			IPv4Len:      30,
+			Subinterface: 5,
+			VLANID:       50,
+		},
+	}
+	if diff := cmp.Diff(want, l); diff != "" {
+		t.Errorf("Link -want, +got:\n%s", diff)
+	}
+	if _, err := p.Link("port2", &LinkOptions{Subinterface: 5, VRF: "vrf2"}); err == nil {
+		t.Errorf("Link got no error allocating the same subinterface twice")
+	}
+}
+
+func TestPlanReserve(t *testing.T) {
+	p, err := NewPlan(Pools{IPv4: []string{"192.0.2.0/29", "198.51.100.0/30"}})
+	if err != nil {
+		t.Fatalf("NewPlan got error: %v", err)
+	}
+	if err := p.Reserve("192.0.2.0/30"); err != nil {
+		t.Fatalf("Reserve got error: %v", err)
+	}
+	if err := p.Reserve("192.0.2.0/24"); err == nil {
+		t.Errorf("Reserve got no error on overlapping prefix")
+	}
+
+	var got []string
+	for _, port := range []string{"port1", "port2"} {
+		l, err := p.Link(port)
+		if err != nil {
+			t.Fatalf("Link(%q) got error: %v", port, err)
+		}
+		got = append(got, l.DUT.IPv4)
+	}
+	want := []string{"192.0.2.5", "198.51.100.1"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("DUT addresses -want, +got:\n%s", diff)
+	}
+	if _, err := p.Link("port3"); err == nil {
+		t.Errorf("Link got no error from an exhausted pool")
+	}
+}
+
+func TestPlanLoopback(t *testing.T) {
+	p, err := NewPlan(DefaultPools)
+	if err != nil {
+		t.Fatalf("NewPlan got error: %v", err)
+	}
+	var got []*Attributes
+	for _, name := range []string{"Loopback0", "Loopback1"} {
+		a, err := p.Loopback(name)
+		if err != nil {
+			t.Fatalf("Loopback(%q) got error: %v", name, err)
+		}
+		got = append(got, a)
+	}
+	want := []*Attributes{
+		{Desc: "Loopback0", IPv4: "198.19.255.1", IPv4Len: 32, IPv6: "2001:db8:3::1", IPv6Len: 128},
+		{Desc: "Loopback1", IPv4: "198.19.255.2", IPv4Len: 32, IPv6: "2001:db8:3::2", IPv6Len: 128},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Loopback -want, +got:\n%s", diff)
+	}
+	if _, err := p.Loopback("Loopback0"); err == nil {
+		t.Errorf("Loopback got no error allocating the same name twice")
+	}
+}
+
+func TestNewPlanErrors(t *testing.T) {
+	for _, pools := range []Pools{
+		{IPv4: []string{"not a prefix"}},
+		{IPv4: []string{"2001:db8::/64"}},
+		{IPv6: []string{"2001:db8::/127"}},
+	} {
+		if _, err := NewPlan(pools); err == nil {
+			t.Errorf("NewPlan(%v) got no error", pools)
+		}
+	}
+}
@@ -0,0 +1,501 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package check
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/ygnmi/ygnmi"
+	"github.com/openconfig/ygot/util"
+	"github.com/openconfig/ygot/ygot"
+	"google.golang.org/protobuf/proto"
+)
+
+// Batch validates many Validators over as few gNMI subscriptions as possible.
+// Validators of the package's own singleton and wildcard queries under the
+// root query are grouped by common path prefix and watched with a single
+// Subscribe stream; each update is dispatched to the validation function of
+// every validator that has not yet passed.  Any other validator, such as a
+// counter or stability validator, is awaited on its own.
+type Batch[R ygot.ValidatedGoStruct] struct {
+	root ygnmi.SingletonQuery[R]
+	vds  []Validator
+}
+
+// NewBatch returns a Batch of vds under the root query, typically the device
+// root, e.g. gnmi.OC().State().  Validators of state queries can only be
+// batched under a state root, and config queries under a config root.
+func NewBatch[R ygot.ValidatedGoStruct](root ygnmi.SingletonQuery[R], vds ...Validator) *Batch[R] {
+	return &Batch[R]{root: root, vds: vds}
+}
+
+// Add adds validators to the batch.
+func (b *Batch[R]) Add(vds ...Validator) *Batch[R] {
+	b.vds = append(b.vds, vds...)
+	return b
+}
+
+// batchable is implemented by validators whose values can be extracted from
+// a GoStruct fetched by a Batch.
+type batchable interface {
+	Validator
+	// batchQuery returns the path of the query and whether it is state.
+	batchQuery() (ygnmi.PathStruct, bool)
+	// validateNodes runs the validation function on the values of the query
+	// found in a GoStruct received at ts.
+	validateNodes(nodes []*node, ts time.Time) error
+	// failure returns the error for a validation error and failure cause,
+	// labelled with the path of the query.
+	failure(validationErr, failureCause error) error
+}
+
+// node is a value found in a GoStruct, and its path.
+type node struct {
+	path *gpb.Path
+	val  reflect.Value
+}
+
+func (vd *validation[T]) batchQuery() (ygnmi.PathStruct, bool) {
+	return vd.query.PathStruct(), vd.query.IsState()
+}
+
+func (vd *validation[T]) validateNodes(nodes []*node, ts time.Time) error {
+	v := &ygnmi.Value[T]{Timestamp: ts}
+	if path, _, err := ygnmi.ResolvePath(vd.query.PathStruct()); err == nil {
+		v.Path = path
+	}
+	if len(nodes) > 0 {
+		if val, ok := nodeValue[T](nodes[0]); ok {
+			v.SetVal(val)
+		}
+	}
+	return vd.validationFn(v)
+}
+
+func (vd *validation[T]) failure(validationErr, failureCause error) error {
+	return &validationError[T]{query: vd.query, validationErr: validationErr, failureCause: failureCause}
+}
+
+func (vd *wildcardValidation[T]) batchQuery() (ygnmi.PathStruct, bool) {
+	return vd.query.PathStruct(), vd.query.IsState()
+}
+
+func (vd *wildcardValidation[T]) validateNodes(nodes []*node, ts time.Time) error {
+	var vals []*ygnmi.Value[T]
+	for _, n := range nodes {
+		if val, ok := nodeValue[T](n); ok {
+			vals = append(vals, (&ygnmi.Value[T]{Path: n.path, Timestamp: ts}).SetVal(val))
+		}
+	}
+	sortValues(vals)
+	return vd.validationFn(vals)
+}
+
+func (vd *wildcardValidation[T]) failure(validationErr, failureCause error) error {
+	return &validationError[T]{query: vd.query, validationErr: validationErr, failureCause: failureCause}
+}
+
+// nodeValue converts the value of a node to the type of a query, returning
+// false if the node is unset.
+func nodeValue[T any](n *node) (T, bool) {
+	var zero T
+	v := n.val
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return zero, false
+		}
+		if v.Kind() == reflect.Ptr && v.Elem().Kind() != reflect.Struct {
+			v = v.Elem()
+		}
+	case reflect.Slice, reflect.Map:
+		if v.Len() == 0 {
+			return zero, false
+		}
+	case reflect.Int64:
+		if v.Int() == 0 {
+			return zero, false // unset enum
+		}
+	}
+	val, ok := v.Interface().(T)
+	return val, ok
+}
+
+// batched is a batchable validator with the path of its query relative to
+// the root of the batch.
+type batched struct {
+	vd    batchable
+	index int
+	rel   []*gpb.PathElem
+}
+
+// split separates the validators that can be batched from the others.
+func (b *Batch[R]) split() (rootPath *gpb.Path, bs []*batched, others []int, err error) {
+	rootPath, _, err = ygnmi.ResolvePath(b.root.PathStruct())
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("bad root query: %w", err)
+	}
+	for i, vd := range b.vds {
+		bv, ok := vd.(batchable)
+		if !ok {
+			others = append(others, i)
+			continue
+		}
+		ps, state := bv.batchQuery()
+		path, _, err := ygnmi.ResolvePath(ps)
+		if err != nil || state != b.root.IsState() || !util.PathMatchesQuery(path, rootPath) {
+			others = append(others, i)
+			continue
+		}
+		bs = append(bs, &batched{vd: bv, index: i, rel: path.GetElem()[len(rootPath.GetElem()):]})
+	}
+	return rootPath, bs, others, nil
+}
+
+// subscriptionPaths groups the paths of the batched validators by the list
+// entry they are in, and returns the longest common prefix of each group,
+// leaving out any path that is covered by another.
+func subscriptionPaths(bs []*batched) []*gpb.Path {
+	groups := make(map[string][]*gpb.PathElem)
+	var keys []string
+	for _, bv := range bs {
+		end := 1
+		for i, e := range bv.rel {
+			if len(e.GetKey()) > 0 {
+				end = i + 1
+			}
+		}
+		if end > len(bv.rel) {
+			end = len(bv.rel)
+		}
+		key, _ := ygot.PathToString(&gpb.Path{Elem: bv.rel[:end]})
+		prefix, ok := groups[key]
+		if !ok {
+			keys = append(keys, key)
+			groups[key] = bv.rel
+			continue
+		}
+		groups[key] = commonPrefix(prefix, bv.rel)
+	}
+	sort.Strings(keys)
+	var paths []*gpb.Path
+	for _, k := range keys {
+		paths = append(paths, &gpb.Path{Elem: groups[k]})
+	}
+	var minimal []*gpb.Path
+	for i, p := range paths {
+		covered := false
+		for j, q := range paths {
+			if i != j && util.PathMatchesQuery(p, q) && !(util.PathMatchesQuery(q, p) && j > i) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			minimal = append(minimal, p)
+		}
+	}
+	return minimal
+}
+
+func commonPrefix(a, b []*gpb.PathElem) []*gpb.PathElem {
+	n := 0
+	for n < len(a) && n < len(b) && proto.Equal(a[n], b[n]) {
+		n++
+	}
+	return a[:n]
+}
+
+// SubscriptionPaths returns the paths that the batch subscribes to, relative
+// to the root query.
+func (b *Batch[R]) SubscriptionPaths() ([]string, error) {
+	_, bs, _, err := b.split()
+	if err != nil {
+		return nil, err
+	}
+	var strs []string
+	for _, p := range subscriptionPaths(bs) {
+		s, err := ygot.PathToString(p)
+		if err != nil {
+			return nil, err
+		}
+		strs = append(strs, s)
+	}
+	return strs, nil
+}
+
+// query returns a query of the root for the subscription paths.
+func (b *Batch[R]) query(rootPath *gpb.Path, bs []*batched) (ygnmi.SingletonQuery[R], error) {
+	batch := ygnmi.NewBatch(b.root)
+	for _, p := range subscriptionPaths(bs) {
+		var ps ygnmi.PathStruct = ygnmi.NewDeviceRootBase()
+		for _, e := range append(append([]*gpb.PathElem{}, rootPath.GetElem()...), p.GetElem()...) {
+			keys := make(map[string]interface{})
+			for k, v := range e.GetKey() {
+				keys[k] = v
+			}
+			ps = ygnmi.NewNodePath([]string{e.GetName()}, keys, ps)
+		}
+		if err := batch.AddPaths(ps); err != nil {
+			return nil, err
+		}
+	}
+	return batch.Query(), nil
+}
+
+// validate validates the batched validators that have not passed yet against
+// the value of the root, and returns the number still failing.
+func validate[R ygot.ValidatedGoStruct](root *ygnmi.Value[R], bs []*batched, passed []bool, lastInvalid []error) int {
+	var gs reflect.Value
+	if val, ok := root.Val(); ok {
+		gs = reflect.ValueOf(val)
+	}
+	failing := 0
+	for i, bv := range bs {
+		if passed[i] {
+			continue
+		}
+		var nodes []*node
+		if gs.IsValid() {
+			nodes = findNodes(gs, bv.rel, nil)
+		}
+		if lastInvalid[i] = bv.vd.validateNodes(nodes, root.Timestamp); lastInvalid[i] == nil {
+			passed[i] = true
+		} else {
+			failing++
+		}
+	}
+	return failing
+}
+
+// Check validates every validator immediately, fetching the values of the
+// batched validators with a single Subscribe.  It returns one error per
+// validator, in order, which is nil if the validator passed.
+func (b *Batch[R]) Check(client *ygnmi.Client) []error {
+	errs := make([]error, len(b.vds))
+	rootPath, bs, others, err := b.split()
+	if err != nil {
+		return b.fail(errs, err)
+	}
+	var q ygnmi.SingletonQuery[R]
+	if len(bs) > 0 {
+		// Build the query before starting the others, so that a failure
+		// does not race with them.
+		if q, err = b.query(rootPath, bs); err != nil {
+			return b.fail(errs, err)
+		}
+	}
+	var wg sync.WaitGroup
+	for _, i := range others {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = b.vds[i].Check(client)
+		}(i)
+	}
+	defer wg.Wait()
+	if len(bs) == 0 {
+		return errs
+	}
+	root, err := ygnmi.Lookup(context.Background(), client, q)
+	passed, lastInvalid := make([]bool, len(bs)), make([]error, len(bs))
+	if err == nil {
+		validate(root, bs, passed, lastInvalid)
+	}
+	for i, bv := range bs {
+		switch {
+		case err != nil:
+			errs[bv.index] = bv.vd.failure(nil, err)
+		case !passed[i]:
+			errs[bv.index] = bv.vd.failure(lastInvalid[i], nil)
+		}
+	}
+	return errs
+}
+
+// Await waits for every validator to pass, watching the batched validators
+// with a single Subscribe stream.  It returns one error per validator, in
+// order, which is nil if the validator passed.  Like Validator.Await, it
+// always fetches the values at least once.
+func (b *Batch[R]) Await(ctx context.Context, client *ygnmi.Client) []error {
+	errs := make([]error, len(b.vds))
+	rootPath, bs, others, err := b.split()
+	if err != nil {
+		return b.fail(errs, err)
+	}
+	var q ygnmi.SingletonQuery[R]
+	if len(bs) > 0 {
+		// Build the query before starting the others, so that a failure
+		// does not race with them.
+		if q, err = b.query(rootPath, bs); err != nil {
+			return b.fail(errs, err)
+		}
+	}
+	var wg sync.WaitGroup
+	for _, i := range others {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = b.vds[i].Await(ctx, client)
+		}(i)
+	}
+	defer wg.Wait()
+	if len(bs) == 0 {
+		return errs
+	}
+	passed, lastInvalid := make([]bool, len(bs)), make([]error, len(bs))
+	root, err := ygnmi.Lookup(ctx, client, q)
+	if err == nil && validate(root, bs, passed, lastInvalid) > 0 {
+		watcher := ygnmi.Watch(ctx, client, q, func(root *ygnmi.Value[R]) error {
+			if validate(root, bs, passed, lastInvalid) > 0 {
+				return ygnmi.Continue
+			}
+			return nil
+		})
+		_, err = watcher.Await()
+	}
+	for i, bv := range bs {
+		if !passed[i] {
+			errs[bv.index] = bv.vd.failure(lastInvalid[i], err)
+		}
+	}
+	return errs
+}
+
+// AwaitFor calls Await with a context with deadline now + timeout, which is
+// shared by all the validators. If timeout is <= 0, this is equivalent to
+// Check().
+func (b *Batch[R]) AwaitFor(timeout time.Duration, client *ygnmi.Client) []error {
+	if timeout <= 0 {
+		return b.Check(client)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return b.Await(ctx, client)
+}
+
+// AwaitUntil calls Await with a context with the given deadline, which is
+// shared by all the validators. If deadline is in the past, this is
+// equivalent to Check().
+func (b *Batch[R]) AwaitUntil(deadline time.Time, client *ygnmi.Client) []error {
+	if deadline.Before(time.Now()) {
+		return b.Check(client)
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	return b.Await(ctx, client)
+}
+
+// fail sets the error of every validator that has not run to err.
+func (b *Batch[R]) fail(errs []error, err error) []error {
+	for i, vd := range b.vds {
+		if errs[i] == nil {
+			errs[i] = fmt.Errorf("%s: %w", vd.Path(), err)
+		}
+	}
+	return errs
+}
+
+// findNodes returns the values at the path elements under the GoStruct v,
+// whose path is prefix, matching any wildcard list keys.
+func findNodes(v reflect.Value, elems, prefix []*gpb.PathElem) []*node {
+	if len(elems) == 0 {
+		return []*node{{path: &gpb.Path{Elem: prefix}, val: v}}
+	}
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	s := v.Elem()
+	for i := 0; i < s.NumField(); i++ {
+		tag, ok := s.Type().Field(i).Tag.Lookup("path")
+		if !ok {
+			continue
+		}
+		for _, alt := range strings.Split(tag, "|") {
+			names := strings.Split(strings.TrimPrefix(alt, "/"), "/")
+			if !matchNames(names, elems) {
+				continue
+			}
+			f := s.Field(i)
+			rest := elems[len(names):]
+			if f.Kind() != reflect.Map {
+				return findNodes(f, rest, append(prefix, elems[:len(names)]...))
+			}
+			return findEntries(f, elems[len(names)-1], rest, append(prefix, elems[:len(names)-1]...))
+		}
+	}
+	return nil
+}
+
+// matchNames reports whether names are the names of the first path elements.
+func matchNames(names []string, elems []*gpb.PathElem) bool {
+	if len(names) > len(elems) {
+		return false
+	}
+	for i, name := range names {
+		if elems[i].GetName() != name {
+			return false
+		}
+	}
+	return true
+}
+
+// findEntries finds the nodes under the entries of the list m that match the
+// keys of the list path element.
+func findEntries(m reflect.Value, list *gpb.PathElem, rest, prefix []*gpb.PathElem) []*node {
+	if len(rest) == 0 {
+		return nil // whole lists are not supported
+	}
+	var nodes []*node
+	iter := m.MapRange()
+	for iter.Next() {
+		entry := iter.Value()
+		km, ok := entry.Interface().(ygot.KeyHelperGoStruct)
+		if !ok {
+			continue
+		}
+		keyMap, err := km.ΛListKeyMap()
+		if err != nil {
+			continue
+		}
+		keys := make(map[string]string)
+		match := true
+		for k, v := range keyMap {
+			s, err := ygot.KeyValueAsString(v)
+			if err != nil {
+				match = false
+				break
+			}
+			keys[k] = s
+			if want, ok := list.GetKey()[k]; ok && want != "*" && want != s {
+				match = false
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+		entryPath := append(append([]*gpb.PathElem{}, prefix...), &gpb.PathElem{Name: list.GetName(), Key: keys})
+		nodes = append(nodes, findNodes(entry, rest, entryPath)...)
+	}
+	return nodes
+}
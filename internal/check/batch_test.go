@@ -0,0 +1,141 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package check_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/featureprofiles/internal/check"
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/ygnmi/exampleoc/exampleocpath"
+	"github.com/openconfig/ygot/ygot"
+)
+
+// pathUpdate is an update to the leaf at path, delivered after delay.
+type pathUpdate struct {
+	path  string
+	val   *gpb.TypedValue
+	delay time.Duration
+}
+
+func stringVal(s string) *gpb.TypedValue {
+	return &gpb.TypedValue{Value: &gpb.TypedValue_StringVal{StringVal: s}}
+}
+
+func intVal(i int64) *gpb.TypedValue {
+	return &gpb.TypedValue{Value: &gpb.TypedValue_IntVal{IntVal: i}}
+}
+
+func singleKeyValuePath(key string) string {
+	return fmt.Sprintf("/model/a/single-key[key=%s]/state/value", key)
+}
+
+// stubPaths clears the fakeGNMI's stub and populates it with updates to
+// arbitrary leaves.  Consecutive updates with the same delay are sent in one
+// notification, followed by a sync response.
+func (fg *fakeGNMI) stubPaths(t *testing.T, updates ...pathUpdate) {
+	t.Helper()
+	fg.gen.Reset()
+	var notif *gpb.Notification
+	for i, u := range updates {
+		path, err := ygot.StringToStructuredPath(u.path)
+		if err != nil {
+			t.Fatalf("Parsing path %q: %v", u.path, err)
+		}
+		if notif == nil {
+			notif = &gpb.Notification{Timestamp: int64(u.delay)}
+		}
+		notif.Update = append(notif.Update, &gpb.Update{Path: path, Val: u.val})
+		if i+1 < len(updates) && updates[i+1].delay == u.delay {
+			continue
+		}
+		fg.gen.Responses = append(fg.gen.Responses, &gpb.SubscribeResponse{
+			Response: &gpb.SubscribeResponse_Update{Update: notif},
+		}, &gpb.SubscribeResponse{
+			Response: &gpb.SubscribeResponse_SyncResponse{SyncResponse: true},
+		})
+		notif = nil
+	}
+	initResponses(fg.gen.Responses)
+}
+
+func TestBatchSubscriptionPaths(t *testing.T) {
+	root := exampleocpath.Root()
+	b := check.NewBatch(root.State(),
+		check.Equal(childTwo.State(), "up"),
+		check.Present(root.Parent().Child().Four().State()),
+		check.ForAll(singleKeyValues.State(), "want positive", positive),
+		check.Equal(root.Model().SingleKey("a").Value().State(), 1),
+		check.NonDecreasing(root.Model().MultiKey(1, 2).Key2().State(), time.Millisecond),
+	)
+	got, err := b.SubscriptionPaths()
+	if err != nil {
+		t.Fatalf("SubscriptionPaths() got error: %v", err)
+	}
+	want := []string{"/model/a/single-key[key=*]/state/value", "/parent/child/state"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("SubscriptionPaths() unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	fakeGNMI, c := mustNewFakeGNMI(context.Background(), t)
+	defer fakeGNMI.Close()
+	root := exampleocpath.Root()
+	ms := time.Millisecond
+	b := check.NewBatch(root.State(),
+		check.Equal(childTwo.State(), "up"),
+		check.ForAll(singleKeyValues.State(), "want positive", positive),
+		check.Equal(root.Model().SingleKey("c").Value().State(), 3),
+	)
+	fakeGNMI.stubPaths(t,
+		pathUpdate{childTwoStatePath, stringVal("down"), ms},
+		pathUpdate{singleKeyValuePath("a"), intVal(1), ms},
+		pathUpdate{singleKeyValuePath("b"), intVal(-1), ms},
+		pathUpdate{childTwoStatePath, stringVal("up"), 5 * ms},
+		pathUpdate{singleKeyValuePath("b"), intVal(2), 10 * ms},
+		pathUpdate{childTwoStatePath, stringVal("down"), time.Hour},
+	)
+
+	wantCheck := [][]string{
+		{childTwoStatePath, `got "down", want "up"`},
+		{singleKeyValuesPath, "-1 at /model/a/single-key[key=b]/state/value", "want positive"},
+		{"/model/a/single-key[key=c]/state/value", "no value"},
+	}
+	for i, err := range b.Check(c) {
+		if err := errContainsAll(err, wantCheck[i]); err != nil {
+			t.Errorf("Check() validator %d: %v", i, err)
+		}
+	}
+
+	wantAwait := [][]string{
+		nil,
+		nil,
+		{"/model/a/single-key[key=c]/state/value", "no value", "deadline"},
+	}
+	for i, err := range b.AwaitFor(50*ms, c) {
+		if len(wantAwait[i]) == 0 {
+			if err != nil {
+				t.Errorf("AwaitFor() validator %d: unexpected error: %v", i, err)
+			}
+		} else if err := errContainsAll(err, wantAwait[i]); err != nil {
+			t.Errorf("AwaitFor() validator %d: %v", i, err)
+		}
+	}
+}
@@ -72,6 +72,75 @@ errors of the form "<path>: <got>, <want>", such as:
 	/system/hostname: got "wrongname", want "node1" or nil
 	/some/other/path: got 100, want no value
 
+# Wildcard validators
+
+Wildcard queries, such as ocpath.Root().InterfaceAny().OperStatus().State(),
+are validated against all of their present values at once:
+
+  - check.ValidateAll(query, validationFn func([]*Value[T]) error) is the
+    generic form; the values are sorted by path.
+  - check.ForAll(query, wantMsg, predicate) checks that there is at least one
+    value and every value satisfies the predicate.
+  - check.Exists(query, wantMsg, predicate) checks that some value satisfies
+    the predicate.
+  - check.CountEquals(query, n) and check.CountAtLeast(query, n) check the
+    number of values.
+
+Their errors name the paths of the offending values, e.g.
+
+	/interfaces/interface[name=*]/state/oper-status: got DOWN at /interfaces/interface[name=eth1]/state/oper-status, want UP
+
+# Counters and rates
+
+Counter and rate leaves are validated by comparing two samples taken interval
+apart, using the notification timestamps. Unsigned counters that wrap around
+between the samples are handled, and ieeefloat32 rate leaves are decoded with
+ygot.BinaryToFloat32:
+
+  - check.IncreaseAtLeast(query, interval, n) checks the counter increases by
+    at least n.
+  - check.IncreaseWithin(query, interval, want, percent) checks the counter
+    increases by want, plus or minus percent of want.
+  - check.RateWithin(query, interval, min, max) checks the rate per second is
+    within the bounds.
+  - check.NonDecreasing(query, interval) checks the counter never decreases.
+
+Check blocks for the interval between the samples, and Await keeps watching
+until a pair of samples passes. The errors show both samples, e.g.
+
+	/interfaces/interface[name=eth1]/state/counters/in-pkts: got 100 then 150 (+50 in 1s), want an increase of at least 100
+
+# Stability
+
+Await passes as soon as a value passes validation once, even if it changes
+right afterwards. check.StaysFor(vd, window) instead waits for vd to pass and
+then keeps watching it for the window, and check.Stays(vd) keeps watching it
+until the Await deadline. If the value deviates, the error shows the value and
+when it happened, e.g.
+
+	/network-instances/.../state/session-state: got IDLE, want ESTABLISHED (at 12:00:04.250, 3.2s after first passing at 12:00:01.050)
+
+# Combinators
+
+check.AllOf(vds...), check.AnyOf(vds...) and check.Not(vd) combine Validators
+into a new Validator with the same Check and Await semantics. AllOf awaits its
+validators under one shared deadline, AnyOf awaits them concurrently and passes
+as soon as one of them does, and Not passes when vd fails validation; a failure
+to fetch the value is still an error.
+
+# Batches
+
+Each Validator opens its own Subscribe stream, which adds up when a test
+awaits hundreds of them.  check.NewBatch(root, vds...) groups the singleton and
+wildcard validators under a root query by common path prefix and watches them
+with as few subscriptions as possible, under one shared deadline:
+
+	errs := check.NewBatch(gnmi.OC().State(), vds...).AwaitFor(time.Minute, client)
+
+The result has one error per validator, in order, in the same format as the
+validator's own Await; nil means it passed.  Validators that cannot be batched,
+such as counters and combinators, are awaited on their own, concurrently.
+
 # Validating a Validator
 
 Given a Validator, there are several ways to test its condition:
@@ -217,7 +286,7 @@ type Validator interface {
 // ended the await, and will frequently also have a validationErr (the error
 // generated by the most recent call to the validation function).
 type validationError[T any] struct {
-	query ygnmi.SingletonQuery[T]
+	query ygnmi.AnyQuery[T]
 	// validationErr is the error returned by the validation function.
 	validationErr error
 	// failureCause is the error that triggered this error. This will be nil if
@@ -253,6 +322,12 @@ func (f *validationError[T]) Error() string {
 
 var _ error = (*validationError[any])(nil)
 
+// validationFailed returns true if the error is a validation failure, rather
+// than a failure to fetch any values.
+func (f *validationError[T]) validationFailed() bool {
+	return f.failureCause == nil && f.validationErr != nil
+}
+
 // isTimeout returns true if and only if err is a status.DeadlineExceeded.
 func isTimeout(err error) bool {
 	if err == nil {
@@ -271,6 +346,8 @@ func isTimeout(err error) bool {
 type validation[T any] struct {
 	query        ygnmi.SingletonQuery[T]
 	validationFn func(*ygnmi.Value[T]) error
+	// want describes the expected value, e.g. "want 5", if known.
+	want string
 }
 
 var _ Validator = (*validation[any])(nil)
@@ -364,7 +441,7 @@ func (vd *validation[T]) AwaitUntil(deadline time.Time, client *ygnmi.Client) er
 
 // Validate expects validationFn to return no error on the query's value.
 func Validate[T any, QT ygnmi.SingletonQuery[T]](query QT, validationFn func(*ygnmi.Value[T]) error) Validator {
-	return &validation[T]{query, validationFn}
+	return &validation[T]{query: query, validationFn: validationFn}
 }
 
 // Predicate expects that the query has a value and the given predicate returns
@@ -373,13 +450,13 @@ func Validate[T any, QT ygnmi.SingletonQuery[T]](query QT, validationFn func(*yg
 //
 //	"/some/path: got 13, want a multiple of 4".
 func Predicate[T any, QT ygnmi.SingletonQuery[T]](query QT, wantMsg string, predicate func(T) bool) Validator {
-	return Validate(query, func(vgot *ygnmi.Value[T]) error {
+	return &validation[T]{query: query, want: wantMsg, validationFn: func(vgot *ygnmi.Value[T]) error {
 		got, present := vgot.Val()
 		if !present || !predicate(got) {
 			return fmt.Errorf("got %s, %s", FormatValue(vgot), wantMsg)
 		}
 		return nil
-	})
+	}}
 }
 
 // Equal expects the query's value to be want.
@@ -398,13 +475,14 @@ func NotEqual[T any, QT ygnmi.SingletonQuery[T]](query QT, wantNot T) Validator
 
 // EqualOrNil expects the query to be unset or have value want.
 func EqualOrNil[T any, QT ygnmi.SingletonQuery[T]](query QT, want T) Validator {
-	return Validate(query, func(vgot *ygnmi.Value[T]) error {
+	wantMsg := fmt.Sprintf("want %#v or no value", want)
+	return &validation[T]{query: query, want: wantMsg, validationFn: func(vgot *ygnmi.Value[T]) error {
 		got, present := vgot.Val()
 		if present && !reflect.DeepEqual(got, want) {
-			return fmt.Errorf("got %s, want %#v or no value", FormatValue(vgot), want)
+			return fmt.Errorf("got %s, %s", FormatValue(vgot), wantMsg)
 		}
 		return nil
-	})
+	}}
 }
 
 // Present expects the query to have any value.
@@ -416,10 +494,10 @@ func Present[T any, QT ygnmi.SingletonQuery[T]](query QT) Validator {
 
 // NotPresent expects the query to not have a value set.
 func NotPresent[T any, QT ygnmi.SingletonQuery[T]](query QT) Validator {
-	return Validate(query, func(vgot *ygnmi.Value[T]) error {
+	return &validation[T]{query: query, want: "want no value", validationFn: func(vgot *ygnmi.Value[T]) error {
 		if vgot.IsPresent() {
 			return fmt.Errorf("got %s, want no value", FormatValue(vgot))
 		}
 		return nil
-	})
+	}}
 }
@@ -28,6 +28,7 @@ import (
 	"github.com/openconfig/ygnmi/ygnmi"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
 )
 
 var (
@@ -58,7 +59,7 @@ func newFakeGNMI(ctx context.Context) (*fakeGNMI, error) {
 		Generator:   &fpb.Config_Fixed{Fixed: gen},
 		EnableDelay: true, // Respect timestamps if present.
 	}
-	agent, err := gnmi.New(config, nil)
+	agent, err := gnmi.New(config, []grpc.ServerOption{grpc.StreamInterceptor(cloneResponses)})
 	if err != nil {
 		return nil, err
 	}
@@ -80,6 +81,33 @@ func newFakeGNMI(ctx context.Context) (*fakeGNMI, error) {
 	}, nil
 }
 
+// cloneResponses is a stream interceptor that sends a copy of each response,
+// since the fake agent sends the same stubbed responses to every subscription,
+// and validators may subscribe more than once at a time.
+func cloneResponses(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &cloningStream{ss})
+}
+
+type cloningStream struct {
+	grpc.ServerStream
+}
+
+func (s *cloningStream) SendMsg(m interface{}) error {
+	if pm, ok := m.(proto.Message); ok {
+		m = proto.Clone(pm)
+	}
+	return s.ServerStream.SendMsg(m)
+}
+
+// initResponses initializes the internal state of stubbed responses, which
+// cloneResponses would otherwise set while the fake agent prints them for
+// another subscription.
+func initResponses(responses []*gpb.SubscribeResponse) {
+	for _, r := range responses {
+		r.ProtoReflect()
+	}
+}
+
 func mustNewFakeGNMI(ctx context.Context, t *testing.T) (*fakeGNMI, *ygnmi.Client) {
 	fake, err := newFakeGNMI(ctx)
 	if err != nil {
@@ -125,6 +153,7 @@ func (fg *fakeGNMI) stubChildTwo(updates ...update) {
 			})
 		}
 	}
+	initResponses(fg.gen.Responses)
 }
 
 // errContainsAll returns an error unless the string form of got contains every
@@ -0,0 +1,418 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openconfig/ygnmi/ygnmi"
+)
+
+// pollInterval is how often Not and Stays re-check a validator that they
+// can neither negate nor watch.
+const pollInterval = 100 * time.Millisecond
+
+// expecter is implemented by validators that can describe what they expect,
+// e.g. "want 5".
+type expecter interface {
+	expectation() string
+}
+
+// expectation returns what vd expects, or an empty string if unknown.
+func expectation(vd Validator) string {
+	if e, ok := vd.(expecter); ok {
+		return e.expectation()
+	}
+	return ""
+}
+
+func (vd *validation[T]) expectation() string         { return vd.want }
+func (vd *wildcardValidation[T]) expectation() string { return vd.want }
+func (vd *counterValidation[T]) expectation() string  { return vd.want }
+func (s *stability) expectation() string              { return expectation(s.vd) }
+func (n *not) expectation() string                    { return negatedWant(expectation(n.vd)) }
+
+// negatedWant describes the expectation of a negated validator that expects
+// want.
+func negatedWant(want string) string {
+	if want == "" {
+		return "want a value that fails the negated check"
+	}
+	return fmt.Sprintf("want a value that fails the negated check (%s)", want)
+}
+
+// negatable is implemented by validators that can build their own negation,
+// so that Not can keep watching the query instead of polling it.
+type negatable interface {
+	negate() Validator
+}
+
+// failer is implemented by errors that can tell a failed validation apart
+// from a failure to fetch any values.
+type failer interface {
+	validationFailed() bool
+}
+
+// combinedError is the error of a combinator, holding the errors of the
+// validators that failed.
+type combinedError struct {
+	name string
+	errs []error
+}
+
+func (e *combinedError) Error() string {
+	lines := []string{fmt.Sprintf("%s failed:", e.name)}
+	for _, err := range e.errs {
+		lines = append(lines, "  "+strings.ReplaceAll(err.Error(), "\n", "\n  "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// validationFailed returns true if every combined error is a validation
+// failure.
+func (e *combinedError) validationFailed() bool {
+	for _, err := range e.errs {
+		if !validationFailed(err) {
+			return false
+		}
+	}
+	return len(e.errs) > 0
+}
+
+// validationFailed returns true if err is a validation failure, rather than a
+// failure to fetch any values.
+func validationFailed(err error) bool {
+	var f failer
+	return errors.As(err, &f) && f.validationFailed()
+}
+
+// formatPaths formats the paths of vds as e.g. "AllOf(/a/b, /c/d)".
+func formatPaths(name string, vds []Validator, path func(Validator) string) string {
+	var paths []string
+	for _, vd := range vds {
+		paths = append(paths, path(vd))
+	}
+	return fmt.Sprintf("%s(%s)", name, strings.Join(paths, ", "))
+}
+
+// allOf is the implementation of AllOf.
+type allOf struct {
+	vds []Validator
+}
+
+// AllOf expects every one of vds to pass.  Await waits for all the
+// validators concurrently, each until the deadline of its context, so that a
+// validator that times out does not use up the time of the others, and
+// reports all the validators that did not pass.
+func AllOf(vds ...Validator) Validator {
+	return &allOf{vds}
+}
+
+// Path returns a string representation of the paths being validated.
+func (a *allOf) Path() string {
+	return formatPaths("AllOf", a.vds, Validator.Path)
+}
+
+// RelPath returns a string representation of the paths being validated,
+// relative to some base.
+func (a *allOf) RelPath(base ygnmi.PathStruct) string {
+	return formatPaths("AllOf", a.vds, func(vd Validator) string { return vd.RelPath(base) })
+}
+
+// Check tests every validator immediately and returns an error if any fails.
+func (a *allOf) Check(client *ygnmi.Client) error {
+	return a.run(func(vd Validator) error { return vd.Check(client) })
+}
+
+// Await waits for every validator to pass.
+func (a *allOf) Await(ctx context.Context, client *ygnmi.Client) error {
+	errs := make([]error, len(a.vds))
+	var wg sync.WaitGroup
+	for i, vd := range a.vds {
+		wg.Add(1)
+		go func(i int, vd Validator) {
+			defer wg.Done()
+			errs[i] = vd.Await(ctx, client)
+		}(i, vd)
+	}
+	wg.Wait()
+	return a.combine(errs)
+}
+
+func (a *allOf) run(fn func(Validator) error) error {
+	var errs []error
+	for _, vd := range a.vds {
+		errs = append(errs, fn(vd))
+	}
+	return a.combine(errs)
+}
+
+// combine returns the non-nil errors of the validators as one error, or nil
+// if there are none.
+func (a *allOf) combine(errs []error) error {
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) > 0 {
+		return &combinedError{name: a.Path(), errs: failed}
+	}
+	return nil
+}
+
+// AwaitFor calls Await with a context with deadline now + timeout. If timeout
+// is <= 0, this is equivalent to Check().
+func (a *allOf) AwaitFor(timeout time.Duration, client *ygnmi.Client) error {
+	return awaitFor(a, timeout, client)
+}
+
+// AwaitUntil calls Await with a context with the given deadline. If deadline
+// is in the past, this is equivalent to Check().
+func (a *allOf) AwaitUntil(deadline time.Time, client *ygnmi.Client) error {
+	return awaitUntil(a, deadline, client)
+}
+
+func (a *allOf) negate() Validator {
+	return AnyOf(negateAll(a.vds)...)
+}
+
+// anyOf is the implementation of AnyOf.
+type anyOf struct {
+	vds []Validator
+}
+
+// AnyOf expects at least one of vds to pass.  Await waits for all the
+// validators concurrently and returns as soon as one passes; if none passes,
+// it reports the errors of all of them.
+func AnyOf(vds ...Validator) Validator {
+	return &anyOf{vds}
+}
+
+// Path returns a string representation of the paths being validated.
+func (a *anyOf) Path() string {
+	return formatPaths("AnyOf", a.vds, Validator.Path)
+}
+
+// RelPath returns a string representation of the paths being validated,
+// relative to some base.
+func (a *anyOf) RelPath(base ygnmi.PathStruct) string {
+	return formatPaths("AnyOf", a.vds, func(vd Validator) string { return vd.RelPath(base) })
+}
+
+// Check tests the validators immediately and returns an error if none passes.
+func (a *anyOf) Check(client *ygnmi.Client) error {
+	var errs []error
+	for _, vd := range a.vds {
+		err := vd.Check(client)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return &combinedError{name: a.Path(), errs: errs}
+}
+
+// Await waits for any of the validators to pass.
+func (a *anyOf) Await(ctx context.Context, client *ygnmi.Client) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make([]error, len(a.vds))
+	done := make(chan int)
+	for i, vd := range a.vds {
+		go func(i int, vd Validator) {
+			errs[i] = vd.Await(ctx, client)
+			done <- i
+		}(i, vd)
+	}
+	passed := false
+	for range a.vds {
+		if i := <-done; errs[i] == nil && !passed {
+			passed = true
+			cancel()
+		}
+	}
+	if passed {
+		return nil
+	}
+	return &combinedError{name: a.Path(), errs: errs}
+}
+
+// AwaitFor calls Await with a context with deadline now + timeout. If timeout
+// is <= 0, this is equivalent to Check().
+func (a *anyOf) AwaitFor(timeout time.Duration, client *ygnmi.Client) error {
+	return awaitFor(a, timeout, client)
+}
+
+// AwaitUntil calls Await with a context with the given deadline. If deadline
+// is in the past, this is equivalent to Check().
+func (a *anyOf) AwaitUntil(deadline time.Time, client *ygnmi.Client) error {
+	return awaitUntil(a, deadline, client)
+}
+
+func (a *anyOf) negate() Validator {
+	return AllOf(negateAll(a.vds)...)
+}
+
+// Not expects vd to fail validation.  A failure to fetch the value is still
+// reported as an error, rather than counting as a pass.  Not(AllOf(...)) and
+// Not(AnyOf(...)) are rewritten as AnyOf and AllOf of the negated validators.
+func Not(vd Validator) Validator {
+	if n, ok := vd.(negatable); ok {
+		return n.negate()
+	}
+	return &not{vd}
+}
+
+func negateAll(vds []Validator) []Validator {
+	var negated []Validator
+	for _, vd := range vds {
+		negated = append(negated, Not(vd))
+	}
+	return negated
+}
+
+// not negates a Validator that is not negatable by watching it, or polling
+// its Check if it is not watchable either.
+type not struct {
+	vd Validator
+}
+
+// Path returns a string representation of the path being validated.
+func (n *not) Path() string {
+	return "Not(" + n.vd.Path() + ")"
+}
+
+// RelPath returns a string representation of the path being validated,
+// relative to some base.
+func (n *not) RelPath(base ygnmi.PathStruct) string {
+	return "Not(" + n.vd.RelPath(base) + ")"
+}
+
+// Check tests the validation condition immediately and returns an error if
+// the negated validator passes.
+func (n *not) Check(client *ygnmi.Client) error {
+	err := n.vd.Check(client)
+	switch {
+	case err == nil:
+		return n.passedError()
+	case validationFailed(err):
+		return nil
+	}
+	return err
+}
+
+func (n *not) passedError() error {
+	return &notError{path: n.vd.Path(), want: expectation(n.vd)}
+}
+
+// Await watches the negated validator until it fails validation or ctx is
+// done.  Like the other validators, it always checks at least once.
+func (n *not) Await(ctx context.Context, client *ygnmi.Client) error {
+	var last error
+	fn := func(_ time.Time, err error) error {
+		switch {
+		case err == nil:
+			last = n.passedError()
+		case validationFailed(err):
+			return nil
+		default:
+			last = err
+		}
+		return ygnmi.Continue
+	}
+	var err error
+	if w, ok := n.vd.(watchable); ok {
+		err = w.watch(ctx, client, fn)
+	} else {
+		err = pollWatch(ctx, client, n.vd, fn)
+	}
+	switch {
+	case err == nil:
+		return nil
+	case last != nil:
+		return fmt.Errorf("%w (deadline exceeded)", last)
+	case ctx.Err() != nil:
+		return fmt.Errorf("%s: deadline exceeded before any values were fetched", n.Path())
+	}
+	return fmt.Errorf("%s: %w", n.Path(), err)
+}
+
+// AwaitFor calls Await with a context with deadline now + timeout. If timeout
+// is <= 0, this is equivalent to Check().
+func (n *not) AwaitFor(timeout time.Duration, client *ygnmi.Client) error {
+	return awaitFor(n, timeout, client)
+}
+
+// AwaitUntil calls Await with a context with the given deadline. If deadline
+// is in the past, this is equivalent to Check().
+func (n *not) AwaitUntil(deadline time.Time, client *ygnmi.Client) error {
+	return awaitUntil(n, deadline, client)
+}
+
+func (n *not) negate() Validator {
+	return n.vd
+}
+
+// notError is the error of a negated validator that passed.
+type notError struct {
+	path string
+	// want is the expectation of the negated validator, if known.
+	want string
+}
+
+func (e *notError) Error() string {
+	if e.want == "" {
+		return fmt.Sprintf("%s: passed, want it to fail", e.path)
+	}
+	return fmt.Sprintf("%s: passed (%s), want it to fail", e.path, e.want)
+}
+
+func (e *notError) validationFailed() bool {
+	return true
+}
+
+// negate returns a validation that passes exactly when vd's validation
+// function fails.
+func (vd *validation[T]) negate() Validator {
+	want := negatedWant(vd.want)
+	return &validation[T]{query: vd.query, want: want, validationFn: func(v *ygnmi.Value[T]) error {
+		if vd.validationFn(v) == nil {
+			return fmt.Errorf("got %s, %s", FormatValue(v), want)
+		}
+		return nil
+	}}
+}
+
+// negate returns a wildcard validation that passes exactly when vd's
+// validation function fails.
+func (vd *wildcardValidation[T]) negate() Validator {
+	want := "want values that fail the negated check"
+	if vd.want != "" {
+		want = fmt.Sprintf("%s (%s)", want, vd.want)
+	}
+	return &wildcardValidation[T]{query: vd.query, want: want, validationFn: func(vs []*ygnmi.Value[T]) error {
+		if vd.validationFn(vs) == nil {
+			return fmt.Errorf("got %d values, %s", len(vs), want)
+		}
+		return nil
+	}}
+}
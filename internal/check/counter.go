@@ -0,0 +1,390 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/openconfig/ygnmi/ygnmi"
+	"github.com/openconfig/ygot/ygot"
+)
+
+// Numeric is the type of a counter or rate leaf.  Byte slices, such as
+// oc.Binary, are ieeefloat32 leaves decoded with ygot.BinaryToFloat32.
+type Numeric interface {
+	~uint8 | ~uint16 | ~uint32 | ~uint64 |
+		~int8 | ~int16 | ~int32 | ~int64 |
+		~float32 | ~float64 | ~[]byte
+}
+
+// sample is a decoded value of a counter or rate leaf.
+type sample struct {
+	val      reflect.Value
+	at       time.Time
+	unsigned bool
+	// bits is the width of an unsigned counter, used for wrap-around.
+	bits int
+}
+
+// newSample decodes a present value of a Numeric query.
+func newSample[T Numeric](v *ygnmi.Value[T]) (*sample, bool) {
+	got, present := v.Val()
+	if !present {
+		return nil, false
+	}
+	rv := reflect.ValueOf(got)
+	s := &sample{val: rv, at: v.Timestamp}
+	if s.at.IsZero() {
+		s.at = v.RecvTimestamp
+	}
+	switch rv.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s.unsigned, s.bits = true, rv.Type().Bits()
+	}
+	return s, true
+}
+
+// float returns the value of the sample as a float64.
+func (s *sample) float() float64 {
+	switch s.val.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(s.val.Uint())
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(s.val.Int())
+	case reflect.Float32, reflect.Float64:
+		return s.val.Float()
+	}
+	if s.val.Len() != 4 {
+		return math.NaN()
+	}
+	return float64(ygot.BinaryToFloat32(s.val.Bytes()))
+}
+
+func (s *sample) String() string {
+	if s.unsigned {
+		return strconv.FormatUint(s.val.Uint(), 10)
+	}
+	return strconv.FormatFloat(s.float(), 'g', -1, 64)
+}
+
+// delta returns the increase from s to next.  An unsigned counter that
+// decreased is assumed to have wrapped around once.
+func (s *sample) delta(next *sample) (d float64, wrapped bool) {
+	if !s.unsigned {
+		return next.float() - s.float(), false
+	}
+	a, b := s.val.Uint(), next.val.Uint()
+	diff := b - a
+	if s.bits < 64 {
+		diff &= 1<<s.bits - 1
+	}
+	return float64(diff), b < a
+}
+
+// counterPair is a pair of samples of a counter or rate leaf.
+type counterPair struct {
+	first, second *sample
+}
+
+// String formats both samples and the change between them, e.g.
+// "100 then 150 (+50 in 1s)".
+func (p *counterPair) String() string {
+	d, wrapped := p.first.delta(p.second)
+	change := fmt.Sprintf("%+g in %v", d, p.elapsed())
+	if wrapped {
+		change += ", wrapped around"
+	}
+	return fmt.Sprintf("%v then %v (%s)", p.first, p.second, change)
+}
+
+func (p *counterPair) elapsed() time.Duration {
+	return p.second.at.Sub(p.first.at)
+}
+
+// rate returns the increase per second between the samples.
+func (p *counterPair) rate() float64 {
+	d, _ := p.first.delta(p.second)
+	if secs := p.elapsed().Seconds(); secs > 0 {
+		return d / secs
+	}
+	return math.Inf(1)
+}
+
+// counterValidation is the implementation of Validator for counter and rate
+// leaves, which are validated by comparing two samples.
+type counterValidation[T Numeric] struct {
+	query    ygnmi.SingletonQuery[T]
+	interval time.Duration
+	// throughout validates every consecutive pair of samples for the whole
+	// interval, rather than waiting for a pair of samples at least interval
+	// apart that passes.
+	throughout bool
+	// slide moves the first sample forward after each failing pair, so that
+	// Await compares recent samples rather than the change since it started.
+	slide        bool
+	validationFn func(*counterPair) error
+	// want describes the expected change, e.g. "want an increase of at
+	// least 100".
+	want string
+}
+
+var _ Validator = (*counterValidation[uint64])(nil)
+
+// Path returns a string representation of the path being validated.
+func (vd *counterValidation[T]) Path() string {
+	return FormatPath(vd.query.PathStruct())
+}
+
+// RelPath returns a string representation of the path being validated,
+// relative to some base.
+func (vd *counterValidation[T]) RelPath(base ygnmi.PathStruct) string {
+	return FormatRelativePath(base, vd.query.PathStruct())
+}
+
+// lookup fetches a sample of the query.
+func (vd *counterValidation[T]) lookup(ctx context.Context, client *ygnmi.Client) (*sample, error) {
+	v, err := ygnmi.Lookup(ctx, client, vd.query)
+	if err != nil {
+		return nil, &validationError[T]{query: vd.query, failureCause: err}
+	}
+	s, ok := newSample(v)
+	if !ok {
+		return nil, &validationError[T]{query: vd.query, validationErr: errors.New("got no value, want a counter")}
+	}
+	return s, nil
+}
+
+// Check fetches two samples interval apart and validates them.  Unlike the
+// other validators, it blocks for the interval.
+func (vd *counterValidation[T]) Check(client *ygnmi.Client) error {
+	first, err := vd.lookup(context.Background(), client)
+	if err != nil {
+		return err
+	}
+	time.Sleep(vd.interval)
+	second, err := vd.lookup(context.Background(), client)
+	if err != nil {
+		return err
+	}
+	if err := vd.validationFn(&counterPair{first, second}); err != nil {
+		return &validationError[T]{query: vd.query, validationErr: err}
+	}
+	return nil
+}
+
+// Await watches the query until a pair of samples at least interval apart
+// passes validation.  Validators of every consecutive pair, such as
+// NonDecreasing, instead watch for the interval and fail on the first pair
+// that does not pass.
+func (vd *counterValidation[T]) Await(ctx context.Context, client *ygnmi.Client) error {
+	first, err := vd.lookup(ctx, client)
+	if err != nil {
+		return err
+	}
+	if vd.throughout {
+		return vd.awaitThroughout(ctx, client, first)
+	}
+	lastInvalid := fmt.Errorf("got only %v, want a second sample %v later", first, vd.interval)
+	watcher := ygnmi.Watch(ctx, client, vd.query, func(v *ygnmi.Value[T]) error {
+		s, ok := newSample(v)
+		if !ok || s.at.Sub(first.at) < vd.interval {
+			return ygnmi.Continue
+		}
+		if lastInvalid = vd.validationFn(&counterPair{first, s}); lastInvalid != nil {
+			if vd.slide {
+				first = s
+			}
+			return ygnmi.Continue
+		}
+		return nil
+	})
+	if _, err := watcher.Await(); err != nil {
+		return &validationError[T]{
+			query:         vd.query,
+			failureCause:  err,
+			validationErr: lastInvalid,
+		}
+	}
+	return nil
+}
+
+// watch calls fn with the validation result of each pair of samples that
+// Await would validate: pairs at least interval apart, or, for validators of
+// every consecutive pair, every consecutive pair.
+func (vd *counterValidation[T]) watch(ctx context.Context, client *ygnmi.Client, fn func(time.Time, error) error) error {
+	first, err := vd.lookup(ctx, client)
+	if err != nil {
+		if !validationFailed(err) {
+			return err
+		}
+		// There is no first sample yet; the watch waits for one.
+		if err := fn(time.Now(), err); err != ygnmi.Continue {
+			return err
+		}
+	}
+	watcher := ygnmi.Watch(ctx, client, vd.query, func(v *ygnmi.Value[T]) error {
+		s, ok := newSample(v)
+		if ok && first == nil {
+			first = s
+			return ygnmi.Continue
+		}
+		if !ok || (!vd.throughout && s.at.Sub(first.at) < vd.interval) {
+			return ygnmi.Continue
+		}
+		var err error
+		if invalid := vd.validationFn(&counterPair{first, s}); invalid != nil {
+			err = &validationError[T]{query: vd.query, validationErr: invalid}
+		}
+		if vd.throughout || (err != nil && vd.slide) {
+			first = s
+		}
+		return fn(s.at, err)
+	})
+	_, err = watcher.Await()
+	return err
+}
+
+// awaitThroughout validates every consecutive pair of samples for the
+// interval after first.
+func (vd *counterValidation[T]) awaitThroughout(ctx context.Context, client *ygnmi.Client, first *sample) error {
+	wctx, cancel := context.WithTimeout(ctx, vd.interval)
+	defer cancel()
+	prev := first
+	var invalid error
+	watcher := ygnmi.Watch(wctx, client, vd.query, func(v *ygnmi.Value[T]) error {
+		s, ok := newSample(v)
+		if !ok {
+			return ygnmi.Continue
+		}
+		if invalid = vd.validationFn(&counterPair{prev, s}); invalid != nil {
+			return nil
+		}
+		prev = s
+		return ygnmi.Continue
+	})
+	_, err := watcher.Await()
+	switch {
+	case invalid != nil:
+		return &validationError[T]{query: vd.query, validationErr: invalid}
+	case err != nil && (ctx.Err() != nil || wctx.Err() == nil):
+		return &validationError[T]{query: vd.query, failureCause: err}
+	}
+	return nil
+}
+
+// AwaitFor calls Await with a context with deadline now + timeout. If timeout
+// is <= 0, this is equivalent to Check().
+func (vd *counterValidation[T]) AwaitFor(timeout time.Duration, client *ygnmi.Client) error {
+	return awaitFor(vd, timeout, client)
+}
+
+// AwaitUntil calls Await with a context with the given deadline. If deadline
+// is in the past, this is equivalent to Check().
+func (vd *counterValidation[T]) AwaitUntil(deadline time.Time, client *ygnmi.Client) error {
+	return awaitUntil(vd, deadline, client)
+}
+
+// IncreaseAtLeast expects the counter to increase by at least n within
+// interval.  Check blocks for interval between its two samples.  The error
+// shows both samples, e.g.
+//
+//	"/some/counter: got 100 then 150 (+50 in 1s), want an increase of at least 100".
+func IncreaseAtLeast[T Numeric, QT ygnmi.SingletonQuery[T]](query QT, interval time.Duration, n float64) Validator {
+	want := fmt.Sprintf("want an increase of at least %g", n)
+	return &counterValidation[T]{
+		query:    query,
+		interval: interval,
+		want:     want,
+		validationFn: func(p *counterPair) error {
+			if d, _ := p.first.delta(p.second); d < n {
+				return fmt.Errorf("got %v, %s", p, want)
+			}
+			return nil
+		},
+	}
+}
+
+// IncreaseWithin expects the counter to increase by want, plus or minus
+// percent of want, over interval.  Check blocks for interval, and Await waits
+// for the increase since its first sample to reach that range.
+func IncreaseWithin[T Numeric, QT ygnmi.SingletonQuery[T]](query QT, interval time.Duration, want, percent float64) Validator {
+	margin := math.Abs(want) * percent / 100
+	wantMsg := fmt.Sprintf("want an increase of %g ± %g%%", want, percent)
+	return &counterValidation[T]{
+		query:    query,
+		interval: interval,
+		want:     wantMsg,
+		validationFn: func(p *counterPair) error {
+			if d, _ := p.first.delta(p.second); math.Abs(d-want) > margin {
+				return fmt.Errorf("got %v, %s", p, wantMsg)
+			}
+			return nil
+		},
+	}
+}
+
+// RateWithin expects the rate of the leaf to be between min and max per
+// second, measured over interval.  For counters, the rate is the increase
+// between two samples; ieeefloat32 rate leaves are already rates, so both
+// samples must be within the bounds.  Check blocks for interval to take the
+// second sample.
+func RateWithin[T Numeric, QT ygnmi.SingletonQuery[T]](query QT, interval time.Duration, min, max float64) Validator {
+	return &counterValidation[T]{
+		query:    query,
+		interval: interval,
+		slide:    true,
+		want:     fmt.Sprintf("want a rate between %g and %g/s", min, max),
+		validationFn: func(p *counterPair) error {
+			if p.first.val.Kind() == reflect.Slice {
+				for _, s := range []*sample{p.first, p.second} {
+					if r := s.float(); r < min || r > max {
+						return fmt.Errorf("got %v then %v, want rates between %g and %g", p.first, p.second, min, max)
+					}
+				}
+				return nil
+			}
+			if r := p.rate(); r < min || r > max {
+				return fmt.Errorf("got %v, a rate of %g/s, want between %g and %g/s", p, r, min, max)
+			}
+			return nil
+		},
+	}
+}
+
+// NonDecreasing expects the counter never to decrease for interval.  Check
+// blocks for interval and compares the two samples taken interval apart,
+// while Await compares every update
+// received during the interval.  A decrease is reported even for an unsigned
+// counter that might have wrapped around.
+func NonDecreasing[T Numeric, QT ygnmi.SingletonQuery[T]](query QT, interval time.Duration) Validator {
+	return &counterValidation[T]{
+		query:      query,
+		interval:   interval,
+		throughout: true,
+		want:       "want a non-decreasing value",
+		validationFn: func(p *counterPair) error {
+			if _, wrapped := p.first.delta(p.second); wrapped || p.second.float() < p.first.float() {
+				return fmt.Errorf("got %v then %v, want a non-decreasing value", p.first, p.second)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package check_test
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/check"
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/ygnmi/exampleoc/exampleocpath"
+	"github.com/openconfig/ygot/ygot"
+)
+
+const (
+	counterPath = "/model/b/multi-key[key1=1][key2=2]/state/key2"
+	ratePath    = "/parent/child/state/four"
+)
+
+// leafUpdate is a value of a leaf, delivered after delay.  The delay is also
+// the timestamp of the notification.
+type leafUpdate struct {
+	val   *gpb.TypedValue
+	delay time.Duration
+}
+
+func uintUpdate(v uint64, delay time.Duration) leafUpdate {
+	return leafUpdate{&gpb.TypedValue{Value: &gpb.TypedValue_UintVal{UintVal: v}}, delay}
+}
+
+func rateUpdate(v float32, delay time.Duration) leafUpdate {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, math.Float32bits(v))
+	return leafUpdate{&gpb.TypedValue{Value: &gpb.TypedValue_BytesVal{BytesVal: b}}, delay}
+}
+
+// stubLeaf clears the fakeGNMI's stub and populates it with updates to the
+// leaf at path, each followed by a sync response.
+func (fg *fakeGNMI) stubLeaf(t *testing.T, path string, updates ...leafUpdate) {
+	t.Helper()
+	p, err := ygot.StringToStructuredPath(path)
+	if err != nil {
+		t.Fatalf("Parsing path %q: %v", path, err)
+	}
+	fg.gen.Reset()
+	for _, u := range updates {
+		fg.gen.Responses = append(fg.gen.Responses, &gpb.SubscribeResponse{
+			Response: &gpb.SubscribeResponse_Update{
+				Update: &gpb.Notification{
+					Timestamp: int64(u.delay),
+					Update:    []*gpb.Update{{Path: p, Val: u.val}},
+				},
+			},
+		}, &gpb.SubscribeResponse{
+			Response: &gpb.SubscribeResponse_SyncResponse{SyncResponse: true},
+		})
+	}
+	initResponses(fg.gen.Responses)
+}
+
+func TestCounterAwait(t *testing.T) {
+	fakeGNMI, c := mustNewFakeGNMI(context.Background(), t)
+	defer fakeGNMI.Close()
+	counter := exampleocpath.Root().Model().MultiKey(1, 2).Key2().State()
+	rate := exampleocpath.Root().Parent().Child().Four().State()
+	ms := time.Millisecond
+	testCases := []struct {
+		desc        string
+		validator   check.Validator
+		path        string
+		updates     []leafUpdate
+		errIncludes []string
+	}{{
+		desc:      "IncreaseAtLeast/Correct",
+		validator: check.IncreaseAtLeast(counter, 10*ms, 150),
+		path:      counterPath,
+		updates:   []leafUpdate{uintUpdate(100, ms), uintUpdate(150, 15*ms), uintUpdate(300, 30*ms)},
+	}, {
+		desc:        "IncreaseAtLeast/Incorrect",
+		validator:   check.IncreaseAtLeast(counter, 10*ms, 500),
+		path:        counterPath,
+		updates:     []leafUpdate{uintUpdate(100, ms), uintUpdate(150, 15*ms), uintUpdate(300, 30*ms), uintUpdate(1000, time.Hour)},
+		errIncludes: []string{counterPath, "100 then 300 (+200 in 29ms)", "at least 500", "deadline"},
+	}, {
+		desc:      "IncreaseAtLeast/Wrapped",
+		validator: check.IncreaseAtLeast(counter, 10*ms, 100),
+		path:      counterPath,
+		updates:   []leafUpdate{uintUpdate(math.MaxUint64-49, ms), uintUpdate(50, 15*ms)},
+	}, {
+		desc:      "IncreaseWithin/Correct",
+		validator: check.IncreaseWithin(counter, 10*ms, 1000, 2),
+		path:      counterPath,
+		updates:   []leafUpdate{uintUpdate(100, ms), uintUpdate(1090, 15*ms)},
+	}, {
+		desc:        "IncreaseWithin/Incorrect",
+		validator:   check.IncreaseWithin(counter, 10*ms, 1000, 2),
+		path:        counterPath,
+		updates:     []leafUpdate{uintUpdate(100, ms), uintUpdate(1200, 15*ms), uintUpdate(1100, time.Hour)},
+		errIncludes: []string{counterPath, "100 then 1200", "1000 ± 2%"},
+	}, {
+		desc:      "RateWithin/Counter",
+		validator: check.RateWithin(counter, 10*ms, 9000, 11000),
+		path:      counterPath,
+		updates:   []leafUpdate{uintUpdate(0, ms), uintUpdate(100, 11*ms)},
+	}, {
+		desc:        "RateWithin/CounterTooFast",
+		validator:   check.RateWithin(counter, 10*ms, 9000, 11000),
+		path:        counterPath,
+		updates:     []leafUpdate{uintUpdate(0, ms), uintUpdate(1000, 11*ms), uintUpdate(1100, time.Hour)},
+		errIncludes: []string{counterPath, "0 then 1000", "a rate of 100000/s"},
+	}, {
+		desc:      "RateWithin/Rate",
+		validator: check.RateWithin(rate, 10*ms, 90, 110),
+		path:      ratePath,
+		updates:   []leafUpdate{rateUpdate(95, ms), rateUpdate(105, 15*ms)},
+	}, {
+		desc:        "RateWithin/RateTooSlow",
+		validator:   check.RateWithin(rate, 10*ms, 90, 110),
+		path:        ratePath,
+		updates:     []leafUpdate{rateUpdate(95, ms), rateUpdate(50.5, 15*ms), rateUpdate(100, time.Hour)},
+		errIncludes: []string{ratePath, "got 95 then 50.5", "between 90 and 110"},
+	}, {
+		desc:      "NonDecreasing/Correct",
+		validator: check.NonDecreasing(counter, 30*ms),
+		path:      counterPath,
+		updates:   []leafUpdate{uintUpdate(100, ms), uintUpdate(100, 10*ms), uintUpdate(120, 20*ms), uintUpdate(90, time.Hour)},
+	}, {
+		desc:        "NonDecreasing/Incorrect",
+		validator:   check.NonDecreasing(counter, 30*ms),
+		path:        counterPath,
+		updates:     []leafUpdate{uintUpdate(100, ms), uintUpdate(120, 10*ms), uintUpdate(90, 20*ms)},
+		errIncludes: []string{counterPath, "got 120 then 90", "non-decreasing"},
+	}, {
+		desc:      "Not/Correct",
+		validator: check.Not(check.IncreaseAtLeast(counter, 10*ms, 500)),
+		path:      counterPath,
+		updates:   []leafUpdate{uintUpdate(100, ms), uintUpdate(150, 15*ms), uintUpdate(1000, time.Hour)},
+	}, {
+		desc:        "Not/Incorrect",
+		validator:   check.Not(check.IncreaseAtLeast(counter, 10*ms, 100)),
+		path:        counterPath,
+		updates:     []leafUpdate{uintUpdate(100, ms), uintUpdate(300, 15*ms), uintUpdate(350, time.Hour)},
+		errIncludes: []string{counterPath, "passed (want an increase of at least 100), want it to fail", "deadline"},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeGNMI.stubLeaf(t, tc.path, tc.updates...)
+			gotErr := tc.validator.AwaitFor(50*ms, c)
+			if len(tc.errIncludes) > 0 {
+				if err := errContainsAll(gotErr, tc.errIncludes); err != nil {
+					t.Error(err)
+				}
+			} else if gotErr != nil {
+				t.Errorf("Unexpected error: %v", gotErr)
+			}
+		})
+	}
+}
+
+func TestCounterCheck(t *testing.T) {
+	fakeGNMI, c := mustNewFakeGNMI(context.Background(), t)
+	defer fakeGNMI.Close()
+	counter := exampleocpath.Root().Model().MultiKey(1, 2).Key2().State()
+	fakeGNMI.stubLeaf(t, counterPath, uintUpdate(100, time.Millisecond))
+	err := check.IncreaseAtLeast(counter, time.Millisecond, 1).Check(c)
+	if err := errContainsAll(err, []string{counterPath, "got 100 then 100 (+0 in 0s)", "at least 1"}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/openconfig/ygnmi/ygnmi"
+)
+
+// timeFormat is the format of the deviation times in stability errors.
+const timeFormat = "15:04:05.000"
+
+// watchable is implemented by validators that can report the result of their
+// validation for every update of their query.
+type watchable interface {
+	// watch calls fn with the time and the validation error, labelled with
+	// the path, of the current value and each update, until fn returns
+	// anything but ygnmi.Continue.
+	watch(ctx context.Context, client *ygnmi.Client, fn func(time.Time, error) error) error
+}
+
+func (vd *validation[T]) watch(ctx context.Context, client *ygnmi.Client, fn func(time.Time, error) error) error {
+	watcher := ygnmi.Watch(ctx, client, vd.query, func(v *ygnmi.Value[T]) error {
+		var err error
+		if invalid := vd.validationFn(v); invalid != nil {
+			err = &validationError[T]{query: vd.query, validationErr: invalid}
+		}
+		return fn(v.Timestamp, err)
+	})
+	_, err := watcher.Await()
+	return err
+}
+
+// pollWatch implements watch for validators that are not watchable by calling
+// Check every pollInterval.
+func pollWatch(ctx context.Context, client *ygnmi.Client, vd Validator, fn func(time.Time, error) error) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		if err := fn(time.Now(), vd.Check(client)); err != ygnmi.Continue {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// stability is the implementation of Stays and StaysFor.
+type stability struct {
+	vd Validator
+	// window is how long the validator must keep passing after it first
+	// passes.  Zero means until the end of the Await context.
+	window time.Duration
+}
+
+// Stays expects vd to pass and then keep passing until the deadline of Await,
+// AwaitFor or AwaitUntil, so that e.g. a BGP session that is established and
+// then flaps before the deadline fails.  Await with a context that has no
+// deadline never returns unless vd deviates.  Check is the same as vd.Check.
+func Stays(vd Validator) Validator {
+	return &stability{vd: vd}
+}
+
+// StaysFor expects vd to pass and then keep passing for window.  Await waits
+// for vd to first pass before the context expires, then watches it for the
+// window even beyond the context deadline.  Check expects vd to pass
+// immediately and blocks for the window.
+func StaysFor(vd Validator, window time.Duration) Validator {
+	return &stability{vd: vd, window: window}
+}
+
+// Path returns a string representation of the path being validated.
+func (s *stability) Path() string {
+	return s.vd.Path()
+}
+
+// RelPath returns a string representation of the path being validated,
+// relative to some base.
+func (s *stability) RelPath(base ygnmi.PathStruct) string {
+	return s.vd.RelPath(base)
+}
+
+// Check validates the current value, and for StaysFor keeps validating it
+// for the window.
+func (s *stability) Check(client *ygnmi.Client) error {
+	if s.window == 0 {
+		return s.vd.Check(client)
+	}
+	return s.await(context.Background(), client, true)
+}
+
+// Await waits for the validator to pass and then to keep passing.  If it
+// deviates, the error reports the time and value of the deviation.
+func (s *stability) Await(ctx context.Context, client *ygnmi.Client) error {
+	return s.await(ctx, client, false)
+}
+
+// await implements Await; if immediate is set, the first value must pass.
+func (s *stability) await(ctx context.Context, client *ygnmi.Client, immediate bool) error {
+	watch := func(ctx context.Context, fn func(time.Time, error) error) error {
+		return pollWatch(ctx, client, s.vd, fn)
+	}
+	if w, ok := s.vd.(watchable); ok {
+		watch = func(ctx context.Context, fn func(time.Time, error) error) error {
+			return w.watch(ctx, client, fn)
+		}
+	}
+	var (
+		mu          sync.Mutex
+		matched     bool
+		matchedAt   time.Time
+		held        bool
+		lastInvalid error
+		deviation   error
+	)
+	// For StaysFor, the window is timed separately from ctx, since it may
+	// extend past the deadline of ctx once the validator has passed.
+	parent := ctx
+	if s.window > 0 {
+		parent = context.Background()
+	}
+	wctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	var timer *time.Timer
+	if s.window > 0 {
+		go func() {
+			select {
+			case <-ctx.Done():
+				mu.Lock()
+				if !matched {
+					cancel()
+				}
+				mu.Unlock()
+			case <-wctx.Done():
+			}
+		}()
+		defer func() {
+			mu.Lock()
+			if timer != nil {
+				timer.Stop()
+			}
+			mu.Unlock()
+		}()
+	}
+
+	err := watch(wctx, func(at time.Time, err error) error {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case held:
+			return nil
+		case !matched && err == nil:
+			matched, matchedAt = true, at
+			if s.window > 0 {
+				timer = time.AfterFunc(s.window, func() {
+					mu.Lock()
+					held = true
+					mu.Unlock()
+					cancel()
+				})
+			}
+		case !matched:
+			lastInvalid = err
+			if immediate {
+				return nil
+			}
+		case err != nil:
+			deviation = fmt.Errorf("%w (at %s, %v after first passing at %s)", err, at.Format(timeFormat), at.Sub(matchedAt), matchedAt.Format(timeFormat))
+			return nil
+		}
+		return ygnmi.Continue
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	switch {
+	case deviation != nil:
+		return &stabilityError{deviation}
+	case held:
+		return nil
+	case !matched && lastInvalid != nil:
+		if immediate {
+			return lastInvalid
+		}
+		return fmt.Errorf("%w (deadline exceeded)", lastInvalid)
+	case !matched && ctx.Err() != nil:
+		return fmt.Errorf("%s: deadline exceeded before any values were fetched", s.Path())
+	case !matched:
+		return fmt.Errorf("%s: %w", s.Path(), err)
+	case s.window == 0 && ctx.Err() != nil:
+		return nil
+	}
+	if err == nil {
+		err = errors.New("watch ended")
+	}
+	return fmt.Errorf("%s: passed at %s, but could not watch it for the window: %w", s.Path(), matchedAt.Format(timeFormat), err)
+}
+
+// AwaitFor calls Await with a context with deadline now + timeout. If timeout
+// is <= 0, this is equivalent to Check().
+func (s *stability) AwaitFor(timeout time.Duration, client *ygnmi.Client) error {
+	return awaitFor(s, timeout, client)
+}
+
+// AwaitUntil calls Await with a context with the given deadline. If deadline
+// is in the past, this is equivalent to Check().
+func (s *stability) AwaitUntil(deadline time.Time, client *ygnmi.Client) error {
+	return awaitUntil(s, deadline, client)
+}
+
+// stabilityError is the error of a validator that passed and then deviated.
+type stabilityError struct {
+	deviation error
+}
+
+func (e *stabilityError) Error() string {
+	return e.deviation.Error()
+}
+
+func (e *stabilityError) Unwrap() error {
+	return e.deviation
+}
+
+func (e *stabilityError) validationFailed() bool {
+	return true
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package check_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/check"
+)
+
+func TestStaysAwait(t *testing.T) {
+	fakeGNMI, c := mustNewFakeGNMI(context.Background(), t)
+	defer fakeGNMI.Close()
+	query := childTwo.State()
+	ms := time.Millisecond
+	testCases := []struct {
+		desc        string
+		validator   check.Validator
+		updates     []update
+		errIncludes []string
+	}{{
+		desc:      "StaysFor/Stable",
+		validator: check.StaysFor(check.Equal(query, "up"), 20*ms),
+		updates:   []update{{"up", ms}, {"down", time.Hour}},
+	}, {
+		desc:      "StaysFor/Delayed stable",
+		validator: check.StaysFor(check.Equal(query, "up"), 20*ms),
+		updates:   []update{{"down", ms}, {"up", 5 * ms}, {"down", time.Hour}},
+	}, {
+		desc:        "StaysFor/Flaps",
+		validator:   check.StaysFor(check.Equal(query, "up"), 20*ms),
+		updates:     []update{{"up", ms}, {"down", 11 * ms}, {"up", 12 * ms}},
+		errIncludes: []string{childTwoStatePath, `got "down", want "up"`, "10ms after first passing"},
+	}, {
+		desc:        "StaysFor/Never",
+		validator:   check.StaysFor(check.Equal(query, "up"), 20*ms),
+		updates:     []update{{"down", ms}, {"up", time.Hour}},
+		errIncludes: []string{childTwoStatePath, `got "down", want "up"`, "deadline"},
+	}, {
+		desc:      "Stays/Stable",
+		validator: check.Stays(check.Equal(query, "up")),
+		updates:   []update{{"down", ms}, {"up", 5 * ms}, {"down", time.Hour}},
+	}, {
+		desc:        "Stays/Flaps",
+		validator:   check.Stays(check.Equal(query, "up")),
+		updates:     []update{{"up", ms}, {"down", 31 * ms}, {"up", time.Hour}},
+		errIncludes: []string{childTwoStatePath, `got "down", want "up"`, "30ms after first passing"},
+	}, {
+		desc:      "StaysFor/Polled",
+		validator: check.StaysFor(check.AllOf(check.Equal(query, "up")), 20*ms),
+		updates:   []update{{"up", ms}, {"down", time.Hour}},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeGNMI.stubChildTwo(tc.updates...)
+			gotErr := tc.validator.AwaitFor(50*ms, c)
+			if len(tc.errIncludes) > 0 {
+				if err := errContainsAll(gotErr, tc.errIncludes); err != nil {
+					t.Error(err)
+				}
+			} else if gotErr != nil {
+				t.Errorf("Unexpected error: %v", gotErr)
+			}
+		})
+	}
+}
+
+func TestStaysForCheck(t *testing.T) {
+	fakeGNMI, c := mustNewFakeGNMI(context.Background(), t)
+	defer fakeGNMI.Close()
+	vd := check.StaysFor(check.Equal(childTwo.State(), "up"), 20*time.Millisecond)
+
+	fakeGNMI.stubChildTwo(update{"down", time.Millisecond}, update{"up", time.Hour})
+	if err := errContainsAll(vd.Check(c), []string{childTwoStatePath, `got "down", want "up"`}); err != nil {
+		t.Error(err)
+	}
+	fakeGNMI.stubChildTwo(update{"up", time.Millisecond}, update{"down", 5 * time.Millisecond})
+	if err := errContainsAll(vd.Check(c), []string{childTwoStatePath, `got "down", want "up"`, "4ms after first passing"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStaysForWildcard(t *testing.T) {
+	fakeGNMI, c := mustNewFakeGNMI(context.Background(), t)
+	defer fakeGNMI.Close()
+	vd := check.StaysFor(check.ForAll(singleKeyValues.State(), "want positive", positive), 20*time.Millisecond)
+	fakeGNMI.stubSingleKeyValues(t,
+		keyedUpdate{"a", 1, time.Millisecond},
+		keyedUpdate{"b", 1, time.Millisecond},
+		keyedUpdate{"b", -1, 5 * time.Millisecond},
+		keyedUpdate{"b", 1, time.Hour},
+	)
+	err := vd.AwaitFor(50*time.Millisecond, c)
+	if err := errContainsAll(err, []string{singleKeyValuesPath, "-1 at /model/a/single-key[key=b]/state/value", "after first passing"}); err != nil {
+		t.Error(err)
+	}
+}
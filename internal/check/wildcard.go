@@ -0,0 +1,279 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openconfig/ygnmi/ygnmi"
+	"github.com/openconfig/ygot/ygot"
+)
+
+// FormatValues formats the present values of a wildcard query along with
+// their paths, e.g. `"UP" at /interfaces/interface[name=eth1]/state/oper-status`.
+func FormatValues[T any](vs []*ygnmi.Value[T]) string {
+	var strs []string
+	for _, v := range vs {
+		strs = append(strs, formatValueAt(v))
+	}
+	return strings.Join(strs, ", ")
+}
+
+// formatValueAt formats a value along with its path.
+func formatValueAt[T any](v *ygnmi.Value[T]) string {
+	path, err := ygot.PathToString(v.Path)
+	if err != nil {
+		path = fmt.Sprintf("<Unprintable path: %v>", err)
+	}
+	return fmt.Sprintf("%s at %s", FormatValue(v), path)
+}
+
+// wildcardValidation is the implementation of Validator for wildcard queries.
+// The validation function receives all the present values matching the
+// query, sorted by path.
+type wildcardValidation[T any] struct {
+	query        ygnmi.WildcardQuery[T]
+	validationFn func([]*ygnmi.Value[T]) error
+	// want describes the expected values, e.g. "want UP", if known.
+	want string
+}
+
+var _ Validator = (*wildcardValidation[any])(nil)
+
+// Path returns a string representation of the path being validated.
+func (vd *wildcardValidation[T]) Path() string {
+	return FormatPath(vd.query.PathStruct())
+}
+
+// RelPath returns a string representation of the path being validated,
+// relative to some base.
+func (vd *wildcardValidation[T]) RelPath(base ygnmi.PathStruct) string {
+	return FormatRelativePath(base, vd.query.PathStruct())
+}
+
+// lookup fetches all the present values matching the query.
+func (vd *wildcardValidation[T]) lookup(client *ygnmi.Client) ([]*ygnmi.Value[T], error) {
+	vals, err := ygnmi.LookupAll(context.Background(), client, vd.query)
+	if err != nil {
+		return nil, err
+	}
+	var present []*ygnmi.Value[T]
+	for _, v := range vals {
+		if v.IsPresent() {
+			present = append(present, v)
+		}
+	}
+	sortValues(present)
+	return present, nil
+}
+
+// Check tests the validation condition immediately and returns an error if it
+// fails.
+func (vd *wildcardValidation[T]) Check(client *ygnmi.Client) error {
+	vals, err := vd.lookup(client)
+	if err != nil {
+		return &validationError[T]{query: vd.query, failureCause: err}
+	}
+	if err := vd.validationFn(vals); err != nil {
+		return &validationError[T]{query: vd.query, validationErr: err}
+	}
+	return nil
+}
+
+// Await waits for the values matching the query to pass validation, keeping
+// track of every path as updates arrive.  Like the singleton Await, it always
+// fetches the current values at least once.
+func (vd *wildcardValidation[T]) Await(ctx context.Context, client *ygnmi.Client) error {
+	var lastInvalid error
+	err := vd.watch(ctx, client, func(_ time.Time, err error) error {
+		if lastInvalid = err; err != nil {
+			return ygnmi.Continue
+		}
+		return nil
+	})
+	if err != nil {
+		failed := &validationError[T]{
+			query:        vd.query,
+			failureCause: err,
+		}
+		var invalid *validationError[T]
+		if errors.As(lastInvalid, &invalid) {
+			failed.validationErr = invalid.validationErr
+		}
+		return failed
+	}
+	return nil
+}
+
+// watch fetches the current values and then watches the query, calling fn
+// with the time and the result of the validation function for the initial
+// values and each update, until fn returns anything but ygnmi.Continue.
+func (vd *wildcardValidation[T]) watch(ctx context.Context, client *ygnmi.Client, fn func(time.Time, error) error) error {
+	vals, err := vd.lookup(client)
+	if err != nil {
+		return err
+	}
+	if err := fn(time.Now(), vd.validate(vals)); err != ygnmi.Continue {
+		return err
+	}
+	// Start from the values we already have, so that a partial set of updates
+	// cannot pass validation on its own.
+	latest := make(map[string]*ygnmi.Value[T])
+	for _, v := range vals {
+		latest[pathKey(v)] = v
+	}
+	watcher := ygnmi.WatchAll(ctx, client, vd.query, func(v *ygnmi.Value[T]) error {
+		if v.IsPresent() {
+			latest[pathKey(v)] = v
+		} else {
+			delete(latest, pathKey(v))
+		}
+		var cur []*ygnmi.Value[T]
+		for _, v := range latest {
+			cur = append(cur, v)
+		}
+		sortValues(cur)
+		return fn(v.Timestamp, vd.validate(cur))
+	})
+	_, err = watcher.Await()
+	return err
+}
+
+// validate runs the validation function, labelling any error with the path.
+func (vd *wildcardValidation[T]) validate(vals []*ygnmi.Value[T]) error {
+	if err := vd.validationFn(vals); err != nil {
+		return &validationError[T]{query: vd.query, validationErr: err}
+	}
+	return nil
+}
+
+// AwaitFor calls Await with a context with deadline now + timeout. If timeout
+// is <= 0, this is equivalent to Check().
+func (vd *wildcardValidation[T]) AwaitFor(timeout time.Duration, client *ygnmi.Client) error {
+	return awaitFor(vd, timeout, client)
+}
+
+// AwaitUntil calls Await with a context with the given deadline. If deadline
+// is in the past, this is equivalent to Check().
+func (vd *wildcardValidation[T]) AwaitUntil(deadline time.Time, client *ygnmi.Client) error {
+	return awaitUntil(vd, deadline, client)
+}
+
+// awaitFor implements Validator.AwaitFor in terms of Check and Await.
+func awaitFor(vd Validator, timeout time.Duration, client *ygnmi.Client) error {
+	if timeout <= 0 {
+		return vd.Check(client)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return vd.Await(ctx, client)
+}
+
+// awaitUntil implements Validator.AwaitUntil in terms of Check and Await.
+func awaitUntil(vd Validator, deadline time.Time, client *ygnmi.Client) error {
+	if deadline.Before(time.Now()) {
+		return vd.Check(client)
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	return vd.Await(ctx, client)
+}
+
+func pathKey[T any](v *ygnmi.Value[T]) string {
+	s, err := ygot.PathToString(v.Path)
+	if err != nil {
+		return v.Path.String()
+	}
+	return s
+}
+
+func sortValues[T any](vs []*ygnmi.Value[T]) {
+	sort.Slice(vs, func(i, j int) bool {
+		return pathKey(vs[i]) < pathKey(vs[j])
+	})
+}
+
+// ValidateAll expects validationFn to return no error on the present values
+// of the wildcard query, sorted by path.
+func ValidateAll[T any, QT ygnmi.WildcardQuery[T]](query QT, validationFn func([]*ygnmi.Value[T]) error) Validator {
+	return &wildcardValidation[T]{query: query, validationFn: validationFn}
+}
+
+// ForAll expects the wildcard query to have at least one value, and the
+// predicate to return true on every value.  The error lists each value that
+// fails, e.g.
+//
+//	"/interfaces/interface[name=*]/state/oper-status: got DOWN at
+//	/interfaces/interface[name=eth1]/state/oper-status, want UP".
+func ForAll[T any, QT ygnmi.WildcardQuery[T]](query QT, wantMsg string, predicate func(T) bool) Validator {
+	return &wildcardValidation[T]{query: query, want: wantMsg, validationFn: func(vs []*ygnmi.Value[T]) error {
+		if len(vs) == 0 {
+			return fmt.Errorf("got no values, %s", wantMsg)
+		}
+		var failed []*ygnmi.Value[T]
+		for _, v := range vs {
+			if got, _ := v.Val(); !predicate(got) {
+				failed = append(failed, v)
+			}
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("got %s, %s", FormatValues(failed), wantMsg)
+		}
+		return nil
+	}}
+}
+
+// Exists expects the predicate to return true on at least one value of the
+// wildcard query.
+func Exists[T any, QT ygnmi.WildcardQuery[T]](query QT, wantMsg string, predicate func(T) bool) Validator {
+	return &wildcardValidation[T]{query: query, want: wantMsg, validationFn: func(vs []*ygnmi.Value[T]) error {
+		for _, v := range vs {
+			if got, _ := v.Val(); predicate(got) {
+				return nil
+			}
+		}
+		if len(vs) == 0 {
+			return fmt.Errorf("got no values, %s", wantMsg)
+		}
+		return fmt.Errorf("got %s, %s", FormatValues(vs), wantMsg)
+	}}
+}
+
+// CountEquals expects the wildcard query to have exactly n values.
+func CountEquals[T any, QT ygnmi.WildcardQuery[T]](query QT, n int) Validator {
+	want := fmt.Sprintf("want exactly %d values", n)
+	return &wildcardValidation[T]{query: query, want: want, validationFn: func(vs []*ygnmi.Value[T]) error {
+		if len(vs) != n {
+			return fmt.Errorf("got %d values, want exactly %d", len(vs), n)
+		}
+		return nil
+	}}
+}
+
+// CountAtLeast expects the wildcard query to have at least n values.
+func CountAtLeast[T any, QT ygnmi.WildcardQuery[T]](query QT, n int) Validator {
+	want := fmt.Sprintf("want at least %d values", n)
+	return &wildcardValidation[T]{query: query, want: want, validationFn: func(vs []*ygnmi.Value[T]) error {
+		if len(vs) < n {
+			return fmt.Errorf("got %d values, want at least %d", len(vs), n)
+		}
+		return nil
+	}}
+}
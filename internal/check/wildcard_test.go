@@ -0,0 +1,219 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package check_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/check"
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/ygnmi/exampleoc/exampleocpath"
+	"github.com/openconfig/ygot/ygot"
+)
+
+var (
+	singleKeyValues     = exampleocpath.Root().Model().SingleKeyAny().Value()
+	singleKeyValuesPath = "/model/a/single-key[key=*]/state/value"
+)
+
+// keyedUpdate is an update to the value of a single-key list entry, delivered
+// after delay.
+type keyedUpdate struct {
+	key   string
+	value int64
+	delay time.Duration
+}
+
+// stubSingleKeyValues clears the fakeGNMI's stub and populates it with updates
+// to /model/a/single-key[key=*]/state/value.  Consecutive updates with the
+// same delay are sent in one notification, followed by a sync response.
+func (fg *fakeGNMI) stubSingleKeyValues(t *testing.T, updates ...keyedUpdate) {
+	t.Helper()
+	fg.gen.Reset()
+	var notif *gpb.Notification
+	for i, u := range updates {
+		path, err := ygot.StringToStructuredPath(fmt.Sprintf("/model/a/single-key[key=%s]/state/value", u.key))
+		if err != nil {
+			t.Fatalf("Parsing path for key %q: %v", u.key, err)
+		}
+		if notif == nil {
+			notif = &gpb.Notification{Timestamp: int64(u.delay)}
+		}
+		notif.Update = append(notif.Update, &gpb.Update{
+			Path: path,
+			Val:  &gpb.TypedValue{Value: &gpb.TypedValue_IntVal{IntVal: u.value}},
+		})
+		if i+1 < len(updates) && updates[i+1].delay == u.delay {
+			continue
+		}
+		fg.gen.Responses = append(fg.gen.Responses, &gpb.SubscribeResponse{
+			Response: &gpb.SubscribeResponse_Update{Update: notif},
+		}, &gpb.SubscribeResponse{
+			Response: &gpb.SubscribeResponse_SyncResponse{SyncResponse: true},
+		})
+		notif = nil
+	}
+	initResponses(fg.gen.Responses)
+}
+
+func positive(v int64) bool { return v > 0 }
+
+func TestWildcardCheck(t *testing.T) {
+	fakeGNMI, c := mustNewFakeGNMI(context.Background(), t)
+	defer fakeGNMI.Close()
+	query := singleKeyValues.State()
+	allPositive := []keyedUpdate{{"a", 1, 0}, {"b", 2, 0}, {"c", 3, 0}}
+	oneNegative := []keyedUpdate{{"a", 1, 0}, {"b", -2, 0}, {"c", 3, 0}}
+	testCases := []struct {
+		desc        string
+		validator   check.Validator
+		updates     []keyedUpdate
+		errIncludes []string
+	}{{
+		desc:      "ForAll/Correct",
+		validator: check.ForAll(query, "want positive", positive),
+		updates:   allPositive,
+	}, {
+		desc:        "ForAll/Incorrect",
+		validator:   check.ForAll(query, "want positive", positive),
+		updates:     oneNegative,
+		errIncludes: []string{singleKeyValuesPath, "-2 at /model/a/single-key[key=b]/state/value", "want positive"},
+	}, {
+		desc:        "ForAll/Missing",
+		validator:   check.ForAll(query, "want positive", positive),
+		errIncludes: []string{singleKeyValuesPath, "got no values", "want positive"},
+	}, {
+		desc:      "Exists/Correct",
+		validator: check.Exists(query, "want negative", func(v int64) bool { return v < 0 }),
+		updates:   oneNegative,
+	}, {
+		desc:        "Exists/Incorrect",
+		validator:   check.Exists(query, "want negative", func(v int64) bool { return v < 0 }),
+		updates:     allPositive,
+		errIncludes: []string{singleKeyValuesPath, "1 at /model/a/single-key[key=a]/state/value", "want negative"},
+	}, {
+		desc:      "CountEquals/Correct",
+		validator: check.CountEquals(query, 3),
+		updates:   allPositive,
+	}, {
+		desc:        "CountEquals/Incorrect",
+		validator:   check.CountEquals(query, 2),
+		updates:     allPositive,
+		errIncludes: []string{singleKeyValuesPath, "got 3 values, want exactly 2"},
+	}, {
+		desc:      "CountAtLeast/Correct",
+		validator: check.CountAtLeast(query, 2),
+		updates:   allPositive,
+	}, {
+		desc:        "CountAtLeast/Incorrect",
+		validator:   check.CountAtLeast(query, 4),
+		updates:     allPositive,
+		errIncludes: []string{singleKeyValuesPath, "got 3 values, want at least 4"},
+	}, {
+		desc:      "AllOf/Correct",
+		validator: check.AllOf(check.CountEquals(query, 3), check.ForAll(query, "want positive", positive)),
+		updates:   allPositive,
+	}, {
+		desc:        "AllOf/Incorrect",
+		validator:   check.AllOf(check.CountEquals(query, 3), check.ForAll(query, "want positive", positive)),
+		updates:     oneNegative,
+		errIncludes: []string{"AllOf(", singleKeyValuesPath, "want positive"},
+	}, {
+		desc:      "AnyOf/Correct",
+		validator: check.AnyOf(check.CountEquals(query, 2), check.ForAll(query, "want positive", positive)),
+		updates:   allPositive,
+	}, {
+		desc:        "AnyOf/Incorrect",
+		validator:   check.AnyOf(check.CountEquals(query, 2), check.ForAll(query, "want positive", positive)),
+		updates:     oneNegative,
+		errIncludes: []string{"AnyOf(", "want exactly 2", "want positive"},
+	}, {
+		desc:      "Not/Correct",
+		validator: check.Not(check.ForAll(query, "want positive", positive)),
+		updates:   oneNegative,
+	}, {
+		desc:        "Not/Incorrect",
+		validator:   check.Not(check.CountAtLeast(query, 2)),
+		updates:     allPositive,
+		errIncludes: []string{singleKeyValuesPath, "fail the negated check (want at least 2 values)"},
+	}, {
+		desc:      "Not/AllOf",
+		validator: check.Not(check.AllOf(check.CountEquals(query, 3), check.ForAll(query, "want positive", positive))),
+		updates:   oneNegative,
+	}, {
+		desc:      "Not/Not",
+		validator: check.Not(check.Not(check.CountEquals(query, 3))),
+		updates:   allPositive,
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeGNMI.stubSingleKeyValues(t, tc.updates...)
+			gotErr := tc.validator.Check(c)
+			if len(tc.errIncludes) > 0 {
+				if err := errContainsAll(gotErr, tc.errIncludes); err != nil {
+					t.Error(err)
+				}
+			} else if gotErr != nil {
+				t.Errorf("Unexpected error: %v", gotErr)
+			}
+		})
+	}
+}
+
+func TestWildcardAwait(t *testing.T) {
+	fakeGNMI, c := mustNewFakeGNMI(context.Background(), t)
+	defer fakeGNMI.Close()
+	query := singleKeyValues.State()
+	testCases := []struct {
+		desc        string
+		validator   check.Validator
+		updates     []keyedUpdate
+		errIncludes []string
+	}{{
+		desc:      "Delayed correct",
+		validator: check.ForAll(query, "want positive", positive),
+		updates:   []keyedUpdate{{"a", 1, 0}, {"b", -2, 0}, {"b", 2, 1}},
+	}, {
+		desc:        "Too slow",
+		validator:   check.ForAll(query, "want positive", positive),
+		updates:     []keyedUpdate{{"a", 1, 0}, {"b", -2, 0}, {"b", 2, time.Hour}},
+		errIncludes: []string{singleKeyValuesPath, "-2", "want positive", "deadline"},
+	}, {
+		desc:      "AnyOf/Delayed correct",
+		validator: check.AnyOf(check.CountEquals(query, 5), check.ForAll(query, "want positive", positive)),
+		updates:   []keyedUpdate{{"a", 1, 0}, {"b", -2, 0}, {"b", 2, 1}},
+	}, {
+		desc:        "AllOf/Too slow",
+		validator:   check.AllOf(check.CountEquals(query, 2), check.ForAll(query, "want positive", positive)),
+		updates:     []keyedUpdate{{"a", 1, 0}, {"b", -2, 0}, {"b", 2, time.Hour}},
+		errIncludes: []string{"AllOf(", "-2", "deadline"},
+	}}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeGNMI.stubSingleKeyValues(t, tc.updates...)
+			gotErr := tc.validator.AwaitFor(time.Millisecond*50, c)
+			if len(tc.errIncludes) > 0 {
+				if err := errContainsAll(gotErr, tc.errIncludes); err != nil {
+					t.Error(err)
+				}
+			} else if gotErr != nil {
+				t.Errorf("Unexpected error: %v", gotErr)
+			}
+		})
+	}
+}
@@ -17,8 +17,16 @@ package components
 import (
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/ondatra/gnmi/oc"
+	"github.com/openconfig/ygot/ygot"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	spb "github.com/openconfig/gnoi/system"
+	tpb "github.com/openconfig/gnoi/types"
 )
 
 func TestFindMatchingStrings(t *testing.T) {
@@ -41,3 +49,123 @@ func TestFindMatchingStrings(t *testing.T) {
 		t.Errorf("FindMatchingStrings(%s) returned unexpected diff (-want +got):\n%s", args, diff)
 	}
 }
+
+func TestTree(t *testing.T) {
+	component := func(name, parent string, typ oc.Component_Type_Union, role oc.E_Platform_ComponentRedundantRole) *oc.Component {
+		c := &oc.Component{Name: ygot.String(name), Type: typ, RedundantRole: role, OperStatus: oc.PlatformTypes_COMPONENT_OPER_STATUS_ACTIVE}
+		if parent != "" {
+			c.Parent = ygot.String(parent)
+		}
+		return c
+	}
+	chassis := component("Chassis", "", oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_CHASSIS, oc.Platform_ComponentRedundantRole_UNSET)
+	// Linecard1 is only known to be in the chassis from its subcomponents.
+	chassis.GetOrCreateSubcomponent("Linecard1")
+	lc2 := component("Linecard2", "Chassis", oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_LINECARD, oc.Platform_ComponentRedundantRole_UNSET)
+	lc2.OperStatus = oc.PlatformTypes_COMPONENT_OPER_STATUS_INACTIVE
+	tr := NewTree([]*oc.Component{
+		component("Supervisor2", "Chassis", oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_CONTROLLER_CARD, oc.Platform_ComponentRedundantRole_SECONDARY),
+		component("Supervisor1", "Chassis", oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_CONTROLLER_CARD, oc.Platform_ComponentRedundantRole_PRIMARY),
+		chassis,
+		component("Linecard1", "", oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_LINECARD, oc.Platform_ComponentRedundantRole_UNSET),
+		lc2,
+		component("CPU0", "Linecard1", oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_CPU, oc.Platform_ComponentRedundantRole_UNSET),
+		component("Orphan", "Unknown", oc.PlatformTypes_OPENCONFIG_SOFTWARE_COMPONENT_OPERATING_SYSTEM, oc.Platform_ComponentRedundantRole_UNSET),
+		// A and B are each other's parent, which is broken at B.
+		component("A", "B", nil, oc.Platform_ComponentRedundantRole_UNSET),
+		component("B", "A", nil, oc.Platform_ComponentRedundantRole_UNSET),
+	})
+
+	var roots []string
+	for _, r := range tr.Roots() {
+		roots = append(roots, r.GetName())
+	}
+	if diff := cmp.Diff([]string{"B", "Chassis", "Orphan"}, roots); diff != "" {
+		t.Errorf("Roots() unexpected diff (-want +got):\n%s", diff)
+	}
+
+	tests := []struct {
+		desc string
+		q    Query
+		want []string
+	}{{
+		desc: "type",
+		q:    Query{Type: oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_LINECARD},
+		want: []string{"Linecard1", "Linecard2"},
+	}, {
+		desc: "parent",
+		q:    Query{Parent: "Chassis"},
+		want: []string{"Linecard1", "Linecard2", "Supervisor1", "Supervisor2"},
+	}, {
+		desc: "ancestor",
+		q:    Query{Ancestor: "Chassis", Type: oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_CPU},
+		want: []string{"CPU0"},
+	}, {
+		desc: "redundant role",
+		q:    Query{RedundantRole: oc.Platform_ComponentRedundantRole_SECONDARY},
+		want: []string{"Supervisor2"},
+	}, {
+		desc: "oper status and name",
+		q:    Query{OperStatus: oc.PlatformTypes_COMPONENT_OPER_STATUS_ACTIVE, Name: regexp.MustCompile(`^Linecard`)},
+		want: []string{"Linecard1"},
+	}}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, tr.FindNames(tc.q)); diff != "" {
+				t.Errorf("FindNames(%+v) unexpected diff (-want +got):\n%s", tc.q, diff)
+			}
+		})
+	}
+
+	active, standby, err := tr.Supervisors()
+	if err != nil || active != "Supervisor1" || standby != "Supervisor2" {
+		t.Errorf("Supervisors() got %q, %q, %v, want Supervisor1, Supervisor2", active, standby, err)
+	}
+
+	wantInventory := `B (oper-status=ACTIVE)
+  A (oper-status=ACTIVE)
+Chassis (type=CHASSIS, oper-status=ACTIVE)
+  Linecard1 (type=LINECARD, oper-status=ACTIVE)
+    CPU0 (type=CPU, oper-status=ACTIVE)
+  Linecard2 (type=LINECARD, oper-status=INACTIVE)
+  Supervisor1 (type=CONTROLLER_CARD, oper-status=ACTIVE, redundant-role=PRIMARY)
+  Supervisor2 (type=CONTROLLER_CARD, oper-status=ACTIVE, redundant-role=SECONDARY)
+Orphan (type=OPERATING_SYSTEM, oper-status=ACTIVE)
+`
+	if diff := cmp.Diff(wantInventory, tr.String()); diff != "" {
+		t.Errorf("String() unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestRebootRequests(t *testing.T) {
+	defer func(path, empty bool) {
+		*deviations.GNOISubcomponentPath = path
+		*deviations.GNOIStatusWithEmptySubcomponent = empty
+	}(*deviations.GNOISubcomponentPath, *deviations.GNOIStatusWithEmptySubcomponent)
+
+	for _, path := range []bool{false, true} {
+		*deviations.GNOISubcomponentPath = path
+		req := rebootRequest([]string{"Linecard1"}, &RebootOptions{Delay: time.Minute, Message: "test"})
+		want := &spb.RebootRequest{
+			Method:        spb.RebootMethod_COLD,
+			Delay:         uint64(time.Minute.Nanoseconds()),
+			Message:       "test",
+			Subcomponents: []*tpb.Path{GetSubcomponentPath("Linecard1")},
+		}
+		if diff := cmp.Diff(want, req, protocmp.Transform()); diff != "" {
+			t.Errorf("rebootRequest() with deviation_gnoi_subcomponent_path=%v unexpected diff (-want +got):\n%s", path, diff)
+		}
+		if got := pathName(req.GetSubcomponents()[0]); got != "Linecard1" {
+			t.Errorf("pathName(%v) got %q, want Linecard1", req.GetSubcomponents()[0], got)
+		}
+	}
+
+	*deviations.GNOIStatusWithEmptySubcomponent = false
+	if got := len(rebootStatusRequest("Linecard1").GetSubcomponents()); got != 1 {
+		t.Errorf("rebootStatusRequest() got %d subcomponents, want 1", got)
+	}
+	*deviations.GNOIStatusWithEmptySubcomponent = true
+	if got := len(rebootStatusRequest("Linecard1").GetSubcomponents()); got != 0 {
+		t.Errorf("rebootStatusRequest() with deviation_gnoi_status_empty_subcomponent got %d subcomponents, want 0", got)
+	}
+}
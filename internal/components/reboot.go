@@ -0,0 +1,375 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package components
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/gnmi"
+	"github.com/openconfig/ondatra/gnmi/oc"
+	"github.com/openconfig/testt"
+	"github.com/openconfig/ygnmi/ygnmi"
+
+	spb "github.com/openconfig/gnoi/system"
+	tpb "github.com/openconfig/gnoi/types"
+)
+
+const (
+	// defaultRebootTimeout is the default time for the device or component to
+	// return after a reboot or switchover.
+	defaultRebootTimeout = 15 * time.Minute
+	// defaultRebootPollInterval is how often the device is polled while it
+	// reboots.
+	defaultRebootPollInterval = 30 * time.Second
+	// postConditionTimeout is the time for the post conditions to hold once
+	// the device or component has returned.
+	postConditionTimeout = 10 * time.Minute
+)
+
+// RebootOptions are the parameters of RebootChassis, RebootComponent and
+// Switchover.
+type RebootOptions struct {
+	// Method is the reboot method.  Zero means COLD.
+	Method spb.RebootMethod
+	// Delay delays the reboot of the chassis.
+	Delay time.Duration
+	// Message is the reason for the reboot.
+	Message string
+	// Force forces the reboot of the chassis.
+	Force bool
+	// Timeout is the time for the device or component to return.  Zero means
+	// 15 minutes.
+	Timeout time.Duration
+	// PollInterval is how often the device is polled.  Zero means 30 seconds.
+	PollInterval time.Duration
+
+	// Post conditions that are verified once the device or component has
+	// returned.
+	//
+	// InterfacesUp verifies that the interfaces that were operationally up
+	// before are up again.
+	InterfacesUp bool
+	// ComponentsActive verifies that the components that were active before
+	// are active again.
+	ComponentsActive bool
+}
+
+func (o *RebootOptions) timeout() time.Duration {
+	if o.Timeout > 0 {
+		return o.Timeout
+	}
+	return defaultRebootTimeout
+}
+
+func (o *RebootOptions) pollInterval() time.Duration {
+	if o.PollInterval > 0 {
+		return o.PollInterval
+	}
+	return defaultRebootPollInterval
+}
+
+// RebootReport reports the timings of a reboot or switchover.
+type RebootReport struct {
+	// Operation is "reboot" or "switchover".
+	Operation string `json:"operation"`
+	// Target is the rebooted component or "chassis", or the new active
+	// controller card of a switchover.
+	Target string `json:"target"`
+	// RPC is the time for the gNOI RPC to return.
+	RPC time.Duration `json:"rpc_ns"`
+	// Recovery is the time from sending the RPC until the device or
+	// component returned.
+	Recovery time.Duration `json:"recovery_ns"`
+	// PostConditions is the time from the return of the device or component
+	// until the post conditions held.
+	PostConditions time.Duration `json:"post_conditions_ns"`
+}
+
+func (r *RebootReport) String() string {
+	return fmt.Sprintf("%s of %s: RPC returned after %v, recovered after %v, post conditions held after another %v",
+		r.Operation, r.Target, r.RPC, r.Recovery, r.PostConditions)
+}
+
+// rebootRequest returns the request to reboot the named components, or the
+// chassis if there are none.
+func rebootRequest(names []string, opts *RebootOptions) *spb.RebootRequest {
+	req := &spb.RebootRequest{
+		Method:  opts.Method,
+		Delay:   uint64(opts.Delay.Nanoseconds()),
+		Message: opts.Message,
+		Force:   opts.Force,
+	}
+	if req.Method == spb.RebootMethod_UNKNOWN {
+		req.Method = spb.RebootMethod_COLD
+	}
+	for _, name := range names {
+		req.Subcomponents = append(req.Subcomponents, GetSubcomponentPath(name))
+	}
+	return req
+}
+
+// rebootStatusRequest returns the request for the reboot status of the named
+// component, which devices with the GNOIStatusWithEmptySubcomponent deviation
+// do not accept.
+func rebootStatusRequest(name string) *spb.RebootStatusRequest {
+	if *deviations.GNOIStatusWithEmptySubcomponent {
+		return &spb.RebootStatusRequest{Subcomponents: []*tpb.Path{}}
+	}
+	return &spb.RebootStatusRequest{Subcomponents: []*tpb.Path{GetSubcomponentPath(name)}}
+}
+
+// preConditions records the state of the DUT that the post conditions are
+// verified against.
+type preConditions struct {
+	upInterfaces     []string
+	activeComponents []string
+}
+
+func recordPreConditions(t testing.TB, dut *ondatra.DUTDevice, opts *RebootOptions) *preConditions {
+	t.Helper()
+	pre := &preConditions{}
+	if opts.InterfacesUp {
+		for _, intf := range gnmi.GetAll(t, dut, gnmi.OC().InterfaceAny().State()) {
+			if intf.GetOperStatus() == oc.Interface_OperStatus_UP {
+				pre.upInterfaces = append(pre.upInterfaces, intf.GetName())
+			}
+		}
+		sort.Strings(pre.upInterfaces)
+		t.Logf("Interfaces up before the reboot: %v", pre.upInterfaces)
+	}
+	if opts.ComponentsActive {
+		pre.activeComponents = GetTree(t, dut).FindNames(Query{OperStatus: oc.PlatformTypes_COMPONENT_OPER_STATUS_ACTIVE})
+	}
+	return pre
+}
+
+// verify waits for the post conditions to hold.
+func (pre *preConditions) verify(t testing.TB, dut *ondatra.DUTDevice) {
+	t.Helper()
+	if len(pre.upInterfaces) > 0 {
+		batch := gnmi.OCBatch()
+		for _, intf := range pre.upInterfaces {
+			batch.AddPaths(gnmi.OC().Interface(intf).OperStatus())
+		}
+		watch := gnmi.Watch(t, dut, batch.State(), postConditionTimeout, func(val *ygnmi.Value[*oc.Root]) bool {
+			root, present := val.Val()
+			if !present {
+				return false
+			}
+			for _, intf := range pre.upInterfaces {
+				if root.GetInterface(intf).GetOperStatus() != oc.Interface_OperStatus_UP {
+					return false
+				}
+			}
+			return true
+		})
+		if _, ok := watch.Await(t); !ok {
+			t.Fatalf("Interfaces %v did not come back up within %v", pre.upInterfaces, postConditionTimeout)
+		}
+	}
+	if len(pre.activeComponents) > 0 {
+		deadline := time.Now().Add(postConditionTimeout)
+		for {
+			tr := GetTree(t, dut)
+			var inactive []string
+			for _, name := range pre.activeComponents {
+				if n := tr.Node(name); n == nil || n.GetOperStatus() != oc.PlatformTypes_COMPONENT_OPER_STATUS_ACTIVE {
+					inactive = append(inactive, name)
+				}
+			}
+			if len(inactive) == 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Components %v did not become active again within %v", inactive, postConditionTimeout)
+			}
+			time.Sleep(10 * time.Second)
+		}
+	}
+}
+
+// awaitReachable waits for wait, then polls the current time of the DUT every
+// interval until it responds, and returns false if it does not before the
+// deadline.
+func awaitReachable(t testing.TB, dut *ondatra.DUTDevice, wait time.Duration, deadline time.Time, interval time.Duration) bool {
+	t.Helper()
+	time.Sleep(wait)
+	for {
+		var now string
+		if errMsg := testt.CaptureFatal(t, func(t testing.TB) {
+			now = gnmi.Get(t, dut, gnmi.OC().System().CurrentDatetime().State())
+		}); errMsg == nil {
+			t.Logf("DUT is reachable with current time %v", now)
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		t.Logf("DUT is not reachable yet, keep polling ...")
+		time.Sleep(interval)
+	}
+}
+
+// RebootChassis reboots the DUT, waits for it to return and verifies the
+// post conditions.  The boot time of the DUT must have increased.
+func RebootChassis(t testing.TB, dut *ondatra.DUTDevice, opts *RebootOptions) *RebootReport {
+	t.Helper()
+	if opts == nil {
+		opts = &RebootOptions{}
+	}
+	pre := recordPreConditions(t, dut, opts)
+	bootTime := gnmi.Get(t, dut, gnmi.OC().System().BootTime().State())
+
+	req := rebootRequest(nil, opts)
+	t.Logf("Send reboot request: %v", req)
+	start := time.Now()
+	if _, err := dut.RawAPIs().GNOI().New(t).System().Reboot(context.Background(), req); err != nil {
+		t.Fatalf("Failed to reboot chassis with unexpected err: %v", err)
+	}
+	report := &RebootReport{Operation: "reboot", Target: "chassis", RPC: time.Since(start)}
+
+	// The DUT is first polled one interval after the delayed reboot, when it
+	// is expected to be down.
+	if !awaitReachable(t, dut, opts.Delay+opts.pollInterval(), start.Add(opts.Delay+opts.timeout()), opts.pollInterval()) {
+		t.Fatalf("DUT did not return within %v of the reboot", opts.Delay+opts.timeout())
+	}
+	report.Recovery = time.Since(start)
+	if got := gnmi.Get(t, dut, gnmi.OC().System().BootTime().State()); got <= bootTime {
+		t.Errorf("Boot time after reboot got %v, want > %v", got, bootTime)
+	}
+
+	recovered := time.Now()
+	pre.verify(t, dut)
+	report.PostConditions = time.Since(recovered)
+	t.Log(report)
+	return report
+}
+
+// RebootComponent reboots the named component, such as a linecard or the
+// standby controller card, waits for the reboot to complete and for the
+// component to be active again, and verifies the post conditions.
+func RebootComponent(t testing.TB, dut *ondatra.DUTDevice, name string, opts *RebootOptions) *RebootReport {
+	t.Helper()
+	if opts == nil {
+		opts = &RebootOptions{}
+	}
+	pre := recordPreConditions(t, dut, opts)
+
+	gnoiClient := dut.RawAPIs().GNOI().New(t)
+	req := rebootRequest([]string{name}, opts)
+	t.Logf("Send reboot request: %v", req)
+	start := time.Now()
+	if _, err := gnoiClient.System().Reboot(context.Background(), req); err != nil {
+		t.Fatalf("Failed to reboot component %s with unexpected err: %v", name, err)
+	}
+	report := &RebootReport{Operation: "reboot", Target: name, RPC: time.Since(start)}
+
+	deadline := start.Add(opts.timeout())
+	statusReq := rebootStatusRequest(name)
+	for {
+		time.Sleep(opts.pollInterval())
+		resp, err := gnoiClient.System().RebootStatus(context.Background(), statusReq)
+		if err == nil && !resp.GetActive() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Reboot of component %s is still active after %v: status %v, err %v", name, opts.timeout(), resp, err)
+		}
+	}
+	gnmi.Await(t, dut, gnmi.OC().Component(name).OperStatus().State(), time.Until(deadline), oc.PlatformTypes_COMPONENT_OPER_STATUS_ACTIVE)
+	report.Recovery = time.Since(start)
+
+	recovered := time.Now()
+	pre.verify(t, dut)
+	report.PostConditions = time.Since(recovered)
+	t.Log(report)
+	return report
+}
+
+// Switchover switches the control processor of the DUT over to the standby
+// controller card, waits for the DUT to return and for the redundant roles of
+// the controller cards to be swapped, and verifies the post conditions.  It
+// skips the test unless the DUT has an active and a standby controller card.
+func Switchover(t testing.TB, dut *ondatra.DUTDevice, opts *RebootOptions) *RebootReport {
+	t.Helper()
+	if opts == nil {
+		opts = &RebootOptions{}
+	}
+	active, standby, err := GetTree(t, dut).Supervisors()
+	if err != nil {
+		t.Skipf("Switchover requires dual controller cards on %v: %v", dut.Model(), err)
+	}
+	t.Logf("Detected active controller card %s and standby %s", active, standby)
+	gnmi.Await(t, dut, gnmi.OC().Component(active).SwitchoverReady().State(), opts.timeout(), true)
+	pre := recordPreConditions(t, dut, opts)
+
+	req := &spb.SwitchControlProcessorRequest{ControlProcessor: GetSubcomponentPath(standby)}
+	t.Logf("Send switchover request: %v", req)
+	start := time.Now()
+	resp, err := dut.RawAPIs().GNOI().New(t).System().SwitchControlProcessor(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Failed to switch over to %s with unexpected err: %v", standby, err)
+	}
+	report := &RebootReport{Operation: "switchover", Target: standby, RPC: time.Since(start)}
+	if got := pathName(resp.GetControlProcessor()); got != standby {
+		t.Errorf("Switchover response got control processor %q, want %q", got, standby)
+	}
+
+	deadline := start.Add(opts.timeout())
+	if !awaitReachable(t, dut, opts.pollInterval(), deadline, opts.pollInterval()) {
+		t.Fatalf("DUT did not return within %v of the switchover", opts.timeout())
+	}
+	for {
+		var gotActive, gotStandby string
+		var err error
+		if errMsg := testt.CaptureFatal(t, func(t testing.TB) {
+			gotActive, gotStandby, err = GetTree(t, dut).Supervisors()
+		}); errMsg == nil && err == nil && gotActive == standby && gotStandby == active {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Controller cards got active %q and standby %q after the switchover, want %q and %q (err: %v)", gotActive, gotStandby, standby, active, err)
+		}
+		time.Sleep(opts.pollInterval())
+	}
+	report.Recovery = time.Since(start)
+
+	recovered := time.Now()
+	pre.verify(t, dut)
+	report.PostConditions = time.Since(recovered)
+	t.Log(report)
+	return report
+}
+
+// pathName returns the name of the component of a gNOI path, which is either
+// the name key of the last element or, with the GNOISubcomponentPath
+// deviation, its name.
+func pathName(p *tpb.Path) string {
+	elems := p.GetElem()
+	if len(elems) == 0 {
+		return ""
+	}
+	last := elems[len(elems)-1]
+	if name, ok := last.GetKey()["name"]; ok {
+		return name
+	}
+	return last.GetName()
+}
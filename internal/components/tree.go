@@ -0,0 +1,340 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package components
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/gnmi"
+	"github.com/openconfig/ondatra/gnmi/oc"
+	"github.com/openconfig/ondatra/gnmi/oc/ocpath"
+	"github.com/openconfig/ygnmi/ygnmi"
+)
+
+// Tree is the hierarchy of the components of a device, as reported by their
+// parent leaves and subcomponent lists.
+type Tree struct {
+	roots  []*Node
+	byName map[string]*Node
+}
+
+// Node is a component in a Tree.
+type Node struct {
+	*oc.Component
+	// Parent is nil for the roots of the tree.  It shadows the parent leaf of
+	// the component, which is available as GetParent().
+	Parent   *Node
+	Children []*Node
+}
+
+// GetTree builds the component tree of the DUT from a single Get of all
+// components.
+func GetTree(t testing.TB, dut *ondatra.DUTDevice) *Tree {
+	t.Helper()
+	return NewTree(gnmi.GetAll(t, dut, gnmi.OC().ComponentAny().State()))
+}
+
+// Tree builds the component tree of the device from a single lookup of all
+// components.
+func (y Y) Tree(ctx context.Context) (*Tree, error) {
+	values, err := ygnmi.LookupAll(ctx, y.Client, ocpath.Root().ComponentAny().State())
+	if err != nil {
+		return nil, err
+	}
+	var components []*oc.Component
+	for _, v := range values {
+		if c, ok := v.Val(); ok {
+			components = append(components, c)
+		}
+	}
+	return NewTree(components), nil
+}
+
+// NewTree builds a component tree.  A component whose parent is unknown is a
+// root of the tree.  Children are ordered by name.
+func NewTree(components []*oc.Component) *Tree {
+	tr := &Tree{byName: make(map[string]*Node)}
+	for _, c := range components {
+		if c.GetName() != "" {
+			tr.byName[c.GetName()] = &Node{Component: c}
+		}
+	}
+	parents := make(map[string]string)
+	for name, n := range tr.byName {
+		for sub := range n.Subcomponent {
+			if _, ok := tr.byName[sub]; ok && sub != name {
+				parents[sub] = name
+			}
+		}
+	}
+	// The parent leaf takes precedence over the subcomponent lists.
+	for name, n := range tr.byName {
+		if p := n.GetParent(); p != "" && p != name {
+			if _, ok := tr.byName[p]; ok {
+				parents[name] = p
+			}
+		}
+	}
+	for _, name := range sortedNames(tr.byName) {
+		n := tr.byName[name]
+		p, ok := parents[name]
+		if !ok || tr.isAncestor(n, tr.byName[p]) {
+			tr.roots = append(tr.roots, n)
+			continue
+		}
+		n.Parent = tr.byName[p]
+		n.Parent.Children = append(n.Parent.Children, n)
+	}
+	return tr
+}
+
+// isAncestor returns whether a is an ancestor of, or is, n, so that making a
+// the child of n would create a cycle.
+func (tr *Tree) isAncestor(a, n *Node) bool {
+	for ; n != nil; n = n.Parent {
+		if n == a {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedNames(m map[string]*Node) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Node returns the named component, or nil if there is none.
+func (tr *Tree) Node(name string) *Node {
+	return tr.byName[name]
+}
+
+// Roots returns the components without a parent, ordered by name.
+func (tr *Tree) Roots() []*Node {
+	return tr.roots
+}
+
+// Len returns the number of components in the tree.
+func (tr *Tree) Len() int {
+	return len(tr.byName)
+}
+
+// TypeName returns the name of the type of the component, e.g. LINECARD, or
+// empty if it is unset.
+func (n *Node) TypeName() string {
+	if n.GetType() == nil {
+		return ""
+	}
+	return fmt.Sprint(n.GetType())
+}
+
+// Walk calls fn on the node and its descendants, depth-first in order.
+func (n *Node) Walk(fn func(*Node)) {
+	fn(n)
+	for _, c := range n.Children {
+		c.Walk(fn)
+	}
+}
+
+// Depth returns the number of ancestors of the node.
+func (n *Node) Depth() int {
+	d := 0
+	for p := n.Parent; p != nil; p = p.Parent {
+		d++
+	}
+	return d
+}
+
+// Query selects components of a Tree.  Unset fields match any component.
+type Query struct {
+	// Type is the type of the components, e.g.
+	// oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_LINECARD.
+	Type oc.Component_Type_Union
+	// Parent is the name of the parent of the components.
+	Parent string
+	// Ancestor is the name of an ancestor of the components.
+	Ancestor string
+	// RedundantRole is the redundant role of the components.
+	RedundantRole oc.E_Platform_ComponentRedundantRole
+	// OperStatus is the operational status of the components.
+	OperStatus oc.E_PlatformTypes_COMPONENT_OPER_STATUS
+	// Name matches the names of the components.
+	Name *regexp.Regexp
+}
+
+func (q *Query) matches(n *Node) bool {
+	switch {
+	case q.Type != nil && n.GetType() != q.Type:
+		return false
+	case q.Parent != "" && (n.Parent == nil || n.Parent.GetName() != q.Parent):
+		return false
+	case q.RedundantRole != oc.Platform_ComponentRedundantRole_UNSET && n.GetRedundantRole() != q.RedundantRole:
+		return false
+	case q.OperStatus != oc.PlatformTypes_COMPONENT_OPER_STATUS_UNSET && n.GetOperStatus() != q.OperStatus:
+		return false
+	case q.Name != nil && !q.Name.MatchString(n.GetName()):
+		return false
+	}
+	if q.Ancestor == "" {
+		return true
+	}
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.GetName() == q.Ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the components that match the query, ordered by name.
+func (tr *Tree) Find(q Query) []*Node {
+	var nodes []*Node
+	for _, name := range sortedNames(tr.byName) {
+		if n := tr.byName[name]; q.matches(n) {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// FindNames returns the names of the components that match the query,
+// ordered by name.
+func (tr *Tree) FindNames(q Query) []string {
+	var names []string
+	for _, n := range tr.Find(q) {
+		names = append(names, n.GetName())
+	}
+	return names
+}
+
+// Supervisors returns the names of the active and standby controller cards,
+// by their redundant roles.  It returns an error unless there is exactly one
+// of each.
+func (tr *Tree) Supervisors() (active, standby string, err error) {
+	cards := tr.Find(Query{Type: oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_CONTROLLER_CARD})
+	var actives, standbys []string
+	for _, n := range cards {
+		switch n.GetRedundantRole() {
+		case oc.Platform_ComponentRedundantRole_PRIMARY:
+			actives = append(actives, n.GetName())
+		case oc.Platform_ComponentRedundantRole_SECONDARY:
+			standbys = append(standbys, n.GetName())
+		}
+	}
+	if len(actives) != 1 || len(standbys) != 1 {
+		return "", "", fmt.Errorf("got active controller cards %q and standby controller cards %q of %d, want one of each", actives, standbys, len(cards))
+	}
+	return actives[0], standbys[0], nil
+}
+
+// String renders the tree as an indented inventory, one component per line.
+func (tr *Tree) String() string {
+	var b strings.Builder
+	for _, r := range tr.roots {
+		r.Walk(func(n *Node) {
+			b.WriteString(strings.Repeat("  ", n.Depth()))
+			b.WriteString(n.GetName())
+			var attrs []string
+			for _, a := range []struct{ k, v string }{
+				{"type", n.TypeName()},
+				{"oper-status", enumName(n.GetOperStatus())},
+				{"redundant-role", enumName(n.GetRedundantRole())},
+				{"part-no", n.GetPartNo()},
+				{"serial-no", n.GetSerialNo()},
+				{"software-version", n.GetSoftwareVersion()},
+			} {
+				if a.v != "" {
+					attrs = append(attrs, a.k+"="+a.v)
+				}
+			}
+			if len(attrs) > 0 {
+				b.WriteString(" (" + strings.Join(attrs, ", ") + ")")
+			}
+			b.WriteString("\n")
+		})
+	}
+	return b.String()
+}
+
+// enumName returns the name of an enum value, or empty if it is unset.
+func enumName[E interface {
+	~int64
+	String() string
+}](e E) string {
+	if e == 0 {
+		return ""
+	}
+	return e.String()
+}
+
+// InventoryItem is a component in the inventory of a Tree.
+type InventoryItem struct {
+	Name            string           `json:"name"`
+	Type            string           `json:"type,omitempty"`
+	Description     string           `json:"description,omitempty"`
+	OperStatus      string           `json:"oper_status,omitempty"`
+	RedundantRole   string           `json:"redundant_role,omitempty"`
+	PartNo          string           `json:"part_no,omitempty"`
+	SerialNo        string           `json:"serial_no,omitempty"`
+	HardwareVersion string           `json:"hardware_version,omitempty"`
+	FirmwareVersion string           `json:"firmware_version,omitempty"`
+	SoftwareVersion string           `json:"software_version,omitempty"`
+	Children        []*InventoryItem `json:"children,omitempty"`
+}
+
+// Inventory returns the tree as inventory items, one per root.
+func (tr *Tree) Inventory() []*InventoryItem {
+	var items []*InventoryItem
+	for _, r := range tr.roots {
+		items = append(items, r.inventory())
+	}
+	return items
+}
+
+func (n *Node) inventory() *InventoryItem {
+	item := &InventoryItem{
+		Name:            n.GetName(),
+		Type:            n.TypeName(),
+		Description:     n.GetDescription(),
+		OperStatus:      enumName(n.GetOperStatus()),
+		RedundantRole:   enumName(n.GetRedundantRole()),
+		PartNo:          n.GetPartNo(),
+		SerialNo:        n.GetSerialNo(),
+		HardwareVersion: n.GetHardwareVersion(),
+		FirmwareVersion: n.GetFirmwareVersion(),
+		SoftwareVersion: n.GetSoftwareVersion(),
+	}
+	for _, c := range n.Children {
+		item.Children = append(item.Children, c.inventory())
+	}
+	return item
+}
+
+// InventoryJSON returns the inventory as indented JSON, to be written as a
+// test artifact along with String(), e.g. with fptest.WriteOutput.
+func (tr *Tree) InventoryJSON() ([]byte, error) {
+	return json.MarshalIndent(tr.Inventory(), "", "  ")
+}
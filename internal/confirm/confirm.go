@@ -18,6 +18,7 @@ package confirm
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"testing"
 
 	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
@@ -25,6 +26,8 @@ import (
 	"github.com/openconfig/ondatra/gnmi/oc"
 	"github.com/openconfig/ygot/ygot"
 	"github.com/openconfig/ygot/ytypes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // getSchema looks up a struct's schema by reflected name
@@ -51,17 +54,29 @@ func PathLabel(pth *gnmipb.Path) string {
 	return pstr
 }
 
-// getSingleValue is ytypes.GetNode, except it returns the Data of the single
-// node found, or an error if the number of matching nodes is not exactly 1.
-func getSingleValue(schema *yang.Entry, root ygot.ValidatedGoStruct, pth *gnmipb.Path) (interface{}, error) {
+// getValue is ytypes.GetNode, except it returns the Data of the node found.
+// It returns nil if no node is found, and a slice of the Data ordered by path
+// if more than one node matches, e.g. for the entries of a keyed list.
+func getValue(schema *yang.Entry, root ygot.ValidatedGoStruct, pth *gnmipb.Path) (interface{}, error) {
 	vals, err := ytypes.GetNode(schema, root, pth)
-	if err != nil {
+	switch {
+	case status.Code(err) == codes.NotFound:
+		return nil, nil
+	case err != nil:
 		return nil, err
+	case len(vals) == 0:
+		return nil, nil
+	case len(vals) == 1:
+		return vals[0].Data, nil
 	}
-	if len(vals) != 1 {
-		return nil, fmt.Errorf("expected exactly one value, found %v", len(vals))
+	sort.Slice(vals, func(i, j int) bool {
+		return PathLabel(vals[i].Path) < PathLabel(vals[j].Path)
+	})
+	var data []interface{}
+	for _, v := range vals {
+		data = append(data, v.Data)
 	}
-	return vals[0].Data, nil
+	return data, nil
 }
 
 // Change represents a difference in value at the gNMI path.
@@ -70,6 +85,7 @@ type Change struct {
 	Want    interface{}
 	Missing bool
 	Got     interface{}
+	Kind    Kind
 }
 
 // Readable is the same as fmt.Sprintf("%v", v), except that pointers to basic types will be
@@ -83,6 +99,8 @@ func Readable(v interface{}) string {
 }
 
 // ExtractChanges turns a Notification into a collection of Change objects.
+// Deleted paths are Missing, and updated paths are Changed, or Added if want
+// has no value there.
 func ExtractChanges(diff *gnmipb.Notification, want, got ygot.ValidatedGoStruct) ([]*Change, error) {
 	schema, err := getSchema(want)
 	if err != nil {
@@ -90,44 +108,56 @@ func ExtractChanges(diff *gnmipb.Notification, want, got ygot.ValidatedGoStruct)
 	}
 	var changes []*Change
 	for _, pth := range diff.GetDelete() {
-		wantVal, err := getSingleValue(schema, want, pth)
+		wantVal, err := getValue(schema, want, pth)
 		if err != nil {
 			return nil, fmt.Errorf("faild to parse expected value at path %v: %v", pth, err)
 		}
-		changes = append(changes, &Change{pth, wantVal, true, nil})
+		changes = append(changes, &Change{pth, wantVal, true, nil, Missing})
 	}
 	for _, upd := range diff.GetUpdate() {
 		pth := upd.GetPath()
-		gotVal, err := getSingleValue(schema, got, pth)
+		gotVal, err := getValue(schema, got, pth)
 		if err != nil {
 			return nil, fmt.Errorf("faild to parse received value at path %v: %v", pth, err)
 		}
-		wantVal, err := getSingleValue(schema, want, pth)
+		wantVal, err := getValue(schema, want, pth)
 		if err != nil {
 			return nil, fmt.Errorf("faild to parse expected value at path %v: %v", pth, err)
 		}
-		changes = append(changes, &Change{pth, wantVal, false, gotVal})
+		kind := Changed
+		if isNil(wantVal) {
+			kind = Added
+		}
+		changes = append(changes, &Change{pth, wantVal, false, gotVal, kind})
 	}
 	return changes, nil
 }
 
 // State checks that every set value in want is present in got. Extra fields in got will be ignored
-// (typically the state contains many more keys than just the ones we're setting).
+// (typically the state contains many more keys than just the ones we're setting).  All
+// differences are reported together in a single error, grouped by kind.
 //
 // DEPRECATED: experimental function
 func State(t testing.TB, want, got ygot.ValidatedGoStruct) {
 	t.Helper()
-	diff, err := ygot.Diff(want, got, &ygot.IgnoreAdditions{})
-	if err != nil {
-		t.Errorf("ygot.Diff failure: %v", err)
-		return
-	}
-	changes, err := ExtractChanges(diff, want, got)
+	StateWithOptions(t, want, got, nil)
+}
+
+// StateWithOptions is State with options to ignore paths, tolerate numeric
+// differences, report additions and write the differences as a JSON artifact.
+func StateWithOptions(t testing.TB, want, got ygot.ValidatedGoStruct, opts *Options) {
+	t.Helper()
+	r, err := Compare(want, got, opts)
 	if err != nil {
 		t.Errorf("Failed to compare states: %v", err)
 		return
 	}
-	for _, change := range changes {
-		t.Errorf("%v: got %v, want %v", PathLabel(change.Path), Readable(change.Got), Readable(change.Want))
+	if opts != nil && opts.Artifact != "" {
+		if err := r.WriteJSON(opts.Artifact); err != nil {
+			t.Errorf("Failed to write state diff: %v", err)
+		}
+	}
+	if !r.Empty() {
+		t.Errorf("State mismatch:\n%v", r)
 	}
 }
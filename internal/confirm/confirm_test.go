@@ -0,0 +1,124 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confirm
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/ondatra/gnmi/oc"
+	"github.com/openconfig/ygot/ygot"
+)
+
+func systems() (want, got *oc.System) {
+	want = &oc.System{Hostname: ygot.String("dut")}
+	want.GetOrCreateDns().Search = []string{"a.example", "b.example"}
+	want.GetOrCreateNtp().GetOrCreateServer("192.0.2.1").Port = ygot.Uint16(123)
+	want.GetOrCreateNtp().GetOrCreateServer("192.0.2.2").Port = ygot.Uint16(123)
+	want.GetOrCreateNtp().GetOrCreateServer("192.0.2.3").Port = ygot.Uint16(123)
+
+	got = &oc.System{Hostname: ygot.String("dut"), DomainName: ygot.String("example")}
+	got.GetOrCreateDns().Search = []string{"b.example", "a.example"}
+	got.GetOrCreateNtp().GetOrCreateServer("192.0.2.1").Port = ygot.Uint16(124)
+	got.GetOrCreateNtp().GetOrCreateServer("192.0.2.3")
+	return want, got
+}
+
+// summary maps each change in the report to "KIND path".
+func summary(r *Report) []string {
+	var s []string
+	for _, c := range r.Changes {
+		s = append(s, c.Kind.String()+" "+PathLabel(c.Path))
+	}
+	return s
+}
+
+func TestCompare(t *testing.T) {
+	cases := []struct {
+		desc string
+		opts *Options
+		want []string
+	}{{
+		desc: "default",
+		want: []string{
+			"CHANGED /dns/state/search",
+			"CHANGED /ntp/servers/server[address=192.0.2.1]/state/port",
+			"MISSING ENTRY /ntp/servers/server[address=192.0.2.2]",
+			"MISSING /ntp/servers/server[address=192.0.2.3]/state/port",
+		},
+	}, {
+		desc: "unordered leaf-lists and tolerance",
+		opts: &Options{
+			UnorderedLeafLists: true,
+			Tolerances:         []*Tolerance{{Path: "/ntp/servers/server[address=*]/state/port", Delta: 1}},
+		},
+		want: []string{
+			"MISSING ENTRY /ntp/servers/server[address=192.0.2.2]",
+			"MISSING /ntp/servers/server[address=192.0.2.3]/state/port",
+		},
+	}, {
+		desc: "ignored subtree",
+		opts: &Options{IgnorePaths: []string{"/ntp/..."}},
+		want: []string{
+			"CHANGED /dns/state/search",
+		},
+	}, {
+		desc: "additions",
+		opts: &Options{ReportAdditions: true, IgnorePaths: []string{"/dns/...", "/ntp/..."}},
+		want: []string{
+			"ADDED /state/domain-name",
+		},
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			want, got := systems()
+			r, err := Compare(want, got, c.opts)
+			if err != nil {
+				t.Fatalf("Compare got error: %v", err)
+			}
+			if diff := cmp.Diff(c.want, summary(r)); diff != "" {
+				t.Errorf("Compare -want, +got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestReportRender(t *testing.T) {
+	want, got := systems()
+	r, err := Compare(want, got, nil)
+	if err != nil {
+		t.Fatalf("Compare got error: %v", err)
+	}
+	s := r.String()
+	for _, sub := range []string{"CHANGED (2)", "MISSING ENTRY (1)", "MISSING (1)", "&124", "&123"} {
+		if !strings.Contains(s, sub) {
+			t.Errorf("Report.String() is missing %q:\n%s", sub, s)
+		}
+	}
+
+	js, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("json.Marshal got error: %v", err)
+	}
+	var grouped map[string][]*jsonChange
+	if err := json.Unmarshal(js, &grouped); err != nil {
+		t.Fatalf("json.Unmarshal got error: %v", err)
+	}
+	if got := len(grouped["CHANGED"]); got != 2 {
+		t.Errorf("JSON report got %d changed leaves, want 2", got)
+	}
+}
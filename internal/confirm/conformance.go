@@ -0,0 +1,274 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confirm
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/check"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/openconfig/ondatra/gnmi/oc"
+	"github.com/openconfig/ygnmi/ygnmi"
+	"github.com/openconfig/ygot/ygot"
+	"github.com/openconfig/ygot/ytypes"
+)
+
+// Mismatch is a state leaf that did not converge to its configured value.
+type Mismatch struct {
+	Path string
+	Err  error
+}
+
+// configLeaf is a populated config leaf found by walkConfig.
+type configLeaf struct {
+	// parent is the GoStruct type containing the leaf field.
+	parent reflect.Type
+	field  int
+	// path is the state path of the leaf.
+	path  ygnmi.PathStruct
+	value interface{}
+	// isDefault is true if value is the schema default of the leaf.
+	isDefault bool
+}
+
+// query returns a state query for the leaf.
+func (l *configLeaf) query() ygnmi.SingletonQuery[interface{}] {
+	parent, field := l.parent, l.field
+	return ygnmi.NewLeafSingletonQuery[interface{}](
+		parent.Name(),
+		true,
+		false,
+		l.path,
+		func(gs ygot.ValidatedGoStruct) (interface{}, bool) {
+			v := reflect.ValueOf(gs).Elem().Field(field)
+			if isEmptyLeaf(v) {
+				return nil, false
+			}
+			return leafValue(v), true
+		},
+		func() ygot.ValidatedGoStruct {
+			return reflect.New(parent).Interface().(ygot.ValidatedGoStruct)
+		},
+		&ytypes.Schema{
+			Root:       &oc.Root{},
+			SchemaTree: oc.SchemaTree,
+			Unmarshal:  oc.Unmarshal,
+		},
+	)
+}
+
+// StateValidators returns a validator for every populated config leaf of
+// config, which was written to path, expecting the matching state leaf to
+// have the configured value.  If the MissingValueForDefaults deviation is set,
+// leaves configured to their default value may also have no state value.
+func StateValidators(path ygnmi.PathStruct, config ygot.ValidatedGoStruct) ([]check.Validator, error) {
+	leaves, err := walkConfig(path, config)
+	if err != nil {
+		return nil, err
+	}
+	var vds []check.Validator
+	for _, l := range leaves {
+		if l.isDefault && *deviations.MissingValueForDefaults {
+			vds = append(vds, check.EqualOrNil(l.query(), l.value))
+		} else {
+			vds = append(vds, check.Equal(l.query(), l.value))
+		}
+	}
+	return vds, nil
+}
+
+// Conformance waits until every populated config leaf of config, which was
+// written to path, is reflected in the state, with a single deadline of
+// now + timeout shared by all the leaves.  It returns the leaves that did not
+// converge, sorted by path.
+func Conformance(client *ygnmi.Client, path ygnmi.PathStruct, config ygot.ValidatedGoStruct, timeout time.Duration) ([]*Mismatch, error) {
+	vds, err := StateValidators(path, config)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	var mismatches []*Mismatch
+	for _, vd := range vds {
+		if err := vd.AwaitUntil(deadline, client); err != nil {
+			mismatches = append(mismatches, &Mismatch{Path: vd.Path(), Err: err})
+		}
+	}
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].Path < mismatches[j].Path
+	})
+	return mismatches, nil
+}
+
+// ConfigConverges is Conformance for tests: it reports all the leaves that
+// did not converge within timeout in one error.
+func ConfigConverges(t testing.TB, client *ygnmi.Client, path ygnmi.PathStruct, config ygot.ValidatedGoStruct, timeout time.Duration) {
+	t.Helper()
+	mismatches, err := Conformance(client, path, config, timeout)
+	if err != nil {
+		t.Errorf("Failed to check state of %s: %v", check.FormatPath(path), err)
+		return
+	}
+	if len(mismatches) == 0 {
+		return
+	}
+	var lines []string
+	for _, m := range mismatches {
+		lines = append(lines, "  "+m.Err.Error())
+	}
+	t.Errorf("State of %s does not match config in %d leaves:\n%s", check.FormatPath(path), len(mismatches), strings.Join(lines, "\n"))
+}
+
+// walkConfig returns the populated config leaves of config.
+func walkConfig(path ygnmi.PathStruct, config ygot.ValidatedGoStruct) ([]*configLeaf, error) {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config must be a non-nil GoStruct pointer, got %T", config)
+	}
+	var leaves []*configLeaf
+	if err := walkStruct(v.Elem(), path, &leaves); err != nil {
+		return nil, err
+	}
+	return leaves, nil
+}
+
+// walkStruct appends the populated config leaves of the struct s, which is at
+// path, and recurses into its containers and lists.
+func walkStruct(s reflect.Value, path ygnmi.PathStruct, leaves *[]*configLeaf) error {
+	typ := s.Type()
+	schema := oc.SchemaTree[typ.Name()]
+	for i := 0; i < typ.NumField(); i++ {
+		ft, fv := typ.Field(i), s.Field(i)
+		tag, ok := ft.Tag.Lookup("path")
+		if !ok || isEmptyLeaf(fv) {
+			continue
+		}
+		elems := strings.Split(strings.Split(tag, "|")[0], "/")
+		switch {
+		case fv.Kind() == reflect.Ptr && fv.Elem().Kind() == reflect.Struct:
+			if err := walkStruct(fv.Elem(), ygnmi.NewNodePath(elems, nil, path), leaves); err != nil {
+				return err
+			}
+		case fv.Kind() == reflect.Map:
+			if err := walkList(fv, elems, path, leaves); err != nil {
+				return err
+			}
+		default:
+			if _, ok := ft.Tag.Lookup("shadow-path"); !ok {
+				continue // state-only leaf
+			}
+			val := leafValue(fv)
+			*leaves = append(*leaves, &configLeaf{
+				parent:    typ,
+				field:     i,
+				path:      ygnmi.NewNodePath(elems, nil, path),
+				value:     val,
+				isDefault: isDefault(childSchema(schema, elems), val),
+			})
+		}
+	}
+	return nil
+}
+
+// walkList walks the entries of a keyed list in key order.
+func walkList(m reflect.Value, elems []string, path ygnmi.PathStruct, leaves *[]*configLeaf) error {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	for _, k := range keys {
+		entry := m.MapIndex(k)
+		km, ok := entry.Interface().(ygot.KeyHelperGoStruct)
+		if !ok {
+			return fmt.Errorf("list %s entry %v has no key helper", strings.Join(elems, "/"), k)
+		}
+		keyMap, err := km.ΛListKeyMap()
+		if err != nil {
+			return fmt.Errorf("list %s entry %v: %v", strings.Join(elems, "/"), k, err)
+		}
+		var entryPath ygnmi.PathStruct = path
+		if len(elems) > 1 {
+			entryPath = ygnmi.NewNodePath(elems[:len(elems)-1], nil, path)
+		}
+		entryPath = ygnmi.NewNodePath(elems[len(elems)-1:], keyMap, entryPath)
+		if err := walkStruct(entry.Elem(), entryPath, leaves); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isEmptyLeaf reports whether a struct field is unset.
+func isEmptyLeaf(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Int64:
+		return v.Int() == 0 // unset enum
+	}
+	return false
+}
+
+// leafValue returns the value of a leaf field, dereferencing scalar pointers.
+func leafValue(v reflect.Value) interface{} {
+	if v.Kind() == reflect.Ptr {
+		return v.Elem().Interface()
+	}
+	return v.Interface()
+}
+
+// childSchema descends from entry along the schema path elements.
+func childSchema(entry *yang.Entry, elems []string) *yang.Entry {
+	for _, name := range elems {
+		if entry == nil {
+			return nil
+		}
+		entry = entry.Dir[name]
+	}
+	return entry
+}
+
+// isDefault reports whether val is the schema default of the leaf entry.
+func isDefault(entry *yang.Entry, val interface{}) bool {
+	if entry == nil {
+		return false
+	}
+	defaults := entry.DefaultValues()
+	if len(defaults) == 0 {
+		return false
+	}
+	var got []string
+	switch v := val.(type) {
+	case ygot.GoEnum:
+		got = []string{v.String()}
+	default:
+		rv := reflect.ValueOf(val)
+		if rv.Kind() == reflect.Slice {
+			for i := 0; i < rv.Len(); i++ {
+				got = append(got, fmt.Sprint(rv.Index(i).Interface()))
+			}
+		} else {
+			got = []string{fmt.Sprint(val)}
+		}
+	}
+	return reflect.DeepEqual(got, defaults)
+}
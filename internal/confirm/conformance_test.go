@@ -0,0 +1,158 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confirm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/featureprofiles/internal/check"
+	"github.com/openconfig/ondatra/gnmi"
+	"github.com/openconfig/ondatra/gnmi/oc"
+	"github.com/openconfig/ygnmi/ygnmi"
+	"github.com/openconfig/ygot/ygot"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	fakegnmi "github.com/openconfig/gnmi/testing/fake/gnmi"
+	fpb "github.com/openconfig/gnmi/testing/fake/proto"
+)
+
+func TestWalkConfig(t *testing.T) {
+	config := &oc.System{Hostname: ygot.String("dut")}
+	config.GetOrCreateDns().Search = []string{"example.com"}
+	ntp := config.GetOrCreateNtp()
+	ntp.Enabled = ygot.Bool(true)
+	ntp.GetOrCreateServer("192.0.2.2").Port = ygot.Uint16(123)
+	ntp.GetOrCreateServer("192.0.2.1").AssociationType = oc.Server_AssociationType_POOL
+	ntp.GetServer("192.0.2.1").Offset = ygot.Uint64(5) // state-only, ignored.
+
+	leaves, err := walkConfig(gnmi.OC().System(), config)
+	if err != nil {
+		t.Fatalf("walkConfig got error: %v", err)
+	}
+	type leaf struct {
+		Path      string
+		Value     interface{}
+		IsDefault bool
+	}
+	var got []leaf
+	for _, l := range leaves {
+		got = append(got, leaf{check.FormatPath(l.path), l.value, l.isDefault})
+	}
+	want := []leaf{
+		{"/system/dns/state/search", []string{"example.com"}, false},
+		{"/system/state/hostname", "dut", false},
+		{"/system/ntp/state/enabled", true, false},
+		{"/system/ntp/servers/server[address=192.0.2.1]/state/address", "192.0.2.1", false},
+		{"/system/ntp/servers/server[address=192.0.2.1]/state/association-type", oc.Server_AssociationType_POOL, false},
+		{"/system/ntp/servers/server[address=192.0.2.2]/state/address", "192.0.2.2", false},
+		{"/system/ntp/servers/server[address=192.0.2.2]/state/port", uint16(123), true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("walkConfig -want, +got:\n%s", diff)
+	}
+}
+
+func TestStateValidators(t *testing.T) {
+	config := &oc.System{Hostname: ygot.String("dut")}
+	vds, err := StateValidators(gnmi.OC().System(), config)
+	if err != nil {
+		t.Fatalf("StateValidators got error: %v", err)
+	}
+	if len(vds) != 1 || vds[0].Path() != "/system/state/hostname" {
+		var paths []string
+		for _, vd := range vds {
+			paths = append(paths, vd.Path())
+		}
+		t.Errorf("StateValidators got paths %v, want [/system/state/hostname]", paths)
+	}
+	if _, err := StateValidators(gnmi.OC().System(), (*oc.System)(nil)); err == nil {
+		t.Errorf("StateValidators got no error on nil config")
+	}
+}
+
+// newFakeClient returns a client of a fake gNMI agent that responds to every
+// subscription with the updates.
+func newFakeClient(t *testing.T, updates ...*gpb.Update) *ygnmi.Client {
+	t.Helper()
+	gen := &fpb.FixedGenerator{Responses: []*gpb.SubscribeResponse{{
+		Response: &gpb.SubscribeResponse_Update{Update: &gpb.Notification{Timestamp: 1, Update: updates}},
+	}, {
+		Response: &gpb.SubscribeResponse_SyncResponse{SyncResponse: true},
+	}}}
+	agent, err := fakegnmi.New(&fpb.Config{Generator: &fpb.Config_Fixed{Fixed: gen}}, nil)
+	if err != nil {
+		t.Fatalf("Cannot start the fake gNMI agent: %v", err)
+	}
+	t.Cleanup(agent.Close)
+	conn, err := grpc.Dial(agent.Address(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Cannot dial the fake gNMI agent: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	client, err := ygnmi.NewClient(gpb.NewGNMIClient(conn))
+	if err != nil {
+		t.Fatalf("Cannot create the ygnmi client: %v", err)
+	}
+	return client
+}
+
+func TestConfigLeafQuery(t *testing.T) {
+	config := &oc.System{Hostname: ygot.String("dut")}
+	config.GetOrCreateNtp().GetOrCreateServer("192.0.2.1").Port = ygot.Uint16(123)
+	leaves, err := walkConfig(gnmi.OC().System(), config)
+	if err != nil {
+		t.Fatalf("walkConfig got error: %v", err)
+	}
+
+	// The fake agent does not filter by path, so each leaf gets its own.
+	vals := map[string]*gpb.TypedValue{
+		"/system/state/hostname": {Value: &gpb.TypedValue_StringVal{StringVal: "dut"}},
+		"/system/ntp/servers/server[address=192.0.2.1]/state/address": {Value: &gpb.TypedValue_StringVal{StringVal: "192.0.2.1"}},
+		"/system/ntp/servers/server[address=192.0.2.1]/state/port":    {Value: &gpb.TypedValue_UintVal{UintVal: 124}},
+	}
+	got := make(map[string]interface{})
+	for _, l := range leaves {
+		q := l.query()
+		path, _, err := ygnmi.ResolvePath(q.PathStruct())
+		if err != nil {
+			t.Fatalf("Cannot resolve the path of %s: %v", check.FormatPath(l.path), err)
+		}
+		name := check.FormatPath(q.PathStruct())
+		val, ok := vals[name]
+		if !ok {
+			t.Fatalf("Query has unexpected path %s", name)
+		}
+		client := newFakeClient(t, &gpb.Update{Path: path, Val: val})
+		v, err := ygnmi.Lookup(context.Background(), client, q)
+		if err != nil {
+			t.Fatalf("Lookup(%s) got error: %v", name, err)
+		}
+		if val, ok := v.Val(); ok {
+			got[name] = val
+		}
+	}
+	want := map[string]interface{}{
+		"/system/state/hostname": "dut",
+		"/system/ntp/servers/server[address=192.0.2.1]/state/address": "192.0.2.1",
+		"/system/ntp/servers/server[address=192.0.2.1]/state/port":    uint16(124),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Query values -want, +got:\n%s", diff)
+	}
+}
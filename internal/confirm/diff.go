@@ -0,0 +1,373 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package confirm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	gnmipb "github.com/openconfig/gnmi/proto/gnmi"
+	"github.com/openconfig/goyang/pkg/yang"
+	"github.com/openconfig/ygot/ygot"
+	"github.com/openconfig/ygot/ytypes"
+)
+
+// Kind classifies a Change.
+type Kind int
+
+const (
+	// Changed is a leaf with different values in want and got.
+	Changed Kind = iota
+	// MissingEntry is a keyed list entry in want that is absent from got.
+	// It stands for all the missing leaves of that entry.
+	MissingEntry
+	// Missing is a leaf set in want but not in got.
+	Missing
+	// Added is a leaf set in got but not in want.
+	Added
+)
+
+var kindNames = map[Kind]string{
+	Changed:      "CHANGED",
+	MissingEntry: "MISSING ENTRY",
+	Missing:      "MISSING",
+	Added:        "ADDED",
+}
+
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Options control how Compare reports the differences.
+type Options struct {
+	// IgnorePaths are patterns of paths to leave out of the comparison, in
+	// the form reported by PathLabel.  A "*" matches any characters within
+	// one path element, and a trailing "/..." matches all descendants, e.g.
+	// "/interfaces/interface[name=*]/state/counters/...".
+	IgnorePaths []string
+	// Tolerances allow numeric leaves to differ within a margin.
+	Tolerances []*Tolerance
+	// ReportAdditions also reports leaves that are set in got but not in
+	// want.  By default they are ignored, because the state typically has
+	// many more leaves than the config.
+	ReportAdditions bool
+	// UnorderedLeafLists compares leaf-lists regardless of element order.
+	UnorderedLeafLists bool
+	// Artifact is the name of a JSON artifact that StateWithOptions writes
+	// the differences to in -outputs_dir.  Empty means no artifact.
+	Artifact string
+}
+
+// Tolerance allows numeric leaves matching a path pattern to differ by up to
+// Delta, or by up to Percent of the wanted value, whichever is larger.
+type Tolerance struct {
+	// Path is a pattern with the same syntax as Options.IgnorePaths.
+	Path    string
+	Delta   float64
+	Percent float64
+}
+
+// Report is the result of Compare.
+type Report struct {
+	Changes []*Change
+}
+
+// Compare reports the differences between want and got according to the
+// options, which may be nil.  The changes are sorted by kind and path.
+func Compare(want, got ygot.ValidatedGoStruct, opts *Options) (*Report, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	ignore, err := compilePatterns(opts.IgnorePaths)
+	if err != nil {
+		return nil, err
+	}
+	tolerances := make([]*regexp.Regexp, len(opts.Tolerances))
+	for i, tol := range opts.Tolerances {
+		if tolerances[i], err = compilePattern(tol.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	var diffOpts []ygot.DiffOpt
+	if !opts.ReportAdditions {
+		diffOpts = append(diffOpts, &ygot.IgnoreAdditions{})
+	}
+	diff, err := ygot.Diff(want, got, diffOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("ygot.Diff failure: %v", err)
+	}
+	changes, err := ExtractChanges(diff, want, got)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := getSchema(want)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{}
+	entries := make(map[string]bool)
+	for _, c := range changes {
+		label := PathLabel(c.Path)
+		if matchAny(ignore, label) {
+			continue
+		}
+		switch c.Kind {
+		case Missing:
+			if entry, ok := missingEntry(schema, got, c.Path); ok {
+				entryLabel := PathLabel(entry)
+				if !entries[entryLabel] && !matchAny(ignore, entryLabel) {
+					entries[entryLabel] = true
+					r.Changes = append(r.Changes, &Change{Path: entry, Missing: true, Kind: MissingEntry})
+				}
+				continue
+			}
+		case Changed:
+			if opts.UnorderedLeafLists && sameElements(c.Want, c.Got) {
+				continue
+			}
+			if withinTolerance(opts.Tolerances, tolerances, label, c.Want, c.Got) {
+				continue
+			}
+		}
+		r.Changes = append(r.Changes, c)
+	}
+	sort.SliceStable(r.Changes, func(i, j int) bool {
+		ci, cj := r.Changes[i], r.Changes[j]
+		if ci.Kind != cj.Kind {
+			return ci.Kind < cj.Kind
+		}
+		return PathLabel(ci.Path) < PathLabel(cj.Path)
+	})
+	return r, nil
+}
+
+// Empty reports whether there are no differences.
+func (r *Report) Empty() bool {
+	return len(r.Changes) == 0
+}
+
+// ByKind returns the changes of the given kind.
+func (r *Report) ByKind(k Kind) []*Change {
+	var changes []*Change
+	for _, c := range r.Changes {
+		if c.Kind == k {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// String renders the differences as a table grouped by kind.
+func (r *Report) String() string {
+	b := &strings.Builder{}
+	w := tabwriter.NewWriter(b, 0, 0, 2, ' ', 0)
+	for _, k := range []Kind{Changed, MissingEntry, Missing, Added} {
+		changes := r.ByKind(k)
+		if len(changes) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%v (%d)\tGot\tWant\n", k, len(changes))
+		for _, c := range changes {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", PathLabel(c.Path), readableValue(c.Got), readableValue(c.Want))
+		}
+	}
+	w.Flush()
+	return b.String()
+}
+
+// jsonChange is the JSON representation of a Change.
+type jsonChange struct {
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+	Got  string `json:"got,omitempty"`
+	Want string `json:"want,omitempty"`
+}
+
+// MarshalJSON renders the differences as a JSON object keyed by kind.
+func (r *Report) MarshalJSON() ([]byte, error) {
+	grouped := make(map[string][]*jsonChange)
+	for _, c := range r.Changes {
+		k := c.Kind.String()
+		grouped[k] = append(grouped[k], &jsonChange{
+			Kind: k,
+			Path: PathLabel(c.Path),
+			Got:  readableValue(c.Got),
+			Want: readableValue(c.Want),
+		})
+	}
+	return json.Marshal(grouped)
+}
+
+// WriteJSON writes the differences as a JSON artifact to -outputs_dir.
+func (r *Report) WriteJSON(name string) error {
+	js, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fptest.WriteOutput(name, ".json", string(js))
+}
+
+// readableValue is Readable, except nil values are rendered as empty.
+func readableValue(v interface{}) string {
+	if isNil(v) {
+		return ""
+	}
+	if vs, ok := v.([]interface{}); ok {
+		var elems []string
+		for _, e := range vs {
+			elems = append(elems, Readable(e))
+		}
+		return "[" + strings.Join(elems, " ") + "]"
+	}
+	return Readable(v)
+}
+
+// isNil reports whether v is nil or a nil pointer, slice or map.
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	switch val := reflect.ValueOf(v); val.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return val.IsNil()
+	}
+	return false
+}
+
+// missingEntry returns the outermost keyed list entry on the path that does
+// not exist in got, if any.
+func missingEntry(schema *yang.Entry, got ygot.ValidatedGoStruct, pth *gnmipb.Path) (*gnmipb.Path, bool) {
+	for i, elem := range pth.GetElem() {
+		if len(elem.GetKey()) == 0 {
+			continue
+		}
+		entry := &gnmipb.Path{Elem: pth.GetElem()[:i+1]}
+		vals, err := ytypes.GetNode(schema, got, entry)
+		if err != nil || len(vals) == 0 || isNil(vals[0].Data) {
+			return entry, true
+		}
+	}
+	return nil, false
+}
+
+// compilePattern converts a path pattern to a regular expression.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	subtree := strings.HasSuffix(pattern, "/...")
+	pattern = strings.TrimSuffix(pattern, "/...")
+	expr := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, `[^/]*`)
+	if subtree {
+		expr += `(/.*)?`
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return nil, fmt.Errorf("bad path pattern %q: %w", pattern, err)
+	}
+	return re, nil
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, p := range patterns {
+		re, err := compilePattern(p)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+func matchAny(res []*regexp.Regexp, label string) bool {
+	for _, re := range res {
+		if re.MatchString(label) {
+			return true
+		}
+	}
+	return false
+}
+
+// withinTolerance reports whether numeric want and got values at the path
+// differ by no more than the first matching tolerance.
+func withinTolerance(tols []*Tolerance, res []*regexp.Regexp, label string, want, got interface{}) bool {
+	for i, re := range res {
+		if !re.MatchString(label) {
+			continue
+		}
+		w, ok := toFloat(want)
+		if !ok {
+			return false
+		}
+		g, ok := toFloat(got)
+		if !ok {
+			return false
+		}
+		margin := math.Max(tols[i].Delta, math.Abs(w)*tols[i].Percent/100)
+		return math.Abs(g-w) <= margin
+	}
+	return false
+}
+
+// toFloat converts a numeric value, or a pointer to one, to float64.
+func toFloat(v interface{}) (float64, bool) {
+	if isNil(v) {
+		return 0, false
+	}
+	val := reflect.ValueOf(v)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(val.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(val.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return val.Float(), true
+	}
+	return 0, false
+}
+
+// sameElements reports whether want and got are slices with the same
+// elements, ignoring order.
+func sameElements(want, got interface{}) bool {
+	wv, gv := reflect.ValueOf(want), reflect.ValueOf(got)
+	if wv.Kind() != reflect.Slice || gv.Kind() != reflect.Slice || wv.Len() != gv.Len() {
+		return false
+	}
+	counts := make(map[string]int)
+	for i := 0; i < wv.Len(); i++ {
+		counts[fmt.Sprint(wv.Index(i).Interface())]++
+	}
+	for i := 0; i < gv.Len(); i++ {
+		k := fmt.Sprint(gv.Index(i).Interface())
+		if counts[k] == 0 {
+			return false
+		}
+		counts[k]--
+	}
+	return true
+}
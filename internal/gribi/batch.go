@@ -0,0 +1,358 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/gribigo/client"
+	"github.com/openconfig/gribigo/constants"
+	"github.com/openconfig/gribigo/fluent"
+	"google.golang.org/protobuf/encoding/prototext"
+
+	gpb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+const (
+	// defaultWindow is the default number of operations awaiting their
+	// final ack in a batch.
+	defaultWindow = 1000
+	// batchPollInterval is how often a batch polls the client for acks.
+	batchPollInterval = 5 * time.Millisecond
+)
+
+// Batch is a sequence of gRIBI operations to be streamed to the DUT by
+// ProgramBatch, which keeps a window of operations in flight and tracks their
+// acks asynchronously instead of awaiting each operation in turn.
+//
+// Usage:
+//
+//	b := &gribi.Batch{}
+//	for i, prefix := range prefixes {
+//	  b.AddEntry(fluent.IPv4Entry().WithPrefix(prefix).
+//	    WithNetworkInstance(ni).WithNextHopGroup(nhg))
+//	}
+//	sum, err := c.ProgramBatch(t, b, &gribi.BatchOptions{Window: 2000})
+type Batch struct {
+	ops []*batchOp
+}
+
+type batchOp struct {
+	opType constants.OpType
+	entry  fluent.GRIBIEntry
+}
+
+// AddEntry appends ADD operations of the entries to the batch.
+func (b *Batch) AddEntry(entries ...fluent.GRIBIEntry) *Batch {
+	return b.append(constants.Add, entries)
+}
+
+// ReplaceEntry appends REPLACE operations of the entries to the batch.
+func (b *Batch) ReplaceEntry(entries ...fluent.GRIBIEntry) *Batch {
+	return b.append(constants.Replace, entries)
+}
+
+// DeleteEntry appends DELETE operations of the entries to the batch.
+func (b *Batch) DeleteEntry(entries ...fluent.GRIBIEntry) *Batch {
+	return b.append(constants.Delete, entries)
+}
+
+func (b *Batch) append(opType constants.OpType, entries []fluent.GRIBIEntry) *Batch {
+	for _, e := range entries {
+		b.ops = append(b.ops, &batchOp{opType: opType, entry: e})
+	}
+	return b
+}
+
+// Len returns the number of operations in the batch.
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+// BatchOptions are optional parameters to ProgramBatch.
+type BatchOptions struct {
+	// Window is the maximum number of operations that have been sent but not
+	// received their final ack.  Zero means 1000.
+	Window int
+	// Timeout bounds the time to program the whole batch.  Zero means no
+	// timeout other than that of the context.
+	Timeout time.Duration
+}
+
+// OpFailure is an operation of a batch that failed or was never acked.
+type OpFailure struct {
+	// Index is the index of the operation in the batch.
+	Index int `json:"index"`
+	// Type is the type of the operation.
+	Type string `json:"type"`
+	// Entry is the text format of the entry.
+	Entry string `json:"entry"`
+	// Result is the last programming result received, or UNSET if the
+	// operation was never acked.
+	Result string `json:"result"`
+}
+
+func (f *OpFailure) String() string {
+	return fmt.Sprintf("op %d (%s %s): %s", f.Index, f.Type, f.Entry, f.Result)
+}
+
+// BatchSummary summarizes the programming of a batch, for trending the
+// install rate of a DUT across releases.
+type BatchSummary struct {
+	// Ops is the number of operations in the batch.
+	Ops int `json:"ops"`
+	// Acked is the number of operations that received their final ack.
+	Acked int `json:"acked"`
+	// Window is the window size that the batch was programmed with.
+	Window int `json:"window"`
+	// Duration is the time from sending the first operation to receiving
+	// the last final ack.
+	Duration time.Duration `json:"duration_ns"`
+	// OpsPerSecond is the number of acked operations per second of Duration.
+	OpsPerSecond float64 `json:"ops_per_second"`
+	// TimeToLastRIBACK and TimeToLastFIBACK are the times from sending the
+	// first operation to receiving the last RIB_PROGRAMMED and FIB_PROGRAMMED
+	// result.  Either is zero if no such result was received, e.g.
+	// TimeToLastFIBACK in a session without FIB_ACK.
+	TimeToLastRIBACK time.Duration `json:"time_to_last_rib_ack_ns"`
+	TimeToLastFIBACK time.Duration `json:"time_to_last_fib_ack_ns"`
+	// Failures are the operations that failed or were never acked.
+	Failures []*OpFailure `json:"failures,omitempty"`
+}
+
+func (s *BatchSummary) String() string {
+	return fmt.Sprintf("%d/%d ops acked in %v (%.1f ops/s, window %d), last RIB_ACK after %v, last FIB_ACK after %v, %d failures",
+		s.Acked, s.Ops, s.Duration, s.OpsPerSecond, s.Window, s.TimeToLastRIBACK, s.TimeToLastFIBACK, len(s.Failures))
+}
+
+// Write writes the summary as JSON to the test outputs directory, with the
+// given name prefix.
+func (s *BatchSummary) Write(name string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fptest.WriteOutput(name, ".json", string(b))
+}
+
+// ProgramBatch streams the operations of the batch to the DUT over the
+// client's session, keeping up to a window of operations awaiting their
+// final ack.  Operations that failed or were never acked before the timeout
+// are reported in the summary; an error is returned only if the session
+// fails.  The other operations are applied to the intended RIB.
+func (c *Client) ProgramBatch(t testing.TB, b *Batch, opts *BatchOptions) (*BatchSummary, error) {
+	t.Helper()
+	sum, err := ProgramBatch(context.Background(), t, c.fluentC, b, opts)
+	if err != nil {
+		return nil, err
+	}
+	if c.ref != nil {
+		c.ref.mirrorBatch(t, b, opts, sum)
+	}
+	failed := make(map[int]bool)
+	for _, f := range sum.Failures {
+		failed[f.Index] = true
+	}
+	for i, op := range b.ops {
+		if failed[i] {
+			continue
+		}
+		if pb, err := op.entry.OpProto(); err == nil {
+			pb.Op = aftOps[op.opType]
+			c.IntendedRIB().Apply(pb)
+		}
+	}
+	return sum, nil
+}
+
+// aftOps maps operation types to gRIBI AFT operations.
+var aftOps = map[constants.OpType]gpb.AFTOperation_Operation{
+	constants.Add:     gpb.AFTOperation_ADD,
+	constants.Replace: gpb.AFTOperation_REPLACE,
+	constants.Delete:  gpb.AFTOperation_DELETE,
+}
+
+// ProgramBatch streams the operations of the batch over a fluent client
+// session.  See Client.ProgramBatch.
+func ProgramBatch(ctx context.Context, t testing.TB, c *fluent.GRIBIClient, b *Batch, opts *BatchOptions) (*BatchSummary, error) {
+	t.Helper()
+	window := defaultWindow
+	if opts != nil && opts.Window > 0 {
+		window = opts.Window
+	}
+	if opts != nil && opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	// The fluent client numbers operations sequentially, so the IDs of the
+	// batch follow its count of operations so far, whether or not earlier
+	// operations got a result.
+	st := c.Status(t)
+	seen := len(st.Results)
+	firstID := opCount(st) + 1
+
+	n := len(b.ops)
+	status := make([]gpb.AFTResult_Status, n)
+	acked := make([]bool, n)
+	resultAt := make([]time.Time, n)
+	sum := &BatchSummary{Ops: n, Window: window}
+	start := time.Now()
+	var lastAck time.Time
+	sent := 0
+	for {
+		st := c.Status(t)
+		if len(st.SendErrs) > 0 || len(st.ReadErrs) > 0 {
+			return nil, fmt.Errorf("gRIBI session failed after %d of %d ops were sent: send errors %v, read errors %v", sent, n, st.SendErrs, st.ReadErrs)
+		}
+		for _, r := range st.Results[seen:] {
+			if r.OperationID < firstID || r.OperationID >= firstID+uint64(n) {
+				continue
+			}
+			i := int(r.OperationID - firstID)
+			status[i] = r.ProgrammingResult
+			// Results are timed when they are polled, since the fluent client
+			// does not timestamp them reliably.
+			at := time.Now()
+			resultAt[i] = at
+			switch r.ProgrammingResult {
+			case gpb.AFTResult_RIB_PROGRAMMED:
+				sum.TimeToLastRIBACK = at.Sub(start)
+			case gpb.AFTResult_FIB_PROGRAMMED:
+				sum.TimeToLastFIBACK = at.Sub(start)
+			}
+		}
+		seen = len(st.Results)
+		pending := make(map[uint64]bool)
+		for _, p := range st.PendingTransactions {
+			if op, ok := p.(*client.PendingOp); ok {
+				pending[op.Op.GetId()] = true
+			}
+		}
+		done := 0
+		for i := 0; i < sent; i++ {
+			if !acked[i] && status[i] != gpb.AFTResult_UNSET && !pending[firstID+uint64(i)] {
+				acked[i] = true
+				if resultAt[i].After(lastAck) {
+					lastAck = resultAt[i]
+				}
+			}
+			if acked[i] {
+				done++
+			}
+		}
+		if done == n {
+			break
+		}
+		if free := window - (sent - done); sent < n && free > 0 {
+			sent += sendOps(t, c, b.ops[sent:min(n, sent+free)])
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			t.Logf("gRIBI batch timed out with %d of %d ops sent: %v", sent, n, ctx.Err())
+		case <-time.After(batchPollInterval):
+			continue
+		}
+		break
+	}
+
+	for i, op := range b.ops {
+		if acked[i] {
+			sum.Acked++
+			if status[i] != gpb.AFTResult_FAILED && status[i] != gpb.AFTResult_FIB_FAILED {
+				continue
+			}
+		}
+		sum.Failures = append(sum.Failures, &OpFailure{
+			Index:  i,
+			Type:   op.opType.String(),
+			Entry:  entryText(op.entry),
+			Result: status[i].String(),
+		})
+	}
+	if !lastAck.IsZero() {
+		sum.Duration = lastAck.Sub(start)
+	}
+	if sum.Duration > 0 {
+		sum.OpsPerSecond = float64(sum.Acked) / sum.Duration.Seconds()
+	}
+	return sum, nil
+}
+
+// opCount returns the number of operations that a fluent client numbered so
+// far, from its status: the largest ID of its results and pending operations,
+// since the client adds each operation to the pending ones as it queues it,
+// and keeps it there until it gets its final result.
+func opCount(st *client.ClientStatus) uint64 {
+	var count uint64
+	for _, r := range st.Results {
+		if r.OperationID > count {
+			count = r.OperationID
+		}
+	}
+	for _, p := range st.PendingTransactions {
+		if op, ok := p.(*client.PendingOp); ok && op.Op.GetId() > count {
+			count = op.Op.GetId()
+		}
+	}
+	return count
+}
+
+// sendOps queues the operations, one ModifyRequest per run of operations of
+// the same type, and returns how many were queued.
+func sendOps(t testing.TB, c *fluent.GRIBIClient, ops []*batchOp) int {
+	t.Helper()
+	for i := 0; i < len(ops); {
+		j := i
+		var entries []fluent.GRIBIEntry
+		for ; j < len(ops) && ops[j].opType == ops[i].opType; j++ {
+			entries = append(entries, ops[j].entry)
+		}
+		switch ops[i].opType {
+		case constants.Add:
+			c.Modify().AddEntry(t, entries...)
+		case constants.Replace:
+			c.Modify().ReplaceEntry(t, entries...)
+		case constants.Delete:
+			c.Modify().DeleteEntry(t, entries...)
+		}
+		i = j
+	}
+	return len(ops)
+}
+
+// entryText returns a single-line text format of the entry.
+func entryText(e fluent.GRIBIEntry) string {
+	op, err := e.OpProto()
+	if err != nil {
+		return fmt.Sprintf("<invalid entry: %v>", err)
+	}
+	return strings.Join(strings.Fields(prototext.Format(op)), " ")
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,182 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/gribigo/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	gpb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+// startServer starts an in-process gRIBI reference server and returns a
+// fluent client that is the leader of a session with it.
+func startServer(t *testing.T) *fluent.GRIBIClient {
+	t.Helper()
+	return startClient(t, serverStub(t))
+}
+
+// startClient returns a fluent client that is the leader of a session with
+// the stub.
+func startClient(t *testing.T, stub gpb.GRIBIClient) *fluent.GRIBIClient {
+	t.Helper()
+	c := fluent.NewClient()
+	c.Connection().WithStub(stub).
+		WithRedundancyMode(fluent.ElectedPrimaryClient).
+		WithInitialElectionID(1, 0).
+		WithPersistence().
+		WithFIBACK()
+	ctx := context.Background()
+	c.Start(ctx, t)
+	t.Cleanup(func() { c.Stop(t) })
+	c.StartSending(ctx, t)
+	if err := awaitTimeout(ctx, t, c, time.Minute); err != nil {
+		t.Fatalf("Await got error during session negotiation: %v", err)
+	}
+	return c
+}
+
+// serverStub starts an in-process gRIBI reference server with the options
+// and returns a stub connected to it.
+func serverStub(t *testing.T, opts ...server.ServerOpt) gpb.GRIBIClient {
+	t.Helper()
+	s, err := server.New(opts...)
+	if err != nil {
+		t.Fatalf("Cannot create gRIBI server: %v", err)
+	}
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("Cannot listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	gpb.RegisterGRIBIServer(srv, s)
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Cannot dial gRIBI server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return gpb.NewGRIBIClient(conn)
+}
+
+func TestProgramBatch(t *testing.T) {
+	c := startServer(t)
+	const ni = server.DefaultNetworkInstanceName
+	b := &Batch{}
+	b.AddEntry(
+		fluent.NextHopEntry().WithNetworkInstance(ni).WithIndex(1).WithIPAddress("192.0.2.1"),
+		fluent.NextHopGroupEntry().WithNetworkInstance(ni).WithID(1).AddNextHop(1, 1),
+	)
+	const routes = 100
+	for i := 0; i < routes; i++ {
+		b.AddEntry(fluent.IPv4Entry().WithNetworkInstance(ni).WithPrefix(fmt.Sprintf("198.51.100.%d/32", i)).WithNextHopGroup(1))
+	}
+	// The reference server rejects entries in unknown network instances.
+	b.AddEntry(fluent.IPv4Entry().WithNetworkInstance("unknown").WithPrefix("203.0.113.1/32").WithNextHopGroup(1))
+
+	sum, err := ProgramBatch(context.Background(), t, c, b, &BatchOptions{Window: 10, Timeout: time.Minute})
+	if err != nil {
+		t.Fatalf("ProgramBatch() got error: %v", err)
+	}
+	t.Log(sum)
+	if got, want := sum.Acked, b.Len(); got != want {
+		t.Errorf("ProgramBatch() acked %d ops, want %d", got, want)
+	}
+	if got := len(sum.Failures); got != 1 {
+		t.Fatalf("ProgramBatch() got failures %v, want 1", sum.Failures)
+	}
+	if f := sum.Failures[0]; f.Index != routes+2 || f.Result != gpb.AFTResult_FAILED.String() {
+		t.Errorf("ProgramBatch() got failure %v, want op %d FAILED", f, routes+2)
+	}
+	if sum.TimeToLastFIBACK <= 0 || sum.OpsPerSecond <= 0 {
+		t.Errorf("ProgramBatch() got summary %v, want FIB_ACK time and rate", sum)
+	}
+
+	// A second batch follows the operation IDs of the first.
+	sum, err = ProgramBatch(context.Background(), t, c, (&Batch{}).DeleteEntry(
+		fluent.IPv4Entry().WithNetworkInstance(ni).WithPrefix("198.51.100.0/32"),
+	), nil)
+	if err != nil {
+		t.Fatalf("ProgramBatch() got error: %v", err)
+	}
+	if sum.Acked != 1 || len(sum.Failures) != 0 {
+		t.Errorf("ProgramBatch() got summary %v, want 1 op acked without failures", sum)
+	}
+}
+
+// droppingStub is a stub that never sends the operations on the prefix to the
+// server, which then never answers them.
+type droppingStub struct {
+	gpb.GRIBIClient
+	prefix string
+}
+
+func (s *droppingStub) Modify(ctx context.Context, opts ...grpc.CallOption) (gpb.GRIBI_ModifyClient, error) {
+	mc, err := s.GRIBIClient.Modify(ctx, opts...)
+	return &droppingModifyClient{GRIBI_ModifyClient: mc, prefix: s.prefix}, err
+}
+
+type droppingModifyClient struct {
+	gpb.GRIBI_ModifyClient
+	prefix string
+}
+
+func (c *droppingModifyClient) Send(req *gpb.ModifyRequest) error {
+	var ops []*gpb.AFTOperation
+	for _, op := range req.GetOperation() {
+		if op.GetIpv4().GetPrefix() != c.prefix {
+			ops = append(ops, op)
+		}
+	}
+	if len(req.GetOperation()) > 0 && len(ops) == 0 {
+		return nil
+	}
+	req.Operation = ops
+	return c.GRIBI_ModifyClient.Send(req)
+}
+
+func TestProgramBatchAfterUnansweredOp(t *testing.T) {
+	const (
+		ni      = server.DefaultNetworkInstanceName
+		dropped = "203.0.113.99/32"
+	)
+	c := startClient(t, &droppingStub{GRIBIClient: serverStub(t), prefix: dropped})
+	// An earlier operation that never gets a result.
+	c.Modify().AddEntry(t, fluent.IPv4Entry().WithNetworkInstance(ni).WithPrefix(dropped).WithNextHopGroup(1))
+
+	b := (&Batch{}).AddEntry(
+		fluent.NextHopEntry().WithNetworkInstance(ni).WithIndex(1).WithIPAddress("192.0.2.1"),
+		fluent.NextHopGroupEntry().WithNetworkInstance(ni).WithID(1).AddNextHop(1, 1),
+		fluent.IPv4Entry().WithNetworkInstance(ni).WithPrefix("198.51.100.1/32").WithNextHopGroup(1),
+		fluent.IPv4Entry().WithNetworkInstance("unknown").WithPrefix("203.0.113.1/32").WithNextHopGroup(1),
+	)
+	sum, err := ProgramBatch(context.Background(), t, c, b, &BatchOptions{Timeout: time.Minute})
+	if err != nil {
+		t.Fatalf("ProgramBatch() got error: %v", err)
+	}
+	if sum.Acked != b.Len() || len(sum.Failures) != 1 || sum.Failures[0].Index != 3 {
+		t.Errorf("ProgramBatch() got summary %v with failures %v, want %d ops acked and op 3 failed", sum, sum.Failures, b.Len())
+	}
+}
@@ -25,7 +25,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/openconfig/featureprofiles/internal/deviations"
 	"github.com/openconfig/gribigo/chk"
+	"github.com/openconfig/gribigo/client"
 	"github.com/openconfig/gribigo/constants"
 	"github.com/openconfig/gribigo/fluent"
 	"github.com/openconfig/ondatra"
@@ -78,13 +80,30 @@ func (i Uint128) Decrement() Uint128 {
 //	  t.Fatalf("Could not initialize gRIBI: %v", err)
 //	}
 type Client struct {
-	DUT         *ondatra.DUTDevice
+	DUT *ondatra.DUTDevice
+	// FIBACK requests FIB_ACK, unless --deviation_gribi_riback_only is set.
 	FIBACK      bool
 	Persistence bool
+	// AllPrimary uses the ALL_PRIMARY redundancy mode instead of
+	// ELECTED_PRIMARY, in which case the election IDs are not used.
+	AllPrimary bool
+	// InitialElectionID is the election ID that the client starts with in
+	// the ELECTED_PRIMARY redundancy mode.  Zero means 1.
+	InitialElectionID Uint128
+	// Reference mirrors every operation that the DUT accepted into an
+	// in-process gribigo reference server, as does --gribi_reference; see
+	// CompareReference.
+	Reference bool
 
 	// Unexport fields below.
 	fluentC    *fluent.GRIBIClient
 	electionID Uint128
+	rib        *RIB
+	ref        *reference
+	// noReference disables --gribi_reference for the clients of a Session.
+	noReference bool
+	// stub, if set, is used instead of the gRIBI client of the DUT.
+	stub gpb.GRIBIClient
 }
 
 // Fluent resturns the fluent client that can be used to directly call the gribi fluent APIs
@@ -98,41 +117,106 @@ type NHGOptions struct {
 	BackupNHG uint64
 }
 
+// NHOptions are optional parameters to a GRIBI next-hop.
+type NHOptions struct {
+	// Interface is the interface that the next-hop egresses through.
+	Interface string
+	// Subinterface is the subinterface of Interface, if HasSubinterface is set.
+	Subinterface    uint64
+	HasSubinterface bool
+	// MAC is the MAC address of the next-hop.
+	MAC string
+	// NetworkInstance is the network instance in which to look up the next-hop,
+	// e.g. after decapsulation.
+	NetworkInstance string
+	// Decapsulate decapsulates the packet from its IP-in-IP header.
+	Decapsulate bool
+	// EncapSrc and EncapDst encapsulate the packet in an IP-in-IP header with
+	// these source and destination addresses.
+	EncapSrc string
+	EncapDst string
+	// PushedLabels are MPLS labels pushed onto the packet.
+	PushedLabels []uint32
+	// PopTopLabel pops the top MPLS label off the packet.
+	PopTopLabel bool
+}
+
+// MPLSOptions are optional parameters to a GRIBI MPLS label entry.
+type MPLSOptions struct {
+	// PoppedLabels are the labels popped off the packet by the entry.
+	PoppedLabels []uint32
+}
+
 // Start function start establish a client connection with the gribi server.
 // By default the client is not the leader and for that function BecomeLeader
 // needs to be called.
 func (c *Client) Start(t testing.TB) error {
 	t.Helper()
-	t.Logf("Starting GRIBI connection for dut: %s", c.DUT.Name())
-	gribiC := c.DUT.RawAPIs().GRIBI().Default(t)
+	t.Logf("Starting GRIBI connection for dut: %s", c.dutName())
+	gribiC := c.stub
+	if gribiC == nil {
+		gribiC = c.DUT.RawAPIs().GRIBI().Default(t)
+	}
 	c.fluentC = fluent.NewClient()
-	c.electionID = Uint128{Low: 1, High: 0}
+	c.electionID = c.InitialElectionID
+	if c.electionID == (Uint128{}) {
+		c.electionID = Uint128{Low: 1, High: 0}
+	}
 
-	conn := c.fluentC.Connection().WithStub(gribiC).WithRedundancyMode(fluent.ElectedPrimaryClient)
-	conn.WithInitialElectionID(c.electionID.Low, c.electionID.High)
+	conn := c.fluentC.Connection().WithStub(gribiC)
+	if c.AllPrimary {
+		conn.WithRedundancyMode(fluent.AllPrimaryClients)
+	} else {
+		conn.WithRedundancyMode(fluent.ElectedPrimaryClient)
+		conn.WithInitialElectionID(c.electionID.Low, c.electionID.High)
+	}
 	if c.Persistence {
 		conn.WithPersistence()
 	}
-	if c.FIBACK {
+	if c.FIBACK && !*deviations.GRIBIRIBAckOnly {
 		conn.WithFIBACK()
 	}
 	ctx := context.Background()
 	c.fluentC.Start(ctx, t)
 	c.fluentC.StartSending(ctx, t)
-	err := c.AwaitTimeout(ctx, t, timeout)
-	return err
+	if err := c.AwaitTimeout(ctx, t, timeout); err != nil {
+		return err
+	}
+	if c.Reference || *gribiReference && !c.noReference {
+		ref, err := startReference(t, c)
+		if err != nil {
+			return err
+		}
+		c.ref = ref
+	}
+	return nil
 }
 
 // Close function closes the gribi session with the dut by stopping the fluent client.
 func (c *Client) Close(t testing.TB) {
 	t.Helper()
-	t.Logf("Closing GRIBI connection for dut: %s", c.DUT.Name())
+	t.Logf("Closing GRIBI connection for dut: %s", c.dutName())
+	if c.ref != nil {
+		if err := c.CompareReference(t); err != nil {
+			t.Error(err)
+		}
+		c.ref.stop(t)
+		c.ref = nil
+	}
 	if c.fluentC != nil {
 		c.fluentC.Stop(t)
 		c.fluentC = nil
 	}
 }
 
+// dutName returns the name of the DUT, for logging.
+func (c *Client) dutName() string {
+	if c.DUT == nil {
+		return "<none>"
+	}
+	return c.DUT.Name()
+}
+
 // AwaitTimeout calls a fluent client Await by adding a timeout to the context.
 func (c *Client) AwaitTimeout(ctx context.Context, t testing.TB, timeout time.Duration) error {
 	return awaitTimeout(ctx, t, c.fluentC, timeout)
@@ -149,20 +233,40 @@ func (c *Client) LearnElectionID(t testing.TB) (electionID Uint128) {
 func (c *Client) UpdateElectionID(t testing.TB, electionID Uint128) {
 	UpdateElectionID(t, c.fluentC, electionID)
 	c.electionID = electionID
+	c.mirrorElectionID(t, electionID)
 }
 
 // BecomeLeader learns the latest election id and the make the client leader by increasing the election id by one.
 func (c *Client) BecomeLeader(t testing.TB) (electionID Uint128) {
 	eID := BecomeLeader(t, c.fluentC)
 	c.electionID = eID
+	c.mirrorElectionID(t, eID)
 	return eID
 }
 
+// mirrorElectionID updates the election ID of the reference client, if any.
+func (c *Client) mirrorElectionID(t testing.TB, electionID Uint128) {
+	t.Helper()
+	if c.ref != nil {
+		UpdateElectionID(t, c.ref.fluentC, electionID)
+	}
+}
+
 // ElectionID returns the current electionID being set for the client.
 func (c *Client) ElectionID() Uint128 {
 	return c.electionID
 }
 
+// ProgrammedResult returns the programming result expected for a successful
+// operation: InstalledInFIB if the client requested FIB_ACK and the DUT
+// supports it, or InstalledInRIB otherwise.
+func (c *Client) ProgrammedResult() fluent.ProgrammingResult {
+	if c.FIBACK && !*deviations.GRIBIRIBAckOnly {
+		return fluent.InstalledInFIB
+	}
+	return fluent.InstalledInRIB
+}
+
 // AddNHG adds a NextHopGroupEntry with a given index, and a map of next hop entry indices to the weights,
 // in a given network instance.
 func (c *Client) AddNHG(t testing.TB, nhgIndex uint64, nhWeights map[uint64]uint64, instance string, expectedResult fluent.ProgrammingResult, opts ...*NHGOptions) {
@@ -176,39 +280,85 @@ func (c *Client) AddNHG(t testing.TB, nhgIndex uint64, nhWeights map[uint64]uint
 			nhg.WithBackupNHG(opt.BackupNHG)
 		}
 	}
-	c.fluentC.Modify().AddEntry(t, nhg)
-	if err := c.AwaitTimeout(context.Background(), t, timeout); err != nil {
-		t.Fatalf("Error waiting to add NHG: %v", err)
-	}
-	chk.HasResult(t, c.fluentC.Results(t),
-		fluent.OperationResult().
-			WithNextHopGroupOperation(nhgIndex).
-			WithOperationType(constants.Add).
-			WithProgrammingResult(expectedResult).
-			AsResult(),
-		chk.IgnoreOperationID(),
-	)
+	c.modify(t, "add NHG", constants.Add, nhg, fluent.OperationResult().
+		WithNextHopGroupOperation(nhgIndex).
+		WithOperationType(constants.Add).
+		WithProgrammingResult(expectedResult).
+		AsResult())
+}
+
+// DeleteNHG deletes a NextHopGroupEntry with a given index within a given network instance.
+func (c *Client) DeleteNHG(t testing.TB, nhgIndex uint64, instance string, expectedResult fluent.ProgrammingResult) {
+	t.Helper()
+	nhg := fluent.NextHopGroupEntry().WithNetworkInstance(instance).WithID(nhgIndex)
+	c.modify(t, "delete NHG", constants.Delete, nhg, fluent.OperationResult().
+		WithNextHopGroupOperation(nhgIndex).
+		WithOperationType(constants.Delete).
+		WithProgrammingResult(expectedResult).
+		AsResult())
 }
 
 // AddNH adds a NextHopEntry with a given index to an address within a given network instance.
-func (c *Client) AddNH(t testing.TB, nhIndex uint64, address, instance string, expectedResult fluent.ProgrammingResult) {
+// The address may be empty if the options point the next hop at an interface or MAC address.
+func (c *Client) AddNH(t testing.TB, nhIndex uint64, address, instance string, expectedResult fluent.ProgrammingResult, opts ...*NHOptions) {
 	t.Helper()
-	c.fluentC.Modify().AddEntry(t,
-		fluent.NextHopEntry().
-			WithNetworkInstance(instance).
-			WithIndex(nhIndex).
-			WithIPAddress(address))
-	if err := c.AwaitTimeout(context.Background(), t, timeout); err != nil {
-		t.Fatalf("Error waiting to add NH: %v", err)
+	c.modify(t, "add NH", constants.Add, nextHopEntry(nhIndex, address, instance, opts...), fluent.OperationResult().
+		WithNextHopOperation(nhIndex).
+		WithOperationType(constants.Add).
+		WithProgrammingResult(expectedResult).
+		AsResult())
+}
+
+// nextHopEntry returns a NextHopEntry with a given index to an address within
+// a given network instance.
+func nextHopEntry(nhIndex uint64, address, instance string, opts ...*NHOptions) fluent.GRIBIEntry {
+	nh := fluent.NextHopEntry().
+		WithNetworkInstance(instance).
+		WithIndex(nhIndex)
+	if address != "" {
+		nh.WithIPAddress(address)
 	}
-	chk.HasResult(t, c.fluentC.Results(t),
-		fluent.OperationResult().
-			WithNextHopOperation(nhIndex).
-			WithOperationType(constants.Add).
-			WithProgrammingResult(expectedResult).
-			AsResult(),
-		chk.IgnoreOperationID(),
-	)
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		switch {
+		case opt.Interface != "" && opt.HasSubinterface:
+			nh.WithSubinterfaceRef(opt.Interface, opt.Subinterface)
+		case opt.Interface != "":
+			nh.WithInterfaceRef(opt.Interface)
+		}
+		if opt.MAC != "" {
+			nh.WithMacAddress(opt.MAC)
+		}
+		if opt.NetworkInstance != "" {
+			nh.WithNextHopNetworkInstance(opt.NetworkInstance)
+		}
+		if opt.Decapsulate {
+			nh.WithDecapsulateHeader(fluent.IPinIP)
+		}
+		if opt.EncapSrc != "" || opt.EncapDst != "" {
+			nh.WithEncapsulateHeader(fluent.IPinIP).WithIPinIP(opt.EncapSrc, opt.EncapDst)
+		}
+		if len(opt.PushedLabels) > 0 {
+			nh.WithPushedLabelStack(opt.PushedLabels...)
+		}
+		if opt.PopTopLabel {
+			nh.WithPopTopLabel()
+		}
+	}
+	return nh
+}
+
+// DeleteNH deletes a NextHopEntry with a given index within a given network instance.
+func (c *Client) DeleteNH(t testing.TB, nhIndex uint64, instance string, expectedResult fluent.ProgrammingResult) {
+	t.Helper()
+	nh := fluent.NextHopEntry().WithNetworkInstance(instance).WithIndex(nhIndex)
+	c.modify(t, "delete NH", constants.Delete, nh, fluent.OperationResult().
+		WithNextHopOperation(nhIndex).
+		WithOperationType(constants.Delete).
+		WithProgrammingResult(expectedResult).
+		AsResult())
 }
 
 // AddIPv4 adds an IPv4Entry mapping a prefix to a given next hop group index within a given network instance.
@@ -220,36 +370,98 @@ func (c *Client) AddIPv4(t testing.TB, prefix string, nhgIndex uint64, instance,
 	if nhgInstance != "" && nhgInstance != instance {
 		ipv4Entry.WithNextHopGroupNetworkInstance(nhgInstance)
 	}
-	c.fluentC.Modify().AddEntry(t, ipv4Entry)
-	if err := c.AwaitTimeout(context.Background(), t, timeout); err != nil {
-		t.Fatalf("Error waiting to add IPv4: %v", err)
-	}
-	chk.HasResult(t, c.fluentC.Results(t),
-		fluent.OperationResult().
-			WithIPv4Operation(prefix).
-			WithOperationType(constants.Add).
-			WithProgrammingResult(expectedResult).
-			AsResult(),
-		chk.IgnoreOperationID(),
-	)
+	c.modify(t, "add IPv4", constants.Add, ipv4Entry, fluent.OperationResult().
+		WithIPv4Operation(prefix).
+		WithOperationType(constants.Add).
+		WithProgrammingResult(expectedResult).
+		AsResult())
 }
 
 // DeleteIPv4 deletes an IPv4Entry within a network instance, given the route's prefix
 func (c *Client) DeleteIPv4(t testing.TB, prefix string, instance string, expectedResult fluent.ProgrammingResult) {
 	t.Helper()
 	ipv4Entry := fluent.IPv4Entry().WithPrefix(prefix).WithNetworkInstance(instance)
-	c.fluentC.Modify().DeleteEntry(t, ipv4Entry)
+	c.modify(t, "delete IPv4", constants.Delete, ipv4Entry, fluent.OperationResult().
+		WithIPv4Operation(prefix).
+		WithOperationType(constants.Delete).
+		WithProgrammingResult(expectedResult).
+		AsResult())
+}
+
+// AddIPv6 adds an IPv6Entry mapping a prefix to a given next hop group index within a given network instance.
+func (c *Client) AddIPv6(t testing.TB, prefix string, nhgIndex uint64, instance, nhgInstance string, expectedResult fluent.ProgrammingResult) {
+	t.Helper()
+	ipv6Entry := newIPv6Entry(prefix, instance).withNextHopGroup(nhgIndex)
+	if nhgInstance != "" && nhgInstance != instance {
+		ipv6Entry.withNextHopGroupNetworkInstance(nhgInstance)
+	}
+	c.modify(t, "add IPv6", constants.Add, ipv6Entry, ipv6Result(constants.Add, expectedResult))
+}
+
+// DeleteIPv6 deletes an IPv6Entry within a network instance, given the route's prefix
+func (c *Client) DeleteIPv6(t testing.TB, prefix string, instance string, expectedResult fluent.ProgrammingResult) {
+	t.Helper()
+	c.modify(t, "delete IPv6", constants.Delete, newIPv6Entry(prefix, instance), ipv6Result(constants.Delete, expectedResult))
+}
+
+// AddMPLS adds a LabelEntry mapping an MPLS label to a given next hop group index within a given network instance.
+func (c *Client) AddMPLS(t testing.TB, label uint32, nhgIndex uint64, instance, nhgInstance string, expectedResult fluent.ProgrammingResult, opts ...*MPLSOptions) {
+	t.Helper()
+	labelEntry := fluent.LabelEntry().WithLabel(label).
+		WithNetworkInstance(instance).
+		WithNextHopGroup(nhgIndex)
+	if nhgInstance != "" && nhgInstance != instance {
+		labelEntry.WithNextHopGroupNetworkInstance(nhgInstance)
+	}
+	for _, opt := range opts {
+		if opt != nil && len(opt.PoppedLabels) > 0 {
+			labelEntry.WithPoppedLabelStack(opt.PoppedLabels...)
+		}
+	}
+	c.modify(t, "add MPLS", constants.Add, labelEntry, fluent.OperationResult().
+		WithMPLSOperation(uint64(label)).
+		WithOperationType(constants.Add).
+		WithProgrammingResult(expectedResult).
+		AsResult())
+}
+
+// DeleteMPLS deletes a LabelEntry within a network instance, given its label.
+func (c *Client) DeleteMPLS(t testing.TB, label uint32, instance string, expectedResult fluent.ProgrammingResult) {
+	t.Helper()
+	labelEntry := fluent.LabelEntry().WithLabel(label).WithNetworkInstance(instance)
+	c.modify(t, "delete MPLS", constants.Delete, labelEntry, fluent.OperationResult().
+		WithMPLSOperation(uint64(label)).
+		WithOperationType(constants.Delete).
+		WithProgrammingResult(expectedResult).
+		AsResult())
+}
+
+// modify sends an operation on the entry, waits for it and checks that the
+// client received the wanted result.  If the operation was expected to be
+// programmed, it is applied to the intended RIB.
+func (c *Client) modify(t testing.TB, desc string, opType constants.OpType, entry fluent.GRIBIEntry, want *client.OpResult) {
+	t.Helper()
+	n := len(c.fluentC.Results(t))
+	sendOps(t, c.fluentC, []*batchOp{{opType: opType, entry: entry}})
 	if err := c.AwaitTimeout(context.Background(), t, timeout); err != nil {
-		t.Fatalf("Error waiting to delete IPv4: %v", err)
+		t.Fatalf("Error waiting to %s: %v", desc, err)
+	}
+	// Only the results of this operation are checked, since the expected
+	// result of some entries, e.g. IPv6, does not identify the entry.
+	results := c.fluentC.Results(t)[n:]
+	// The reference server is asked before the result is checked, so that a
+	// failure comes with the answer of the reference.
+	if c.ref != nil {
+		c.ref.mirror(t, desc, opType, entry, finalResult(results))
+	}
+	chk.HasResult(t, results, want, chk.IgnoreOperationID())
+	if want.ProgrammingResult != gpb.AFTResult_RIB_PROGRAMMED && want.ProgrammingResult != gpb.AFTResult_FIB_PROGRAMMED {
+		return
+	}
+	if pb, err := entry.OpProto(); err == nil {
+		pb.Op = aftOps[opType]
+		c.IntendedRIB().Apply(pb)
 	}
-	chk.HasResult(t, c.fluentC.Results(t),
-		fluent.OperationResult().
-			WithIPv4Operation(prefix).
-			WithOperationType(constants.Delete).
-			WithProgrammingResult(expectedResult).
-			AsResult(),
-		chk.IgnoreOperationID(),
-	)
 }
 
 // FlushAll flushes all the gribi entries
@@ -257,6 +469,13 @@ func (c *Client) FlushAll(t testing.TB) {
 	if err := FlushAll(c.fluentC); err != nil {
 		t.Fatal(err)
 	}
+	c.IntendedRIB().Flush()
+	if c.ref != nil {
+		if err := FlushAll(c.ref.fluentC); err != nil {
+			t.Logf("gRIBI reference server did not flush: %v", err)
+		}
+		c.ref.rib.Flush()
+	}
 }
 
 // Flush flushes gRIBI entries specific to the provided NetworkInstance end electionID
@@ -265,6 +484,13 @@ func (c *Client) Flush(t testing.TB, electionID Uint128, networkInstanceName str
 	if err != nil {
 		t.Fatal(err)
 	}
+	c.IntendedRIB().Flush(networkInstanceName)
+	if c.ref != nil {
+		if _, err := Flush(c.ref.fluentC, electionID, networkInstanceName); err != nil {
+			t.Logf("gRIBI reference server did not flush: %v", err)
+		}
+		c.ref.rib.Flush(networkInstanceName)
+	}
 }
 
 // LearnElectionID learns the current server election id by sending
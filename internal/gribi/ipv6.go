@@ -0,0 +1,90 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"github.com/openconfig/gribigo/client"
+	"github.com/openconfig/gribigo/constants"
+	"github.com/openconfig/gribigo/fluent"
+	"google.golang.org/protobuf/proto"
+
+	aftpb "github.com/openconfig/gribi/v1/proto/gribi_aft"
+	gpb "github.com/openconfig/gribi/v1/proto/service"
+	wpb "github.com/openconfig/ygot/proto/ywrapper"
+)
+
+// ipv6Entry is a gRIBI IPv6Entry, which the fluent API does not provide yet.
+// It implements fluent.GRIBIEntry.
+type ipv6Entry struct {
+	ni string
+	pb *aftpb.Afts_Ipv6EntryKey
+}
+
+// newIPv6Entry returns an IPv6Entry for a prefix within a network instance.
+func newIPv6Entry(prefix, instance string) *ipv6Entry {
+	return &ipv6Entry{
+		ni: instance,
+		pb: &aftpb.Afts_Ipv6EntryKey{
+			Prefix:    prefix,
+			Ipv6Entry: &aftpb.Afts_Ipv6Entry{},
+		},
+	}
+}
+
+// withNextHopGroup specifies the next-hop group that the IPv6Entry points to.
+func (e *ipv6Entry) withNextHopGroup(nhgIndex uint64) *ipv6Entry {
+	e.pb.Ipv6Entry.NextHopGroup = &wpb.UintValue{Value: nhgIndex}
+	return e
+}
+
+// withNextHopGroupNetworkInstance specifies the network instance within which
+// the next-hop group of the IPv6Entry is resolved.
+func (e *ipv6Entry) withNextHopGroupNetworkInstance(instance string) *ipv6Entry {
+	e.pb.Ipv6Entry.NextHopGroupNetworkInstance = &wpb.StringValue{Value: instance}
+	return e
+}
+
+// OpProto implements fluent.GRIBIEntry, leaving the ID and election ID to be
+// populated by the fluent client.
+func (e *ipv6Entry) OpProto() (*gpb.AFTOperation, error) {
+	return &gpb.AFTOperation{
+		NetworkInstance: e.ni,
+		Entry: &gpb.AFTOperation_Ipv6{
+			Ipv6: proto.Clone(e.pb).(*aftpb.Afts_Ipv6EntryKey),
+		},
+	}, nil
+}
+
+// EntryProto implements fluent.GRIBIEntry.
+func (e *ipv6Entry) EntryProto() (*gpb.AFTEntry, error) {
+	return &gpb.AFTEntry{
+		NetworkInstance: e.ni,
+		Entry: &gpb.AFTEntry_Ipv6{
+			Ipv6: proto.Clone(e.pb).(*aftpb.Afts_Ipv6EntryKey),
+		},
+	}, nil
+}
+
+// ipv6Result returns the result of an IPv6 operation.  The fluent client does
+// not record the prefix of IPv6 operations in their result details, so this
+// matches any operation of the given type and programming result; it must only
+// be checked against the results of the operation itself, as Client.modify
+// does.
+func ipv6Result(op constants.OpType, expectedResult fluent.ProgrammingResult) *client.OpResult {
+	return fluent.OperationResult().
+		WithOperationType(op).
+		WithProgrammingResult(expectedResult).
+		AsResult()
+}
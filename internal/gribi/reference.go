@@ -0,0 +1,274 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/gribigo/client"
+	"github.com/openconfig/gribigo/constants"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/gribigo/server"
+	"github.com/openconfig/ondatra/gnmi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	gpb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+var gribiReference = flag.Bool("gribi_reference", false,
+	"Mirror the operations that the DUT accepted from every gribi.Client, except the clients of a gribi.Session, into an in-process gribigo reference server, and report where the DUT diverges from it.")
+
+// ReferenceOp is an operation whose result from the DUT diverged from that
+// of the reference server.
+type ReferenceOp struct {
+	// Desc describes the operation, e.g. "add NH".
+	Desc string
+	// Entry is the text format of the entry.
+	Entry string
+	// DUTResult and RefResult are the final programming results from the DUT
+	// and the reference server.
+	DUTResult string
+	RefResult string
+}
+
+func (o *ReferenceOp) String() string {
+	return fmt.Sprintf("%s (%s): DUT %s, reference %s", o.Desc, o.Entry, o.DUTResult, o.RefResult)
+}
+
+// reference is an in-process gribigo reference server and a client that
+// mirrors the operations of a Client into it.
+//
+// The reference server is private to the Client, so it only mirrors the
+// operations that the DUT accepted: it would accept those of a client that is
+// not the leader of the DUT, and it knows nothing of the entries of other
+// clients.  This is also why a Session, whose clients take turns as leader,
+// does not use reference servers.
+//
+// The reference server only supports the ELECTED_PRIMARY redundancy mode with
+// PRESERVE persistence, so it always uses them, and its RIB is modelled from
+// the operations that it programmed, since its Get response omits the next
+// hops of next hop groups.
+type reference struct {
+	srv     *grpc.Server
+	conn    *grpc.ClientConn
+	fluentC *fluent.GRIBIClient
+	rib     *RIB
+	// diverged are the operations whose results diverged.
+	diverged []*ReferenceOp
+}
+
+// startReference starts a reference server with the network instances of
+// the DUT, if any, and a client with the same election ID and ack type as c.
+func startReference(t testing.TB, c *Client) (*reference, error) {
+	t.Helper()
+	var vrfs []string
+	if c.DUT != nil {
+		for _, v := range gnmi.LookupAll(t, c.DUT, gnmi.OC().NetworkInstanceAny().Name().State()) {
+			if name, ok := v.Val(); ok && name != server.DefaultNetworkInstanceName {
+				vrfs = append(vrfs, name)
+			}
+		}
+	}
+	if ni := *deviations.DefaultNetworkInstance; ni != server.DefaultNetworkInstanceName && !contains(vrfs, ni) {
+		vrfs = append(vrfs, ni)
+	}
+	s, err := server.New(server.WithVRFs(vrfs))
+	if err != nil {
+		return nil, fmt.Errorf("cannot create gRIBI reference server: %w", err)
+	}
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return nil, fmt.Errorf("cannot listen for gRIBI reference server: %w", err)
+	}
+	r := &reference{srv: grpc.NewServer(), rib: NewRIB()}
+	gpb.RegisterGRIBIServer(r.srv, s)
+	go r.srv.Serve(l)
+	r.conn, err = grpc.Dial(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		r.srv.Stop()
+		return nil, fmt.Errorf("cannot dial gRIBI reference server: %w", err)
+	}
+
+	r.fluentC = fluent.NewClient()
+	conn := r.fluentC.Connection().WithStub(gpb.NewGRIBIClient(r.conn)).
+		WithRedundancyMode(fluent.ElectedPrimaryClient).
+		WithInitialElectionID(c.electionID.Low, c.electionID.High).
+		WithPersistence()
+	if c.FIBACK && !*deviations.GRIBIRIBAckOnly {
+		conn.WithFIBACK()
+	}
+	ctx := context.Background()
+	r.fluentC.Start(ctx, t)
+	r.fluentC.StartSending(ctx, t)
+	if err := awaitTimeout(ctx, t, r.fluentC, timeout); err != nil {
+		r.stop(t)
+		return nil, fmt.Errorf("gRIBI reference session failed: %w", err)
+	}
+	return r, nil
+}
+
+func (r *reference) stop(t testing.TB) {
+	r.fluentC.Stop(t)
+	r.conn.Close()
+	r.srv.Stop()
+}
+
+// mirror sends an operation that the DUT returned dutResult for to the
+// reference server, unless the DUT did not program it, and records and logs
+// whether the results diverged.
+func (r *reference) mirror(t testing.TB, desc string, opType constants.OpType, entry fluent.GRIBIEntry, dutResult gpb.AFTResult_Status) {
+	t.Helper()
+	if !programmed(dutResult) {
+		return
+	}
+	n := len(r.fluentC.Results(t))
+	sendOps(t, r.fluentC, []*batchOp{{opType: opType, entry: entry}})
+	if err := awaitTimeout(context.Background(), t, r.fluentC, timeout); err != nil {
+		t.Logf("gRIBI reference server did not respond to %s: %v", desc, err)
+		return
+	}
+	refResult := finalResult(r.fluentC.Results(t)[n:])
+	if programmed(refResult) {
+		if pb, err := entry.OpProto(); err == nil {
+			pb.Op = aftOps[opType]
+			r.rib.Apply(pb)
+		}
+	}
+	if refResult != dutResult {
+		r.record(t, &ReferenceOp{Desc: desc, Entry: entryText(entry), DUTResult: dutResult.String(), RefResult: refResult.String()})
+	}
+}
+
+func (r *reference) record(t testing.TB, op *ReferenceOp) {
+	t.Helper()
+	t.Logf("gRIBI DUT diverged from the reference server: %v", op)
+	r.diverged = append(r.diverged, op)
+}
+
+// mirrorBatch programs the operations of a batch that the DUT programmed,
+// with the summary dutSum, into the reference server, and records those that
+// the reference server failed.
+func (r *reference) mirrorBatch(t testing.TB, b *Batch, opts *BatchOptions, dutSum *BatchSummary) {
+	t.Helper()
+	dutFailed := make(map[int]bool)
+	for _, f := range dutSum.Failures {
+		dutFailed[f.Index] = true
+	}
+	// indices maps the index of each operation of the mirrored batch to that
+	// in b.
+	mirrored, indices := &Batch{}, []int{}
+	for i, op := range b.ops {
+		if !dutFailed[i] {
+			mirrored.ops = append(mirrored.ops, op)
+			indices = append(indices, i)
+		}
+	}
+	refSum, err := ProgramBatch(context.Background(), t, r.fluentC, mirrored, opts)
+	if err != nil {
+		t.Logf("gRIBI reference server did not program the batch: %v", err)
+		return
+	}
+	refFailures := make(map[int]string)
+	for _, f := range refSum.Failures {
+		refFailures[f.Index] = f.Result
+	}
+	for j, op := range mirrored.ops {
+		refResult, refFailed := refFailures[j]
+		if !refFailed {
+			if pb, err := op.entry.OpProto(); err == nil {
+				pb.Op = aftOps[op.opType]
+				r.rib.Apply(pb)
+			}
+			continue
+		}
+		r.record(t, &ReferenceOp{
+			Desc:      fmt.Sprintf("batch op %d %s", indices[j], op.opType),
+			Entry:     entryText(op.entry),
+			DUTResult: "PROGRAMMED",
+			RefResult: refResult,
+		})
+	}
+}
+
+// programmed returns whether a programming result is a success.
+func programmed(status gpb.AFTResult_Status) bool {
+	return status == gpb.AFTResult_RIB_PROGRAMMED || status == gpb.AFTResult_FIB_PROGRAMMED
+}
+
+// finalResult returns the last programming result of the results of an
+// operation.
+func finalResult(results []*client.OpResult) gpb.AFTResult_Status {
+	status := gpb.AFTResult_UNSET
+	for _, r := range results {
+		if r.ProgrammingResult != gpb.AFTResult_UNSET {
+			status = r.ProgrammingResult
+		}
+	}
+	return status
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ReferenceOps returns the operations whose results from the DUT diverged
+// from those of the reference server so far, or nil if the client does not
+// use a reference server.
+func (c *Client) ReferenceOps() []*ReferenceOp {
+	if c.ref == nil {
+		return nil
+	}
+	return append([]*ReferenceOp(nil), c.ref.diverged...)
+}
+
+// CompareReference compares the DUT with the reference server that the
+// client mirrors its operations into: the results of every operation so far,
+// and the entries of the gRIBI Get response of the DUT with those that the
+// reference server programmed.  Entries of the Get response that the
+// reference server did not program, e.g. those of other clients, are ignored.
+// It returns an error describing every divergence, with the answer of the
+// reference server, or nil if there is none or the client does not use a
+// reference server.
+func (c *Client) CompareReference(t testing.TB) error {
+	t.Helper()
+	if c.ref == nil {
+		return nil
+	}
+	var diffs []string
+	for _, op := range c.ref.diverged {
+		diffs = append(diffs, op.String())
+	}
+	res, err := c.fluentC.Get().AllNetworkInstances().WithAFT(fluent.AllAFTs).Send()
+	if err != nil {
+		return fmt.Errorf("gRIBI Get failed: %w", err)
+	}
+	diffs = append(diffs, Diff(c.ref.rib, RIBFromGet(res), "DUT gRIBI Get", false)...)
+	if len(diffs) > 0 {
+		return fmt.Errorf("%d divergences from the gRIBI reference server:\n  %s", len(diffs), strings.Join(diffs, "\n  "))
+	}
+	return nil
+}
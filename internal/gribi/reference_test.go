@@ -0,0 +1,95 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/gribigo/server"
+)
+
+func TestCompareReference(t *testing.T) {
+	const ni = server.DefaultNetworkInstanceName
+	// The "DUT" is a reference server with a VRF that the in-process reference
+	// of the client does not know, so that the two diverge on it.
+	c := &Client{
+		FIBACK:      true,
+		Persistence: true,
+		Reference:   true,
+		stub:        serverStub(t, server.WithVRFs([]string{"VRF-A"})),
+	}
+	if err := c.Start(t); err != nil {
+		t.Fatalf("Start() got error: %v", err)
+	}
+	t.Cleanup(func() { c.Close(t) })
+	c.BecomeLeader(t)
+
+	// Next hop groups are not compared, since the Get response of the "DUT"
+	// omits their next hops.
+	c.AddNH(t, 1, "192.0.2.1", ni, fluent.InstalledInFIB)
+	c.DeleteNH(t, 3, ni, fluent.InstalledInFIB)
+	if err := c.CompareReference(t); err != nil {
+		t.Errorf("CompareReference() got error: %v", err)
+	}
+
+	c.AddNH(t, 2, "192.0.2.2", "VRF-A", fluent.InstalledInFIB)
+	ops := c.ReferenceOps()
+	if len(ops) != 1 || ops[0].DUTResult != "FIB_PROGRAMMED" || ops[0].RefResult != "FAILED" {
+		t.Fatalf("ReferenceOps() got %v, want add NH in VRF-A FIB_PROGRAMMED by the DUT and FAILED by the reference", ops)
+	}
+	err := c.CompareReference(t)
+	if err == nil {
+		t.Fatalf("CompareReference() got no error, want divergences")
+	}
+	for _, want := range []string{"1 divergences", "add NH"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("CompareReference() got error %v, want it to contain %q", err, want)
+		}
+	}
+	c.ref.diverged = nil // Close reports divergences as errors.
+}
+
+func TestCompareReferenceOtherClients(t *testing.T) {
+	const ni = server.DefaultNetworkInstanceName
+	stub := serverStub(t)
+	var clients []*Client
+	for i := 0; i < 2; i++ {
+		c := &Client{FIBACK: true, Persistence: true, Reference: true, stub: stub}
+		if err := c.Start(t); err != nil {
+			t.Fatalf("Start() got error: %v", err)
+		}
+		t.Cleanup(func() { c.Close(t) })
+		clients = append(clients, c)
+	}
+	leader, other := clients[0], clients[1]
+	leader.BecomeLeader(t)
+	leader.AddNH(t, 1, "192.0.2.1", ni, fluent.InstalledInFIB)
+	// The DUT rejects the operations of a client that is not the leader,
+	// which the reference of the client is not asked about.
+	other.AddNH(t, 2, "192.0.2.2", ni, fluent.ProgrammingFailed)
+
+	for i, c := range clients {
+		if ops := c.ReferenceOps(); len(ops) > 0 {
+			t.Errorf("Client %d ReferenceOps() got %v, want none", i, ops)
+		}
+		// The Get response has the next hop of the leader, which the reference
+		// of the other client does not know.
+		if err := c.CompareReference(t); err != nil {
+			t.Errorf("Client %d CompareReference() got error: %v", i, err)
+		}
+	}
+}
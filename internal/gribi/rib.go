@@ -0,0 +1,492 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra/gnmi"
+	"github.com/openconfig/ondatra/gnmi/oc"
+
+	aftpb "github.com/openconfig/gribi/v1/proto/gribi_aft"
+	gpb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+// RIB is a gRIBI RIB of next hops, next hop groups and IPv4, IPv6 and MPLS
+// entries, keyed by network instance and by the indices, IDs, prefixes and
+// labels given by the gRIBI client.  It is used to model the entries that a Client intends to be
+// installed and to compare them with those that the DUT reports.
+type RIB struct {
+	NetworkInstances map[string]*NetworkInstanceRIB `json:"network_instances"`
+}
+
+// NetworkInstanceRIB is the RIB of one network instance.
+type NetworkInstanceRIB struct {
+	NextHops      map[uint64]*NH    `json:"next_hops,omitempty"`
+	NextHopGroups map[uint64]*NHG   `json:"next_hop_groups,omitempty"`
+	IPv4          map[string]*Route `json:"ipv4,omitempty"`
+	IPv6          map[string]*Route `json:"ipv6,omitempty"`
+	MPLS          map[uint64]*Route `json:"mpls,omitempty"`
+	// dutKeyed is set if the next hops and next hop groups are keyed by the
+	// indices and IDs chosen by the DUT, since it did not report those given
+	// by the gRIBI client.
+	dutKeyed bool
+}
+
+// NH is a next hop in a RIB.
+type NH struct {
+	IPAddress         string `json:"ip_address,omitempty"`
+	MACAddress        string `json:"mac_address,omitempty"`
+	Interface         string `json:"interface,omitempty"`
+	Subinterface      uint64 `json:"subinterface,omitempty"`
+	NetworkInstance   string `json:"network_instance,omitempty"`
+	DecapsulateHeader string `json:"decapsulate_header,omitempty"`
+	EncapsulateHeader string `json:"encapsulate_header,omitempty"`
+	TunnelSrc         string `json:"tunnel_src,omitempty"`
+	TunnelDst         string `json:"tunnel_dst,omitempty"`
+}
+
+// NHG is a next hop group in a RIB.
+type NHG struct {
+	// NextHops maps the index of each next hop to its weight.
+	NextHops  map[uint64]uint64 `json:"next_hops"`
+	BackupNHG uint64            `json:"backup_nhg,omitempty"`
+}
+
+// Route is an IPv4, IPv6 or MPLS entry in a RIB.
+type Route struct {
+	NHG uint64 `json:"nhg"`
+	// NHGNetworkInstance is the network instance of the next hop group, or
+	// empty if it is that of the route.
+	NHGNetworkInstance string `json:"nhg_network_instance,omitempty"`
+}
+
+// NewRIB returns an empty RIB.
+func NewRIB() *RIB {
+	return &RIB{NetworkInstances: make(map[string]*NetworkInstanceRIB)}
+}
+
+// NetworkInstance returns the RIB of the network instance, creating it if
+// needed.
+func (r *RIB) NetworkInstance(name string) *NetworkInstanceRIB {
+	ni, ok := r.NetworkInstances[name]
+	if !ok {
+		ni = &NetworkInstanceRIB{
+			NextHops:      make(map[uint64]*NH),
+			NextHopGroups: make(map[uint64]*NHG),
+			IPv4:          make(map[string]*Route),
+			IPv6:          make(map[string]*Route),
+			MPLS:          make(map[uint64]*Route),
+		}
+		r.NetworkInstances[name] = ni
+	}
+	return ni
+}
+
+// aftEntry is implemented by both gRIBI AFTOperations and AFTEntries.
+type aftEntry interface {
+	GetNetworkInstance() string
+	GetIpv4() *aftpb.Afts_Ipv4EntryKey
+	GetIpv6() *aftpb.Afts_Ipv6EntryKey
+	GetMpls() *aftpb.Afts_LabelEntryKey
+	GetNextHop() *aftpb.Afts_NextHopKey
+	GetNextHopGroup() *aftpb.Afts_NextHopGroupKey
+}
+
+// Apply applies a successful gRIBI operation to the RIB.  Other entries than
+// those of a RIB, e.g. MAC entries, are ignored.
+func (r *RIB) Apply(op *gpb.AFTOperation) {
+	if op.GetOp() == gpb.AFTOperation_DELETE {
+		r.delete(op)
+		return
+	}
+	r.set(op)
+}
+
+func (r *RIB) set(e aftEntry) {
+	name := e.GetNetworkInstance()
+	ni := r.NetworkInstance(name)
+	switch {
+	case e.GetIpv4() != nil:
+		ni.IPv4[e.GetIpv4().GetPrefix()] = newRoute(name, e.GetIpv4().GetIpv4Entry().GetNextHopGroup().GetValue(), e.GetIpv4().GetIpv4Entry().GetNextHopGroupNetworkInstance().GetValue())
+	case e.GetIpv6() != nil:
+		ni.IPv6[e.GetIpv6().GetPrefix()] = newRoute(name, e.GetIpv6().GetIpv6Entry().GetNextHopGroup().GetValue(), e.GetIpv6().GetIpv6Entry().GetNextHopGroupNetworkInstance().GetValue())
+	case e.GetMpls() != nil:
+		ni.MPLS[e.GetMpls().GetLabelUint64()] = newRoute(name, e.GetMpls().GetLabelEntry().GetNextHopGroup().GetValue(), e.GetMpls().GetLabelEntry().GetNextHopGroupNetworkInstance().GetValue())
+	case e.GetNextHop() != nil:
+		nh := e.GetNextHop().GetNextHop()
+		ni.NextHops[e.GetNextHop().GetIndex()] = &NH{
+			IPAddress:         nh.GetIpAddress().GetValue(),
+			MACAddress:        nh.GetMacAddress().GetValue(),
+			Interface:         nh.GetInterfaceRef().GetInterface().GetValue(),
+			Subinterface:      nh.GetInterfaceRef().GetSubinterface().GetValue(),
+			NetworkInstance:   nh.GetNetworkInstance().GetValue(),
+			DecapsulateHeader: headerName(nh.GetDecapsulateHeader().String()),
+			EncapsulateHeader: headerName(nh.GetEncapsulateHeader().String()),
+			TunnelSrc:         nh.GetIpInIp().GetSrcIp().GetValue(),
+			TunnelDst:         nh.GetIpInIp().GetDstIp().GetValue(),
+		}
+	case e.GetNextHopGroup() != nil:
+		nhg := e.GetNextHopGroup().GetNextHopGroup()
+		m := &NHG{NextHops: make(map[uint64]uint64), BackupNHG: nhg.GetBackupNextHopGroup().GetValue()}
+		for _, nh := range nhg.GetNextHop() {
+			m.NextHops[nh.GetIndex()] = nh.GetNextHop().GetWeight().GetValue()
+		}
+		ni.NextHopGroups[e.GetNextHopGroup().GetId()] = m
+	}
+}
+
+func (r *RIB) delete(e aftEntry) {
+	ni, ok := r.NetworkInstances[e.GetNetworkInstance()]
+	if !ok {
+		return
+	}
+	switch {
+	case e.GetIpv4() != nil:
+		delete(ni.IPv4, e.GetIpv4().GetPrefix())
+	case e.GetIpv6() != nil:
+		delete(ni.IPv6, e.GetIpv6().GetPrefix())
+	case e.GetMpls() != nil:
+		delete(ni.MPLS, e.GetMpls().GetLabelUint64())
+	case e.GetNextHop() != nil:
+		delete(ni.NextHops, e.GetNextHop().GetIndex())
+	case e.GetNextHopGroup() != nil:
+		delete(ni.NextHopGroups, e.GetNextHopGroup().GetId())
+	}
+}
+
+// Flush removes all entries of the named network instances, or of all of
+// them if none are named.
+func (r *RIB) Flush(names ...string) {
+	if len(names) == 0 {
+		r.NetworkInstances = make(map[string]*NetworkInstanceRIB)
+	}
+	for _, name := range names {
+		delete(r.NetworkInstances, name)
+	}
+}
+
+func newRoute(ni string, nhg uint64, nhgNI string) *Route {
+	if nhgNI == ni {
+		nhgNI = ""
+	}
+	return &Route{NHG: nhg, NHGNetworkInstance: nhgNI}
+}
+
+// headerName returns the name of an encapsulation header type, without the
+// prefix of the gRIBI enum, or empty if it is unset.
+func headerName(s string) string {
+	s = strings.TrimPrefix(s, "OPENCONFIGAFTTYPESENCAPSULATIONHEADERTYPE_")
+	if s == "UNSET" {
+		return ""
+	}
+	return s
+}
+
+// RIBFromGet returns the RIB of the entries in a gRIBI Get response.
+func RIBFromGet(res *gpb.GetResponse) *RIB {
+	r := NewRIB()
+	for _, e := range res.GetEntry() {
+		r.set(e)
+	}
+	return r
+}
+
+// RIBFromAFT returns the RIB of the gNMI AFT telemetry of the named network
+// instances.  Next hops and next hop groups are keyed by the programmed index
+// and ID that the gRIBI client gave them, since the AFT index and ID are
+// chosen by the DUT.  If the DUT does not report them for every next hop and
+// next hop group of a network instance, those of the DUT are kept, and Diff
+// matches the next hops by content instead.
+func RIBFromAFT(afts map[string]*oc.NetworkInstance_Afts) *RIB {
+	// nhgIDs maps the AFT next hop group IDs of each network instance to the
+	// programmed IDs, and nhIndices the next hop indices.
+	nhgIDs := make(map[string]map[uint64]uint64)
+	nhIndices := make(map[string]map[uint64]uint64)
+	dutKeyed := make(map[string]bool)
+	for name, aft := range afts {
+		nhgIDs[name] = make(map[uint64]uint64)
+		for id, nhg := range aft.NextHopGroup {
+			nhgIDs[name][id] = nhg.GetProgrammedId()
+			if nhg.ProgrammedId == nil {
+				dutKeyed[name] = true
+			}
+		}
+		nhIndices[name] = make(map[uint64]uint64)
+		for index, nh := range aft.NextHop {
+			nhIndices[name][index] = nh.GetProgrammedIndex()
+			if nh.ProgrammedIndex == nil {
+				dutKeyed[name] = true
+			}
+		}
+		if dutKeyed[name] {
+			for id := range nhgIDs[name] {
+				nhgIDs[name][id] = id
+			}
+			for index := range nhIndices[name] {
+				nhIndices[name][index] = index
+			}
+		}
+	}
+	programmedID := func(ids map[string]map[uint64]uint64, ni string, id uint64) uint64 {
+		if p, ok := ids[ni][id]; ok {
+			return p
+		}
+		return id
+	}
+	route := func(ni string, nhg *uint64, nhgNI *string) *Route {
+		nhgName := ni
+		if nhgNI != nil && *nhgNI != "" {
+			nhgName = *nhgNI
+		}
+		var id uint64
+		if nhg != nil {
+			id = programmedID(nhgIDs, nhgName, *nhg)
+		}
+		if nhgNI == nil {
+			return newRoute(ni, id, "")
+		}
+		return newRoute(ni, id, *nhgNI)
+	}
+
+	r := NewRIB()
+	for name, aft := range afts {
+		ni := r.NetworkInstance(name)
+		ni.dutKeyed = dutKeyed[name]
+		for prefix, e := range aft.Ipv4Entry {
+			ni.IPv4[prefix] = route(name, e.NextHopGroup, e.NextHopGroupNetworkInstance)
+		}
+		for prefix, e := range aft.Ipv6Entry {
+			ni.IPv6[prefix] = route(name, e.NextHopGroup, e.NextHopGroupNetworkInstance)
+		}
+		for label, e := range aft.LabelEntry {
+			// Reserved labels, which are enums, cannot be programmed by gRIBI.
+			if l, ok := label.(oc.UnionUint32); ok {
+				ni.MPLS[uint64(l)] = route(name, e.NextHopGroup, e.NextHopGroupNetworkInstance)
+			}
+		}
+		for index, nh := range aft.NextHop {
+			m := &NH{
+				IPAddress:       nh.GetIpAddress(),
+				MACAddress:      nh.GetMacAddress(),
+				NetworkInstance: nh.GetNetworkInstance(),
+			}
+			if ref := nh.GetInterfaceRef(); ref != nil {
+				m.Interface = ref.GetInterface()
+				m.Subinterface = uint64(ref.GetSubinterface())
+			}
+			if nh.DecapsulateHeader != oc.Aft_EncapsulationHeaderType_UNSET {
+				m.DecapsulateHeader = nh.DecapsulateHeader.String()
+			}
+			if nh.EncapsulateHeader != oc.Aft_EncapsulationHeaderType_UNSET {
+				m.EncapsulateHeader = nh.EncapsulateHeader.String()
+			}
+			if ipInIP := nh.GetIpInIp(); ipInIP != nil {
+				m.TunnelSrc = ipInIP.GetSrcIp()
+				m.TunnelDst = ipInIP.GetDstIp()
+			}
+			ni.NextHops[nhIndices[name][index]] = m
+		}
+		for id, nhg := range aft.NextHopGroup {
+			m := &NHG{NextHops: make(map[uint64]uint64)}
+			if nhg.BackupNextHopGroup != nil {
+				m.BackupNHG = programmedID(nhgIDs, name, nhg.GetBackupNextHopGroup())
+			}
+			for index, nh := range nhg.NextHop {
+				m.NextHops[programmedID(nhIndices, name, index)] = nh.GetWeight()
+			}
+			ni.NextHopGroups[nhgIDs[name][id]] = m
+		}
+	}
+	return r
+}
+
+// Diff returns the discrepancies between the RIBs want and got, one per entry,
+// naming got as name.  Entries of got that are not in want are reported only
+// if unexpected is set.  If it is not, the entries of got may also have more
+// fields set than those of want, e.g. the MAC address and interface that the
+// DUT resolved a next hop to.
+//
+// If the next hops and next hop groups of got are keyed by the DUT, as
+// returned by RIBFromAFT, every next hop of want is matched with any next hop
+// of got with the same fields, next hop groups are not compared, and neither
+// are the next hop group IDs of entries.
+func Diff(want, got *RIB, name string, unexpected bool) []string {
+	var diffs []string
+	equal := reflect.DeepEqual
+	if !unexpected {
+		equal = setFieldsEqual
+	}
+	report := func(ni, entry string, w, g interface{}, wok, gok bool) {
+		prefix := fmt.Sprintf("network instance %q, %s", ni, entry)
+		switch {
+		case wok && !gok:
+			diffs = append(diffs, fmt.Sprintf("%s: missing from %s, want %+v", prefix, name, w))
+		case !wok && gok && unexpected:
+			diffs = append(diffs, fmt.Sprintf("%s: unexpected in %s: %+v", prefix, name, g))
+		case wok && gok && !equal(w, g):
+			diffs = append(diffs, fmt.Sprintf("%s: %s has %+v, want %+v", prefix, name, g, w))
+		}
+	}
+	empty := NewRIB()
+	for _, niName := range unionKeys(want.NetworkInstances, got.NetworkInstances) {
+		w, ok := want.NetworkInstances[niName]
+		if !ok {
+			w = empty.NetworkInstance(niName)
+		}
+		g, ok := got.NetworkInstances[niName]
+		if !ok {
+			g = empty.NetworkInstance(niName)
+		}
+		// route returns an entry as compared, without its next hop group ID if
+		// that is chosen by the DUT.
+		route := func(r *Route) *Route {
+			if r == nil || !g.dutKeyed {
+				return r
+			}
+			return &Route{NHGNetworkInstance: r.NHGNetworkInstance}
+		}
+		for _, prefix := range unionKeys(w.IPv4, g.IPv4) {
+			wr, wok := w.IPv4[prefix]
+			gr, gok := g.IPv4[prefix]
+			report(niName, "IPv4 entry "+prefix, route(wr), route(gr), wok, gok)
+		}
+		for _, prefix := range unionKeys(w.IPv6, g.IPv6) {
+			wr, wok := w.IPv6[prefix]
+			gr, gok := g.IPv6[prefix]
+			report(niName, "IPv6 entry "+prefix, route(wr), route(gr), wok, gok)
+		}
+		for _, label := range unionKeys(w.MPLS, g.MPLS) {
+			wr, wok := w.MPLS[label]
+			gr, gok := g.MPLS[label]
+			report(niName, fmt.Sprintf("MPLS entry %d", label), route(wr), route(gr), wok, gok)
+		}
+		if g.dutKeyed {
+			for _, index := range unionKeys(w.NextHops, nil) {
+				wn := w.NextHops[index]
+				if !containsNH(g.NextHops, wn, equal) {
+					report(niName, fmt.Sprintf("next hop %d", index), wn, nil, true, false)
+				}
+			}
+			continue
+		}
+		for _, id := range unionKeys(w.NextHopGroups, g.NextHopGroups) {
+			wn, wok := w.NextHopGroups[id]
+			gn, gok := g.NextHopGroups[id]
+			report(niName, fmt.Sprintf("next hop group %d", id), wn, gn, wok, gok)
+		}
+		for _, index := range unionKeys(w.NextHops, g.NextHops) {
+			wn, wok := w.NextHops[index]
+			gn, gok := g.NextHops[index]
+			report(niName, fmt.Sprintf("next hop %d", index), wn, gn, wok, gok)
+		}
+	}
+	return diffs
+}
+
+// containsNH returns whether any next hop of nhs is equal to nh.
+func containsNH(nhs map[uint64]*NH, nh *NH, equal func(w, g interface{}) bool) bool {
+	for _, n := range nhs {
+		if equal(nh, n) {
+			return true
+		}
+	}
+	return false
+}
+
+// setFieldsEqual returns whether every field of the entry w that is set has
+// the same value in the entry g, both pointers to structs of the same type.
+func setFieldsEqual(w, g interface{}) bool {
+	wv, gv := reflect.ValueOf(w).Elem(), reflect.ValueOf(g).Elem()
+	for i := 0; i < wv.NumField(); i++ {
+		if f := wv.Field(i); !f.IsZero() && !reflect.DeepEqual(f.Interface(), gv.Field(i).Interface()) {
+			return false
+		}
+	}
+	return true
+}
+
+// unionKeys returns the sorted union of the keys of two maps.
+func unionKeys[K string | uint64, V any](a, b map[K]V) []K {
+	var keys []K
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// IntendedRIB returns the model of the entries that the client intends to be
+// installed on the DUT: those of every operation that the helpers of the
+// client expected to succeed and were acknowledged, less any flushed.
+func (c *Client) IntendedRIB() *RIB {
+	if c.rib == nil {
+		c.rib = NewRIB()
+	}
+	return c.rib
+}
+
+// Verify checks that the intended RIB of the client, the gRIBI Get response
+// of the DUT and its gNMI AFT telemetry agree on every next hop, next hop
+// group and IPv4, IPv6 and MPLS entry, including weights and backup next hop
+// groups.  The error lists every discrepancy by entry.  Entries in the AFT
+// telemetry that are not in the intended RIB, e.g. those of other protocols,
+// are ignored, and so are the fields that the DUT resolved, e.g. the MAC
+// address of a next hop.
+func (c *Client) Verify(t testing.TB) error {
+	t.Helper()
+	return c.verifyRIB(t, c.IntendedRIB())
+}
+
+// verifyRIB compares want with the gRIBI Get response received by the client
+// and the gNMI AFT telemetry of its DUT, if any.
+func (c *Client) verifyRIB(t testing.TB, want *RIB) error {
+	t.Helper()
+	res, err := c.fluentC.Get().AllNetworkInstances().WithAFT(fluent.AllAFTs).Send()
+	if err != nil {
+		return fmt.Errorf("gRIBI Get failed: %w", err)
+	}
+	diffs := Diff(want, RIBFromGet(res), "gRIBI Get", true)
+
+	if c.DUT != nil {
+		afts := make(map[string]*oc.NetworkInstance_Afts)
+		for name := range want.NetworkInstances {
+			if aft, ok := gnmi.Lookup(t, c.DUT, gnmi.OC().NetworkInstance(name).Afts().State()).Val(); ok {
+				afts[name] = aft
+			}
+		}
+		diffs = append(diffs, diffAFT(want, afts)...)
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("%d discrepancies with the intended RIB:\n  %s", len(diffs), strings.Join(diffs, "\n  "))
+	}
+	return nil
+}
+
+// diffAFT returns the discrepancies between want and the gNMI AFT telemetry
+// of its network instances.
+func diffAFT(want *RIB, afts map[string]*oc.NetworkInstance_Afts) []string {
+	return Diff(want, RIBFromAFT(afts), "gNMI AFT", false)
+}
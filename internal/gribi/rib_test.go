@@ -0,0 +1,163 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra/gnmi/oc"
+	"github.com/openconfig/ygot/ygot"
+
+	gpb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+func TestRIBFromGet(t *testing.T) {
+	const ni = "DEFAULT"
+	b := (&Batch{}).AddEntry(
+		fluent.NextHopEntry().WithNetworkInstance(ni).WithIndex(1).WithIPAddress("192.0.2.1"),
+		fluent.NextHopEntry().WithNetworkInstance(ni).WithIndex(2).WithIPAddress("192.0.2.2"),
+		fluent.NextHopGroupEntry().WithNetworkInstance(ni).WithID(2).AddNextHop(2, 1),
+		fluent.NextHopGroupEntry().WithNetworkInstance(ni).WithID(1).AddNextHop(1, 3).WithBackupNHG(2),
+		fluent.IPv4Entry().WithNetworkInstance(ni).WithPrefix("198.51.100.1/32").WithNextHopGroup(1),
+		newIPv6Entry("2001:db8::1/128", ni).withNextHopGroup(1).withNextHopGroupNetworkInstance(ni),
+		fluent.LabelEntry().WithNetworkInstance(ni).WithLabel(100).WithNextHopGroup(1),
+		fluent.IPv4Entry().WithNetworkInstance(ni).WithPrefix("198.51.100.2/32").WithNextHopGroup(1),
+	).DeleteEntry(
+		fluent.IPv4Entry().WithNetworkInstance(ni).WithPrefix("198.51.100.2/32"),
+	)
+	want := NewRIB()
+	res := &gpb.GetResponse{}
+	for i, op := range b.ops {
+		pb, err := op.entry.OpProto()
+		if err != nil {
+			t.Fatalf("OpProto() got error: %v", err)
+		}
+		pb.Op = aftOps[op.opType]
+		want.Apply(pb)
+		// The last two operations add and delete the same entry, which is
+		// then not in the Get response.
+		if i < len(b.ops)-2 {
+			e, err := op.entry.EntryProto()
+			if err != nil {
+				t.Fatalf("EntryProto() got error: %v", err)
+			}
+			res.Entry = append(res.Entry, e)
+		}
+	}
+	if got := len(want.NetworkInstance(ni).IPv4); got != 1 {
+		t.Errorf("Intended RIB got %d IPv4 entries, want 1", got)
+	}
+	if got := len(want.NetworkInstance(ni).MPLS); got != 1 {
+		t.Errorf("Intended RIB got %d MPLS entries, want 1", got)
+	}
+
+	got := RIBFromGet(res)
+	if diffs := Diff(want, got, "gRIBI Get", true); len(diffs) > 0 {
+		t.Errorf("Diff() got discrepancies: %v", diffs)
+	}
+
+	got.NetworkInstance(ni).NextHopGroups[1].NextHops[1] = 4
+	got.NetworkInstance(ni).IPv4["203.0.113.1/32"] = &Route{NHG: 1}
+	delete(got.NetworkInstance(ni).NextHops, 2)
+	wantDiffs := []string{
+		`network instance "DEFAULT", IPv4 entry 203.0.113.1/32: unexpected in gRIBI Get`,
+		`network instance "DEFAULT", next hop group 1: gRIBI Get has &{NextHops:map[1:4] BackupNHG:2}, want &{NextHops:map[1:3] BackupNHG:2}`,
+		`network instance "DEFAULT", next hop 2: missing from gRIBI Get`,
+	}
+	diffs := Diff(want, got, "gRIBI Get", true)
+	if len(diffs) != len(wantDiffs) {
+		t.Fatalf("Diff() got discrepancies %q, want %d", diffs, len(wantDiffs))
+	}
+	for i, d := range diffs {
+		if !strings.HasPrefix(d, wantDiffs[i]) {
+			t.Errorf("Diff() got discrepancy %q, want prefix %q", d, wantDiffs[i])
+		}
+	}
+}
+
+func TestRIBFromAFT(t *testing.T) {
+	aft := &oc.NetworkInstance_Afts{}
+	nh := aft.GetOrCreateNextHop(1001)
+	nh.IpAddress = ygot.String("192.0.2.1")
+	nh.ProgrammedIndex = ygot.Uint64(1)
+	nhg := aft.GetOrCreateNextHopGroup(2001)
+	nhg.ProgrammedId = ygot.Uint64(1)
+	nhg.GetOrCreateNextHop(1001).Weight = ygot.Uint64(3)
+	aft.GetOrCreateIpv4Entry("198.51.100.1/32").NextHopGroup = ygot.Uint64(2001)
+	aft.GetOrCreateIpv4Entry("192.0.2.0/24").NextHopGroup = ygot.Uint64(3000)
+	aft.GetOrCreateLabelEntry(oc.UnionUint32(100)).NextHopGroup = ygot.Uint64(2001)
+
+	got := RIBFromAFT(map[string]*oc.NetworkInstance_Afts{"DEFAULT": aft})
+	want := NewRIB()
+	ni := want.NetworkInstance("DEFAULT")
+	ni.NextHops[1] = &NH{IPAddress: "192.0.2.1"}
+	ni.NextHopGroups[1] = &NHG{NextHops: map[uint64]uint64{1: 3}}
+	ni.IPv4["198.51.100.1/32"] = &Route{NHG: 1}
+	ni.MPLS[100] = &Route{NHG: 1}
+	if diffs := Diff(want, got, "gNMI AFT", false); len(diffs) > 0 {
+		t.Errorf("Diff() got discrepancies: %v", diffs)
+	}
+	ni.IPv4["198.51.100.2/32"] = &Route{NHG: 1}
+	if diff := cmp.Diff([]string{`network instance "DEFAULT", IPv4 entry 198.51.100.2/32: missing from gNMI AFT, want &{NHG:1 NHGNetworkInstance:}`}, Diff(want, got, "gNMI AFT", false)); diff != "" {
+		t.Errorf("Diff() unexpected diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestDiffAFTResolvedNextHops(t *testing.T) {
+	want := NewRIB()
+	ni := want.NetworkInstance("DEFAULT")
+	ni.NextHops[1] = &NH{IPAddress: "192.0.2.1"}
+	ni.NextHops[2] = &NH{IPAddress: "192.0.2.2"}
+	ni.NextHopGroups[1] = &NHG{NextHops: map[uint64]uint64{1: 1, 2: 3}}
+	ni.IPv4["198.51.100.1/32"] = &Route{NHG: 1}
+
+	// newAFT returns the AFT of the DUT, with the next hops resolved to a MAC
+	// address and interface, and the programmed indices and IDs if set.
+	newAFT := func(programmed bool) *oc.NetworkInstance_Afts {
+		aft := &oc.NetworkInstance_Afts{}
+		nhg := aft.GetOrCreateNextHopGroup(2001)
+		for i, ip := range []string{"192.0.2.1", "192.0.2.2"} {
+			index := uint64(1001 + i)
+			nh := aft.GetOrCreateNextHop(index)
+			nh.IpAddress = ygot.String(ip)
+			nh.MacAddress = ygot.String("02:00:00:00:00:01")
+			nh.GetOrCreateInterfaceRef().Interface = ygot.String("Ethernet1")
+			nhg.GetOrCreateNextHop(index).Weight = ygot.Uint64(uint64(1 + 2*i))
+			if programmed {
+				nh.ProgrammedIndex = ygot.Uint64(uint64(1 + i))
+			}
+		}
+		if programmed {
+			nhg.ProgrammedId = ygot.Uint64(1)
+		}
+		aft.GetOrCreateIpv4Entry("198.51.100.1/32").NextHopGroup = ygot.Uint64(2001)
+		return aft
+	}
+	for _, programmed := range []bool{true, false} {
+		aft := newAFT(programmed)
+		if diffs := diffAFT(want, map[string]*oc.NetworkInstance_Afts{"DEFAULT": aft}); len(diffs) > 0 {
+			t.Errorf("diffAFT() with programmed IDs %v got discrepancies: %v", programmed, diffs)
+		}
+		aft.GetNextHop(1002).IpAddress = ygot.String("192.0.2.3")
+		wantDiff := `network instance "DEFAULT", next hop 2: `
+		diffs := diffAFT(want, map[string]*oc.NetworkInstance_Afts{"DEFAULT": aft})
+		if len(diffs) != 1 || !strings.HasPrefix(diffs[0], wantDiff) {
+			t.Errorf("diffAFT() with programmed IDs %v got discrepancies %q, want one with prefix %q", programmed, diffs, wantDiff)
+		}
+	}
+}
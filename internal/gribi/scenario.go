@@ -0,0 +1,479 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/gribigo/client"
+	"github.com/openconfig/gribigo/constants"
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/ondatra"
+)
+
+// Scenario is a gRIBI test case described as data: the clients of a Session
+// and a sequence of steps, each of which programs entries with an expected
+// programming result, changes the leader or connection of a client, or
+// verifies the RIB of the DUT.  Scenarios are usually written in YAML and
+// loaded with LoadScenario, e.g.
+//
+//	name: leader failover
+//	clients:
+//	- {name: a, persistence: true, fib_ack: true}
+//	- {name: b, persistence: true, fib_ack: true}
+//	steps:
+//	- {client: a, make_leader: true}
+//	- client: a
+//	  add:
+//	  - next_hop: {index: 1, ip_address: ${nh_ip}}
+//	  - next_hop_group: {id: 1, next_hops: {1: 1}}
+//	  - ipv4: {prefix: 198.51.100.0/24, nhg: 1}
+//	- {client: b, add: [next_hop: {index: 2, ip_address: 192.0.2.2}], result: FAILED}
+//	- {client: a, disconnect: true}
+//	- verify: {}
+//
+// Each step names its client, which may be omitted if there is only one, and
+// has exactly one action.  Entries without a network_instance are in the
+// default network instance of the DUT.  ${var} references are expanded from
+// the variables given to LoadScenario, so that the same scenario can be run on
+// DUTs with different interfaces and addresses.  ${default_ni} is the default
+// network instance of the DUT, unless given as a variable.
+type Scenario struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Clients     []*ScenarioClient `json:"clients"`
+	Steps       []*ScenarioStep   `json:"steps"`
+}
+
+// ScenarioClient is a client of a scenario.  See ClientOptions.
+type ScenarioClient struct {
+	Name        string `json:"name"`
+	AllPrimary  bool   `json:"all_primary,omitempty"`
+	Persistence bool   `json:"persistence,omitempty"`
+	FIBACK      bool   `json:"fib_ack,omitempty"`
+}
+
+// ScenarioStep is a step of a scenario.
+type ScenarioStep struct {
+	Description string `json:"description,omitempty"`
+	Client      string `json:"client,omitempty"`
+
+	// Actions, of which a step has exactly one.
+	MakeLeader bool             `json:"make_leader,omitempty"`
+	Disconnect bool             `json:"disconnect,omitempty"`
+	Reconnect  bool             `json:"reconnect,omitempty"`
+	Add        []*ScenarioEntry `json:"add,omitempty"`
+	Replace    []*ScenarioEntry `json:"replace,omitempty"`
+	Delete     []*ScenarioEntry `json:"delete,omitempty"`
+	Verify     *ScenarioVerify  `json:"verify,omitempty"`
+	FlushAll   bool             `json:"flush_all,omitempty"`
+
+	// Result is the expected programming result of each operation of an add,
+	// replace or delete step: PROGRAMMED (the default), RIB_PROGRAMMED,
+	// FIB_PROGRAMMED or FAILED.  PROGRAMMED is FIB_PROGRAMMED or
+	// RIB_PROGRAMMED depending on whether the client requested FIB_ACK and
+	// the DUT supports it.
+	Result string `json:"result,omitempty"`
+}
+
+// ScenarioEntry is a gRIBI entry, of which exactly one kind is set.
+type ScenarioEntry struct {
+	NetworkInstance string                `json:"network_instance,omitempty"`
+	NextHop         *ScenarioNextHop      `json:"next_hop,omitempty"`
+	NextHopGroup    *ScenarioNextHopGroup `json:"next_hop_group,omitempty"`
+	IPv4            *ScenarioRoute        `json:"ipv4,omitempty"`
+	IPv6            *ScenarioRoute        `json:"ipv6,omitempty"`
+	MPLS            *ScenarioLabel        `json:"mpls,omitempty"`
+}
+
+// ScenarioNextHop is a next hop entry.  See NHOptions.
+type ScenarioNextHop struct {
+	Index           uint64   `json:"index"`
+	IPAddress       string   `json:"ip_address,omitempty"`
+	Interface       string   `json:"interface,omitempty"`
+	Subinterface    *uint64  `json:"subinterface,omitempty"`
+	MACAddress      string   `json:"mac_address,omitempty"`
+	NetworkInstance string   `json:"network_instance,omitempty"`
+	Decapsulate     bool     `json:"decapsulate,omitempty"`
+	EncapSrc        string   `json:"encap_src,omitempty"`
+	EncapDst        string   `json:"encap_dst,omitempty"`
+	PushedLabels    []uint32 `json:"pushed_labels,omitempty"`
+	PopTopLabel     bool     `json:"pop_top_label,omitempty"`
+}
+
+// ScenarioNextHopGroup is a next hop group entry.
+type ScenarioNextHopGroup struct {
+	ID uint64 `json:"id"`
+	// NextHops maps the index of each next hop to its weight.
+	NextHops  map[uint64]uint64 `json:"next_hops,omitempty"`
+	BackupNHG uint64            `json:"backup_nhg,omitempty"`
+}
+
+// ScenarioRoute is an IPv4 or IPv6 entry.
+type ScenarioRoute struct {
+	Prefix             string `json:"prefix"`
+	NHG                uint64 `json:"nhg"`
+	NHGNetworkInstance string `json:"nhg_network_instance,omitempty"`
+}
+
+// ScenarioLabel is an MPLS entry.  See MPLSOptions.
+type ScenarioLabel struct {
+	Label              uint32   `json:"label"`
+	NHG                uint64   `json:"nhg"`
+	NHGNetworkInstance string   `json:"nhg_network_instance,omitempty"`
+	PoppedLabels       []uint32 `json:"popped_labels,omitempty"`
+}
+
+// ScenarioVerify verifies the RIB of the DUT with the gRIBI Get response
+// received by the client of the step, or by any connected client, and with
+// the gNMI AFT telemetry of the DUT.
+type ScenarioVerify struct {
+	// RIB is the expected RIB, in the format of RIB with snake_case field
+	// names.  If it is not set, the RIB that the session expects from the
+	// steps so far is verified.
+	RIB *RIB `json:"rib,omitempty"`
+}
+
+// LoadScenario reads a YAML scenario from a file, expanding the ${var}
+// references in it from vars.
+func LoadScenario(path string, vars map[string]string) (*Scenario, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sc, err := ParseScenario(b, vars)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sc, nil
+}
+
+// varRE matches a ${var} reference in a scenario.  Other uses of $ are left
+// as they are.
+var varRE = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ParseScenario parses a YAML scenario, expanding the ${var} references in it
+// from vars, and validates it.
+func ParseScenario(b []byte, vars map[string]string) (*Scenario, error) {
+	var missing []string
+	text := varRE.ReplaceAllStringFunc(string(b), func(ref string) string {
+		v := varRE.FindStringSubmatch(ref)[1]
+		val, ok := vars[v]
+		if !ok && v == "default_ni" {
+			val, ok = *deviations.DefaultNetworkInstance, true
+		}
+		if !ok {
+			missing = append(missing, v)
+		}
+		return val
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("undefined scenario variables %q", missing)
+	}
+	j, err := yaml.YAMLToJSON([]byte(text))
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse scenario: %w", err)
+	}
+	// Unknown fields are rejected, since they are most likely misspelt.
+	dec := json.NewDecoder(bytes.NewReader(j))
+	dec.DisallowUnknownFields()
+	sc := &Scenario{}
+	if err := dec.Decode(sc); err != nil {
+		return nil, fmt.Errorf("cannot parse scenario: %w", err)
+	}
+	if err := sc.validate(); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// scenarioResults are the programming results of the Result of a step.
+var scenarioResults = map[string]bool{
+	"":               true,
+	"PROGRAMMED":     true,
+	"RIB_PROGRAMMED": true,
+	"FIB_PROGRAMMED": true,
+	"FAILED":         true,
+}
+
+func (sc *Scenario) validate() error {
+	if len(sc.Clients) == 0 {
+		return fmt.Errorf("scenario %q has no clients", sc.Name)
+	}
+	clients := make(map[string]bool)
+	for _, c := range sc.Clients {
+		if c.Name == "" || clients[c.Name] {
+			return fmt.Errorf("scenario %q has an empty or duplicate client name %q", sc.Name, c.Name)
+		}
+		clients[c.Name] = true
+	}
+	for i, step := range sc.Steps {
+		if step.Client == "" && len(sc.Clients) > 1 && step.Verify == nil {
+			return fmt.Errorf("step %d does not name its client", i)
+		}
+		if step.Client != "" && !clients[step.Client] {
+			return fmt.Errorf("step %d names unknown client %q", i, step.Client)
+		}
+		actions := 0
+		for _, set := range []bool{step.MakeLeader, step.Disconnect, step.Reconnect, step.FlushAll,
+			step.Add != nil, step.Replace != nil, step.Delete != nil, step.Verify != nil} {
+			if set {
+				actions++
+			}
+		}
+		if actions != 1 {
+			return fmt.Errorf("step %d has %d actions, want 1", i, actions)
+		}
+		if !scenarioResults[step.Result] {
+			return fmt.Errorf("step %d has unknown result %q", i, step.Result)
+		}
+		for _, entries := range [][]*ScenarioEntry{step.Add, step.Replace, step.Delete} {
+			for j, e := range entries {
+				kinds := 0
+				for _, set := range []bool{e.NextHop != nil, e.NextHopGroup != nil, e.IPv4 != nil, e.IPv6 != nil, e.MPLS != nil} {
+					if set {
+						kinds++
+					}
+				}
+				if kinds != 1 {
+					return fmt.Errorf("step %d, entry %d has %d kinds of entry, want 1", i, j, kinds)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// RunScenario runs the scenario against the DUT in a new Session, which is
+// closed afterwards.  A step whose operations do not get the expected result
+// is fatal, while verification discrepancies are reported as errors.
+func RunScenario(t testing.TB, dut *ondatra.DUTDevice, sc *Scenario) {
+	t.Helper()
+	s := NewSession(dut)
+	defer s.Close(t)
+	runScenario(t, s, sc)
+}
+
+func runScenario(t testing.TB, s *Session, sc *Scenario) {
+	t.Helper()
+	t.Logf("Running gRIBI scenario %q", sc.Name)
+	for _, c := range sc.Clients {
+		s.AddClient(t, c.Name, &ClientOptions{
+			AllPrimary:  c.AllPrimary,
+			Persistence: c.Persistence,
+			FIBACK:      c.FIBACK,
+		})
+	}
+	for i, step := range sc.Steps {
+		name := step.Client
+		if name == "" {
+			name = sc.Clients[0].Name
+		}
+		if step.Description != "" {
+			t.Logf("Step %d: %s", i, step.Description)
+		}
+		switch {
+		case step.MakeLeader:
+			s.MakeLeader(t, name)
+		case step.Disconnect:
+			s.Disconnect(t, name)
+		case step.Reconnect:
+			s.Reconnect(t, name)
+		case step.FlushAll:
+			s.Client(t, name).FlushAll(t)
+		case step.Add != nil:
+			runScenarioOps(t, s.Client(t, name), constants.Add, step)
+		case step.Replace != nil:
+			runScenarioOps(t, s.Client(t, name), constants.Replace, step)
+		case step.Delete != nil:
+			runScenarioOps(t, s.Client(t, name), constants.Delete, step)
+		case step.Verify != nil:
+			var err error
+			want := s.ExpectedRIB()
+			if step.Verify.RIB != nil {
+				want = normalizeRIB(step.Verify.RIB)
+			}
+			if step.Client != "" {
+				err = s.connected(t, step.Client).verifyRIB(t, want)
+			} else {
+				err = s.verify(t, want)
+			}
+			if err != nil {
+				t.Errorf("Step %d of gRIBI scenario %q: %v", i, sc.Name, err)
+			}
+		}
+	}
+}
+
+// runScenarioOps sends the operations of a step one at a time and checks
+// their results.
+func runScenarioOps(t testing.TB, c *Client, opType constants.OpType, step *ScenarioStep) {
+	t.Helper()
+	var expectedResult fluent.ProgrammingResult
+	switch step.Result {
+	case "", "PROGRAMMED":
+		expectedResult = c.ProgrammedResult()
+	case "RIB_PROGRAMMED":
+		expectedResult = fluent.InstalledInRIB
+	case "FIB_PROGRAMMED":
+		expectedResult = fluent.InstalledInFIB
+	case "FAILED":
+		expectedResult = fluent.ProgrammingFailed
+	}
+	for _, e := range step.entries(opType) {
+		entry, want := e.fluentEntry(opType, expectedResult)
+		c.modify(t, fmt.Sprintf("%s %s", opType, entryText(entry)), opType, entry, want)
+	}
+}
+
+// entries returns the entries of the operations of the given type in the
+// step.
+func (step *ScenarioStep) entries(opType constants.OpType) []*ScenarioEntry {
+	switch opType {
+	case constants.Add:
+		return step.Add
+	case constants.Replace:
+		return step.Replace
+	case constants.Delete:
+		return step.Delete
+	}
+	return nil
+}
+
+// fluentEntry returns the fluent entry and the expected result of an
+// operation on the entry.
+func (e *ScenarioEntry) fluentEntry(opType constants.OpType, expectedResult fluent.ProgrammingResult) (fluent.GRIBIEntry, *client.OpResult) {
+	ni := e.NetworkInstance
+	if ni == "" {
+		ni = *deviations.DefaultNetworkInstance
+	}
+	res := fluent.OperationResult().
+		WithOperationType(opType).
+		WithProgrammingResult(expectedResult)
+	switch {
+	case e.NextHop != nil:
+		nh := e.NextHop
+		opts := &NHOptions{
+			Interface:       nh.Interface,
+			MAC:             nh.MACAddress,
+			NetworkInstance: nh.NetworkInstance,
+			Decapsulate:     nh.Decapsulate,
+			EncapSrc:        nh.EncapSrc,
+			EncapDst:        nh.EncapDst,
+			PushedLabels:    nh.PushedLabels,
+			PopTopLabel:     nh.PopTopLabel,
+		}
+		if nh.Subinterface != nil {
+			opts.Subinterface = *nh.Subinterface
+			opts.HasSubinterface = true
+		}
+		return nextHopEntry(nh.Index, nh.IPAddress, ni, opts), res.WithNextHopOperation(nh.Index).AsResult()
+	case e.NextHopGroup != nil:
+		nhg := fluent.NextHopGroupEntry().WithNetworkInstance(ni).WithID(e.NextHopGroup.ID)
+		for _, index := range unionKeys(e.NextHopGroup.NextHops, nil) {
+			nhg.AddNextHop(index, e.NextHopGroup.NextHops[index])
+		}
+		if e.NextHopGroup.BackupNHG != 0 {
+			nhg.WithBackupNHG(e.NextHopGroup.BackupNHG)
+		}
+		return nhg, res.WithNextHopGroupOperation(e.NextHopGroup.ID).AsResult()
+	case e.IPv4 != nil:
+		ipv4 := fluent.IPv4Entry().WithNetworkInstance(ni).WithPrefix(e.IPv4.Prefix)
+		if opType != constants.Delete {
+			ipv4.WithNextHopGroup(e.IPv4.NHG)
+			if e.IPv4.NHGNetworkInstance != "" {
+				ipv4.WithNextHopGroupNetworkInstance(e.IPv4.NHGNetworkInstance)
+			}
+		}
+		return ipv4, res.WithIPv4Operation(e.IPv4.Prefix).AsResult()
+	case e.MPLS != nil:
+		label := fluent.LabelEntry().WithNetworkInstance(ni).WithLabel(e.MPLS.Label)
+		if opType != constants.Delete {
+			label.WithNextHopGroup(e.MPLS.NHG)
+			if e.MPLS.NHGNetworkInstance != "" {
+				label.WithNextHopGroupNetworkInstance(e.MPLS.NHGNetworkInstance)
+			}
+			if len(e.MPLS.PoppedLabels) > 0 {
+				label.WithPoppedLabelStack(e.MPLS.PoppedLabels...)
+			}
+		}
+		return label, res.WithMPLSOperation(uint64(e.MPLS.Label)).AsResult()
+	default:
+		ipv6 := newIPv6Entry(e.IPv6.Prefix, ni)
+		if opType != constants.Delete {
+			ipv6.withNextHopGroup(e.IPv6.NHG)
+			if e.IPv6.NHGNetworkInstance != "" {
+				ipv6.withNextHopGroupNetworkInstance(e.IPv6.NHGNetworkInstance)
+			}
+		}
+		return ipv6, ipv6Result(opType, expectedResult)
+	}
+}
+
+// normalizeRIB returns a RIB parsed from a scenario in the form of those
+// built by the client, which always has next hops in a next hop group and
+// leaves the network instance of the next hop group of a route empty if it is
+// that of the route.
+func normalizeRIB(r *RIB) *RIB {
+	n := NewRIB()
+	for name, ni := range r.NetworkInstances {
+		m := n.NetworkInstance(name)
+		if ni == nil {
+			continue
+		}
+		for index, nh := range ni.NextHops {
+			if nh == nil {
+				nh = &NH{}
+			}
+			m.NextHops[index] = nh
+		}
+		for id, nhg := range ni.NextHopGroups {
+			g := &NHG{NextHops: make(map[uint64]uint64)}
+			if nhg != nil {
+				g.BackupNHG = nhg.BackupNHG
+				for index, weight := range nhg.NextHops {
+					g.NextHops[index] = weight
+				}
+			}
+			m.NextHopGroups[id] = g
+		}
+		for prefix, route := range ni.IPv4 {
+			if route == nil {
+				route = &Route{}
+			}
+			m.IPv4[prefix] = newRoute(name, route.NHG, route.NHGNetworkInstance)
+		}
+		for prefix, route := range ni.IPv6 {
+			if route == nil {
+				route = &Route{}
+			}
+			m.IPv6[prefix] = newRoute(name, route.NHG, route.NHGNetworkInstance)
+		}
+		for label, route := range ni.MPLS {
+			if route == nil {
+				route = &Route{}
+			}
+			m.MPLS[label] = newRoute(name, route.NHG, route.NHGNetworkInstance)
+		}
+	}
+	return n
+}
@@ -0,0 +1,134 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/gribigo/constants"
+	"github.com/openconfig/gribigo/fluent"
+)
+
+func TestParseScenario(t *testing.T) {
+	const text = `
+name: routes
+description: $port is only expanded in braces
+clients: [{name: a, fib_ack: true}]
+steps:
+- {make_leader: true}
+- add:
+  - next_hop: {index: 1, interface: "${port}", subinterface: 0, mac_address: "02:00:00:00:00:01"}
+  - next_hop_group: {id: 1, next_hops: {1: 1}}
+  - ipv4: {prefix: 198.51.100.0/24, nhg: 1, nhg_network_instance: DEFAULT}
+  - {network_instance: VRF-A, ipv6: {prefix: "2001:db8::/32", nhg: 1, nhg_network_instance: DEFAULT}}
+  - mpls: {label: 100, nhg: 1, popped_labels: [100]}
+- verify:
+    rib:
+      network_instances:
+        ${default_ni}:
+          next_hop_groups: {1: {next_hops: {1: 1}}}
+          ipv4: {198.51.100.0/24: {nhg: 1, nhg_network_instance: DEFAULT}}
+          mpls: {100: {nhg: 1}}
+`
+	sc, err := ParseScenario([]byte(text), map[string]string{"port": "Ethernet1"})
+	if err != nil {
+		t.Fatalf("ParseScenario() got error: %v", err)
+	}
+	if want := "$port is only expanded in braces"; sc.Description != want {
+		t.Errorf("ParseScenario() got description %q, want %q", sc.Description, want)
+	}
+	if got := len(sc.Steps); got != 3 {
+		t.Fatalf("ParseScenario() got %d steps, want 3", got)
+	}
+	var got []string
+	for _, e := range sc.Steps[1].Add {
+		entry, _ := e.fluentEntry(constants.Add, fluent.InstalledInFIB)
+		got = append(got, entryText(entry))
+	}
+	want := []string{
+		`network_instance: "DEFAULT" next_hop: { index: 1 next_hop: { interface_ref: { interface: { value: "Ethernet1" } subinterface: {} } mac_address: { value: "02:00:00:00:00:01" } } }`,
+		`network_instance: "DEFAULT" next_hop_group: { id: 1 next_hop_group: { next_hop: { index: 1 next_hop: { weight: { value: 1 } } } } }`,
+		`network_instance: "DEFAULT" ipv4: { prefix: "198.51.100.0/24" ipv4_entry: { next_hop_group: { value: 1 } next_hop_group_network_instance: { value: "DEFAULT" } } }`,
+		`network_instance: "VRF-A" ipv6: { prefix: "2001:db8::/32" ipv6_entry: { next_hop_group: { value: 1 } next_hop_group_network_instance: { value: "DEFAULT" } } }`,
+		`network_instance: "DEFAULT" mpls: { label_uint64: 100 label_entry: { next_hop_group: { value: 1 } popped_mpls_label_stack: { popped_mpls_label_stack_uint64: 100 } } }`,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseScenario() unexpected entries (-want +got):\n%s", diff)
+	}
+
+	wantRIB := NewRIB()
+	wantRIB.NetworkInstance("DEFAULT").NextHopGroups[1] = &NHG{NextHops: map[uint64]uint64{1: 1}}
+	wantRIB.NetworkInstance("DEFAULT").IPv4["198.51.100.0/24"] = &Route{NHG: 1}
+	wantRIB.NetworkInstance("DEFAULT").MPLS[100] = &Route{NHG: 1}
+	if diffs := Diff(wantRIB, normalizeRIB(sc.Steps[2].Verify.RIB), "scenario", true); len(diffs) > 0 {
+		t.Errorf("ParseScenario() got unexpected RIB: %v", diffs)
+	}
+}
+
+func TestParseScenarioErrors(t *testing.T) {
+	tests := []struct {
+		desc    string
+		text    string
+		wantErr string
+	}{{
+		desc:    "undefined variable",
+		text:    `{name: x, clients: [{name: a}], steps: [{add: [{next_hop: {index: 1, ip_address: "${ip}"}}]}]}`,
+		wantErr: "undefined scenario variables",
+	}, {
+		desc:    "unknown field",
+		text:    `{name: x, clients: [{name: a, fibak: true}]}`,
+		wantErr: "unknown field",
+	}, {
+		desc:    "unnamed client",
+		text:    `{name: x, clients: [{name: a}, {name: b}], steps: [{make_leader: true}]}`,
+		wantErr: "does not name its client",
+	}, {
+		desc:    "two actions",
+		text:    `{name: x, clients: [{name: a}], steps: [{make_leader: true, disconnect: true}]}`,
+		wantErr: "has 2 actions",
+	}, {
+		desc:    "two kinds of entry",
+		text:    `{name: x, clients: [{name: a}], steps: [{add: [{next_hop: {index: 1}, ipv4: {prefix: 192.0.2.0/24}}]}]}`,
+		wantErr: "2 kinds of entry",
+	}, {
+		desc:    "unknown result",
+		text:    `{name: x, clients: [{name: a}], steps: [{add: [{next_hop: {index: 1}}], result: OK}]}`,
+		wantErr: "unknown result",
+	}}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := ParseScenario([]byte(tc.text), nil)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("ParseScenario() got error %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunScenario(t *testing.T) {
+	sc, err := LoadScenario("testdata/leader_failover.yaml", map[string]string{"nh1": "192.0.2.1", "nh2": "192.0.2.2"})
+	if err != nil {
+		t.Fatalf("LoadScenario() got error: %v", err)
+	}
+	s := NewSession(nil)
+	s.stub = serverStub(t)
+	t.Cleanup(func() { s.Close(t) })
+	runScenario(t, s, sc)
+	if got := s.ExpectedRIB().NetworkInstance("DEFAULT").NextHops; len(got) != 1 || got[2] == nil {
+		t.Errorf("ExpectedRIB() got next hops %v after the scenario, want only next hop 2", got)
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/openconfig/ondatra"
+
+	gpb "github.com/openconfig/gribi/v1/proto/service"
+)
+
+// Session orchestrates several named gRIBI clients of the same DUT, which all
+// use the same redundancy mode.  In the ELECTED_PRIMARY mode the session
+// coordinates the election IDs of its clients so that any of them can be made
+// the leader, and the clients share one intended RIB.  In the ALL_PRIMARY mode
+// each client has its own intended RIB.
+//
+// Disconnecting a client simulates its loss: the entries that it programmed
+// are expected to survive only if it requested PRESERVE persistence, which
+// ExpectedRIB and Verify take into account.
+//
+// Usage:
+//
+//	s := gribi.NewSession(dut)
+//	a := s.AddClient(t, "a", &gribi.ClientOptions{Persistence: true, FIBACK: true})
+//	b := s.AddClient(t, "b", &gribi.ClientOptions{Persistence: true, FIBACK: true})
+//	s.MakeLeader(t, "a")
+//	a.AddNH(t, 1, "192.0.2.1", *deviations.DefaultNetworkInstance, fluent.InstalledInFIB)
+//	s.Disconnect(t, "a")
+//	s.MakeLeader(t, "b")
+//	if err := s.Verify(t); err != nil {
+//	  t.Error(err)
+//	}
+type Session struct {
+	DUT *ondatra.DUTDevice
+
+	// Unexport fields below.
+	clients    map[string]*Client
+	names      []string
+	allPrimary bool
+	// electionID is the largest election ID given to any client.
+	electionID Uint128
+	leader     string
+	// rib is the RIB shared by the clients in the ELECTED_PRIMARY mode.
+	rib *RIB
+	// preserved are the RIBs of disconnected ALL_PRIMARY clients with
+	// PRESERVE persistence.
+	preserved []*RIB
+	// stub, if set, is used instead of the gRIBI client of the DUT.
+	stub gpb.GRIBIClient
+}
+
+// ClientOptions are the parameters of a client of a Session.
+type ClientOptions struct {
+	// AllPrimary uses the ALL_PRIMARY redundancy mode instead of
+	// ELECTED_PRIMARY.  All clients of a session must use the same mode.
+	AllPrimary bool
+	// Persistence requests PRESERVE persistence instead of DELETE.
+	Persistence bool
+	// FIBACK requests FIB_ACK, unless --deviation_gribi_riback_only is set.
+	FIBACK bool
+}
+
+// NewSession returns a session without clients.
+func NewSession(dut *ondatra.DUTDevice) *Session {
+	return &Session{
+		DUT:     dut,
+		clients: make(map[string]*Client),
+		rib:     NewRIB(),
+	}
+}
+
+// AddClient creates and starts a named client.  In the ELECTED_PRIMARY mode
+// the client starts with an election ID below that of the current leader,
+// so it does not preempt it; see MakeLeader.
+func (s *Session) AddClient(t testing.TB, name string, opts *ClientOptions) *Client {
+	t.Helper()
+	if opts == nil {
+		opts = &ClientOptions{}
+	}
+	if _, ok := s.clients[name]; ok {
+		t.Fatalf("gRIBI session already has a client named %q", name)
+	}
+	if len(s.clients) == 0 {
+		s.allPrimary = opts.AllPrimary
+	} else if opts.AllPrimary != s.allPrimary {
+		t.Fatalf("gRIBI client %q cannot use a different redundancy mode than the other clients of the session", name)
+	}
+	c := &Client{
+		DUT:         s.DUT,
+		FIBACK:      opts.FIBACK,
+		Persistence: opts.Persistence,
+		AllPrimary:  opts.AllPrimary,
+		noReference: true,
+		stub:        s.stub,
+	}
+	if !s.allPrimary {
+		c.rib = s.rib
+	}
+	s.clients[name] = c
+	s.names = append(s.names, name)
+	s.start(t, name)
+	return c
+}
+
+// start starts the named client.
+func (s *Session) start(t testing.TB, name string) {
+	t.Helper()
+	c := s.clients[name]
+	c.InitialElectionID = Uint128{Low: 1}
+	if s.allPrimary {
+		c.rib = NewRIB()
+	}
+	if err := c.Start(t); err != nil {
+		t.Fatalf("gRIBI client %q did not start: %v", name, err)
+	}
+}
+
+// Client returns the named client.
+func (s *Session) Client(t testing.TB, name string) *Client {
+	t.Helper()
+	c, ok := s.clients[name]
+	if !ok {
+		t.Fatalf("gRIBI session has no client named %q", name)
+	}
+	return c
+}
+
+// Names returns the names of the clients in the order they were added.
+func (s *Session) Names() []string {
+	return append([]string(nil), s.names...)
+}
+
+// MakeLeader gives the named client an election ID above that of all other
+// clients of the session, making it the leader, and returns the ID.  It is
+// fatal in the ALL_PRIMARY mode.
+func (s *Session) MakeLeader(t testing.TB, name string) Uint128 {
+	t.Helper()
+	c := s.connected(t, name)
+	if s.allPrimary {
+		t.Fatalf("gRIBI client %q cannot be made leader in the ALL_PRIMARY redundancy mode", name)
+	}
+	for _, other := range s.clients {
+		if greater(other.ElectionID(), s.electionID) {
+			s.electionID = other.ElectionID()
+		}
+	}
+	s.electionID = s.electionID.Increment()
+	t.Logf("Making gRIBI client %q leader with election ID %+v", name, s.electionID)
+	c.UpdateElectionID(t, s.electionID)
+	s.leader = name
+	return s.electionID
+}
+
+// Leader returns the name of the leader, or empty if no client has been made
+// leader or the leader was disconnected.
+func (s *Session) Leader() string {
+	return s.leader
+}
+
+// Disconnect closes the session of the named client, simulating its loss.
+// Its entries are expected to be removed by the DUT unless it requested
+// PRESERVE persistence.  In the ELECTED_PRIMARY mode only the entries of the
+// leader are affected; the session has no leader afterwards.
+func (s *Session) Disconnect(t testing.TB, name string) {
+	t.Helper()
+	c := s.connected(t, name)
+	c.Close(t)
+	switch {
+	case s.allPrimary && c.Persistence:
+		s.preserved = append(s.preserved, c.rib)
+	case s.leader == name && !c.Persistence:
+		s.rib.Flush()
+	}
+	if s.leader == name {
+		s.leader = ""
+	}
+}
+
+// Reconnect starts a new session for the named client, which was
+// disconnected.  In the ELECTED_PRIMARY mode it is not the leader; in the
+// ALL_PRIMARY mode it starts with an empty intended RIB.
+func (s *Session) Reconnect(t testing.TB, name string) {
+	t.Helper()
+	c := s.Client(t, name)
+	if c.fluentC != nil {
+		t.Fatalf("gRIBI client %q is already connected", name)
+	}
+	s.start(t, name)
+}
+
+// Close closes the sessions of all connected clients, without modelling the
+// loss of their entries.
+func (s *Session) Close(t testing.TB) {
+	t.Helper()
+	for _, name := range s.names {
+		s.clients[name].Close(t)
+	}
+	s.leader = ""
+}
+
+// connected returns the named client, which must be connected.
+func (s *Session) connected(t testing.TB, name string) *Client {
+	t.Helper()
+	c := s.Client(t, name)
+	if c.fluentC == nil {
+		t.Fatalf("gRIBI client %q is not connected", name)
+	}
+	return c
+}
+
+// ExpectedRIB returns the entries that are expected to be installed on the
+// DUT: the shared RIB in the ELECTED_PRIMARY mode, or the union of the RIBs of
+// the connected clients and of the disconnected clients with PRESERVE
+// persistence in the ALL_PRIMARY mode.  The RIBs of later clients take
+// precedence over those of earlier ones.
+func (s *Session) ExpectedRIB() *RIB {
+	if !s.allPrimary {
+		return s.rib
+	}
+	want := NewRIB()
+	ribs := append([]*RIB(nil), s.preserved...)
+	for _, name := range s.names {
+		if c := s.clients[name]; c.fluentC != nil {
+			ribs = append(ribs, c.IntendedRIB())
+		}
+	}
+	for _, r := range ribs {
+		for name, ni := range r.NetworkInstances {
+			w := want.NetworkInstance(name)
+			for k, v := range ni.NextHops {
+				w.NextHops[k] = v
+			}
+			for k, v := range ni.NextHopGroups {
+				w.NextHopGroups[k] = v
+			}
+			for k, v := range ni.IPv4 {
+				w.IPv4[k] = v
+			}
+			for k, v := range ni.IPv6 {
+				w.IPv6[k] = v
+			}
+			for k, v := range ni.MPLS {
+				w.MPLS[k] = v
+			}
+		}
+	}
+	return want
+}
+
+// Verify compares ExpectedRIB with the gRIBI Get response received by a
+// connected client and with the gNMI AFT telemetry of the DUT.  It returns an
+// error describing all discrepancies, or if no client is connected.
+func (s *Session) Verify(t testing.TB) error {
+	t.Helper()
+	return s.verify(t, s.ExpectedRIB())
+}
+
+// verify compares want with the RIB of the DUT, using a connected client.
+func (s *Session) verify(t testing.TB, want *RIB) error {
+	t.Helper()
+	for _, name := range s.names {
+		if c := s.clients[name]; c.fluentC != nil {
+			return c.verifyRIB(t, want)
+		}
+	}
+	return fmt.Errorf("gRIBI session has no connected client to verify the RIB with")
+}
+
+// greater returns whether a is greater than b.
+func greater(a, b Uint128) bool {
+	return a.High > b.High || a.High == b.High && a.Low > b.Low
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gribi
+
+import (
+	"testing"
+
+	"github.com/openconfig/gribigo/fluent"
+	"github.com/openconfig/gribigo/server"
+)
+
+func TestSession(t *testing.T) {
+	const ni = server.DefaultNetworkInstanceName
+	s := NewSession(nil)
+	s.stub = serverStub(t)
+	t.Cleanup(func() { s.Close(t) })
+	opts := &ClientOptions{Persistence: true, FIBACK: true}
+	a := s.AddClient(t, "a", opts)
+	b := s.AddClient(t, "b", opts)
+
+	if got, want := s.MakeLeader(t, "a"), (Uint128{Low: 2}); got != want {
+		t.Errorf("MakeLeader(a) got election ID %+v, want %+v", got, want)
+	}
+	a.AddNH(t, 1, "192.0.2.1", ni, fluent.InstalledInFIB)
+	// The reference server fails operations of clients that are not the leader.
+	b.AddNH(t, 2, "192.0.2.2", ni, fluent.ProgrammingFailed)
+
+	if got, want := s.MakeLeader(t, "b"), (Uint128{Low: 3}); got != want {
+		t.Errorf("MakeLeader(b) got election ID %+v, want %+v", got, want)
+	}
+	b.AddNH(t, 2, "192.0.2.2", ni, fluent.InstalledInFIB)
+
+	s.Disconnect(t, "b")
+	if got := s.Leader(); got != "" {
+		t.Errorf("Leader() got %q after disconnecting the leader, want none", got)
+	}
+	// Both next hops are preserved after the leader is lost.
+	if got := len(s.ExpectedRIB().NetworkInstance(ni).NextHops); got != 2 {
+		t.Errorf("ExpectedRIB() got %d next hops, want 2", got)
+	}
+	if err := s.Verify(t); err != nil {
+		t.Errorf("Verify() got error: %v", err)
+	}
+
+	s.Reconnect(t, "b")
+	if got, want := s.MakeLeader(t, "b"), (Uint128{Low: 4}); got != want {
+		t.Errorf("MakeLeader(b) got election ID %+v, want %+v", got, want)
+	}
+	b.DeleteNH(t, 1, ni, fluent.InstalledInFIB)
+	if err := s.Verify(t); err != nil {
+		t.Errorf("Verify() got error: %v", err)
+	}
+}
+
+func TestSessionWithoutReference(t *testing.T) {
+	defer func(v bool) { *gribiReference = v }(*gribiReference)
+	*gribiReference = true
+	s := NewSession(nil)
+	s.stub = serverStub(t)
+	t.Cleanup(func() { s.Close(t) })
+	if c := s.AddClient(t, "a", &ClientOptions{Persistence: true}); c.ref != nil {
+		t.Errorf("AddClient() started a reference server with --gribi_reference, want none")
+	}
+}
@@ -0,0 +1,389 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lag provides helpers to configure aggregate interfaces on the DUT
+// and the ATE, and to wait for them to come up.
+//
+// Usage:
+//
+//	l := &lag.LAG{
+//	  Name:     "Port-Channel1",
+//	  Type:     oc.IfAggregate_AggregationType_LACP,
+//	  MinLinks: 1,
+//	  Members:  []string{dut.Port(t, "port2").Name(), dut.Port(t, "port3").Name()},
+//	  Attrs:    &dutDst,
+//	}
+//	l.Push(t, dut)
+//	l.AddToOTG(top, []*ondatra.Port{ate.Port(t, "port2"), ate.Port(t, "port3")}, &ateDst, &dutDst)
+//	...
+//	l.AwaitUp(t, dut, time.Minute, 2)
+package lag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/featureprofiles/internal/deviations"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/gnmi"
+	"github.com/openconfig/ondatra/gnmi/oc"
+	"github.com/openconfig/ondatra/otg"
+	"github.com/openconfig/ygnmi/ygnmi"
+	"github.com/openconfig/ygot/ygot"
+	"google.golang.org/protobuf/encoding/prototext"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+	otgtelemetry "github.com/openconfig/ondatra/gnmi/otg"
+)
+
+// LAG bundles the attributes of an aggregate interface.
+type LAG struct {
+	// Name is the name of the aggregate interface on the DUT.
+	Name string
+	// Type is either LACP or STATIC.
+	Type oc.E_IfAggregate_AggregationType
+	// MinLinks is the minimum number of member links that must be up for the
+	// aggregate to be up.  Zero leaves it unset.
+	MinLinks uint16
+	// Members are the names of the DUT member interfaces.
+	Members []string
+	// Attrs are the layer 3 attributes of the aggregate interface.  If nil,
+	// the aggregate interface has no addresses.
+	Attrs *attrs.Attributes
+}
+
+// NewOCLacp returns the LACP configuration of the aggregate interface.
+func (l *LAG) NewOCLacp() *oc.Lacp_Interface {
+	lacp := &oc.Lacp_Interface{Name: ygot.String(l.Name)}
+	if l.Type == oc.IfAggregate_AggregationType_LACP {
+		lacp.LacpMode = oc.Lacp_LacpActivityType_ACTIVE
+	} else {
+		lacp.LacpMode = oc.Lacp_LacpActivityType_UNSET
+	}
+	return lacp
+}
+
+// NewOCAggregate returns the configuration of the aggregate interface.
+func (l *LAG) NewOCAggregate() *oc.Interface {
+	i := &oc.Interface{Name: ygot.String(l.Name)}
+	if l.Attrs != nil {
+		l.Attrs.ConfigOCInterface(i)
+		// The aggregate has no ethernet MAC or L2 MTU of its own.
+		i.Ethernet = nil
+		i.Mtu = nil
+	} else if *deviations.InterfaceEnabled {
+		i.Enabled = ygot.Bool(true)
+	}
+	i.Type = oc.IETFInterfaces_InterfaceType_ieee8023adLag
+	g := i.GetOrCreateAggregation()
+	g.LagType = l.Type
+	if l.MinLinks > 0 {
+		g.MinLinks = ygot.Uint16(l.MinLinks)
+	}
+	return i
+}
+
+// NewOCMember returns the configuration of a member interface.
+func (l *LAG) NewOCMember(name string) *oc.Interface {
+	i := &oc.Interface{Name: ygot.String(name)}
+	i.Description = ygot.String(fmt.Sprintf("%s member", l.Name))
+	i.Type = oc.IETFInterfaces_InterfaceType_ethernetCsmacd
+	if *deviations.InterfaceEnabled {
+		i.Enabled = ygot.Bool(true)
+	}
+	i.GetOrCreateEthernet().AggregateId = ygot.String(l.Name)
+	return i
+}
+
+// ConfigOC adds the aggregate interface, its members and, for LACP, the LACP
+// interface to root.
+func (l *LAG) ConfigOC(root *oc.Root) {
+	if l.Type == oc.IfAggregate_AggregationType_LACP {
+		root.GetOrCreateLacp().AppendInterface(l.NewOCLacp())
+	}
+	root.AppendInterface(l.NewOCAggregate())
+	for _, member := range l.Members {
+		root.AppendInterface(l.NewOCMember(member))
+	}
+}
+
+// Push configures the LAG on the DUT.  If deviations.AggregateAtomicUpdate is
+// set, the aggregate and its members are sent in a single gNMI Update at
+// /interfaces.  Otherwise they are staged: first the aggregate interface,
+// then each of the members.  For LACP, the LACP interface is replaced last.
+func (l *LAG) Push(t testing.TB, dut *ondatra.DUTDevice) {
+	t.Helper()
+	d := gnmi.OC()
+
+	if *deviations.AggregateAtomicUpdate {
+		l.clearMembers(t, dut)
+		root := &oc.Root{}
+		l.ConfigOC(root)
+		root.Lacp = nil
+		req, err := interfacesUpdate(root)
+		if err != nil {
+			t.Fatalf("Cannot build the update of %s: %v", l.Name, err)
+		}
+		t.Logf("%s to Update() at /interfaces:\n%s", l.Name, prototext.Format(req))
+		if _, err := dut.RawAPIs().GNMI().Default(t).Set(context.Background(), req); err != nil {
+			t.Fatalf("Update of %s at /interfaces failed: %v", l.Name, err)
+		}
+	} else {
+		agg := l.NewOCAggregate()
+		aggPath := d.Interface(l.Name)
+		fptest.LogQuery(t, l.Name, aggPath.Config(), agg)
+		gnmi.Replace(t, dut, aggPath.Config(), agg)
+
+		for _, member := range l.Members {
+			i := l.NewOCMember(member)
+			iPath := d.Interface(member)
+			fptest.LogQuery(t, member, iPath.Config(), i)
+			gnmi.Replace(t, dut, iPath.Config(), i)
+		}
+	}
+
+	if l.Type == oc.IfAggregate_AggregationType_LACP {
+		lacp := l.NewOCLacp()
+		lacpPath := d.Lacp().Interface(l.Name)
+		fptest.LogQuery(t, "LACP", lacpPath.Config(), lacp)
+		gnmi.Replace(t, dut, lacpPath.Config(), lacp)
+	}
+}
+
+// interfacesUpdate returns a SetRequest that updates /interfaces with the
+// interfaces of root.
+func interfacesUpdate(root *oc.Root) (*gpb.SetRequest, error) {
+	tree, err := ygot.ConstructIETFJSON(&oc.Root{Interface: root.Interface}, &ygot.RFC7951JSONConfig{AppendModuleName: true, PreferShadowPath: true})
+	if err != nil {
+		return nil, err
+	}
+	intfs, ok := tree["openconfig-interfaces:interfaces"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("no interfaces to update")
+	}
+	b, err := json.Marshal(map[string]interface{}{"openconfig-interfaces:interface": intfs["interface"]})
+	if err != nil {
+		return nil, err
+	}
+	return &gpb.SetRequest{
+		Update: []*gpb.Update{{
+			Path: &gpb.Path{Origin: "openconfig", Elem: []*gpb.PathElem{{Name: "interfaces"}}},
+			Val:  &gpb.TypedValue{Value: &gpb.TypedValue_JsonIetfVal{JsonIetfVal: b}},
+		}},
+	}, nil
+}
+
+// clearMembers removes the min-links of the aggregate and the aggregate-id of
+// its members, so an atomic update can redefine them.
+func (l *LAG) clearMembers(t testing.TB, dut *ondatra.DUTDevice) {
+	t.Helper()
+	gnmi.Delete(t, dut, gnmi.OC().Interface(l.Name).Aggregation().MinLinks().Config())
+	for _, member := range l.Members {
+		gnmi.Delete(t, dut, gnmi.OC().Interface(member).Ethernet().AggregateId().Config())
+	}
+}
+
+// AwaitUp waits until the aggregate interface on the DUT is oper-UP with at
+// least n member interfaces oper-UP and, for LACP, in sync, sharing one
+// timeout for all conditions.
+func (l *LAG) AwaitUp(t testing.TB, dut *ondatra.DUTDevice, timeout time.Duration, n int) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+
+	gnmi.Await(t, dut, gnmi.OC().Interface(l.Name).OperStatus().State(), timeout, oc.Interface_OperStatus_UP)
+
+	up, ok := awaitMembers(t, dut, gnmi.OC().InterfaceAny().OperStatus().State(), pathKey("interface", "name"),
+		l.Members, n, time.Until(deadline), func(s oc.E_Interface_OperStatus) bool {
+			return s == oc.Interface_OperStatus_UP
+		})
+	if !ok {
+		t.Fatalf("%s got %d members oper-UP %v, want at least %d of %v", l.Name, len(up), up, n, l.Members)
+	}
+	if l.Type != oc.IfAggregate_AggregationType_LACP {
+		return
+	}
+	inSync, ok := awaitMembers(t, dut, gnmi.OC().Lacp().Interface(l.Name).MemberAny().Synchronization().State(), pathKey("member", "interface"),
+		l.Members, n, time.Until(deadline), func(s oc.E_Lacp_LacpSynchronizationType) bool {
+			return s == oc.Lacp_LacpSynchronizationType_IN_SYNC
+		})
+	if !ok {
+		t.Fatalf("%s got %d members in LACP sync %v, want at least %d of %v", l.Name, len(inSync), inSync, n, l.Members)
+	}
+}
+
+// pathKey returns a function that returns the value of key in the last
+// element of a path with the given name.
+func pathKey(elem, key string) func(*gpb.Path) string {
+	return func(p *gpb.Path) string {
+		elems := p.GetElem()
+		for i := len(elems) - 1; i >= 0; i-- {
+			if elems[i].GetName() == elem {
+				return elems[i].GetKey()[key]
+			}
+		}
+		return ""
+	}
+}
+
+// awaitMembers watches q until at least n of the members, identified in the
+// paths of q by key, have a value that satisfies ok.  It returns the members
+// that satisfy ok, sorted, and whether there are at least n of them.
+func awaitMembers[T any](t testing.TB, dut *ondatra.DUTDevice, q ygnmi.WildcardQuery[T], key func(*gpb.Path) string,
+	members []string, n int, timeout time.Duration, ok func(T) bool) ([]string, bool) {
+	t.Helper()
+	isMember := make(map[string]bool)
+	for _, m := range members {
+		isMember[m] = true
+	}
+	good := make(map[string]bool)
+	_, done := gnmi.WatchAll(t, dut, q, timeout, func(v *ygnmi.Value[T]) bool {
+		if m := key(v.Path); isMember[m] {
+			val, present := v.Val()
+			if present && ok(val) {
+				good[m] = true
+			} else {
+				delete(good, m)
+			}
+		}
+		return len(good) >= n
+	}).Await(t)
+	var got []string
+	for m := range good {
+		got = append(got, m)
+	}
+	sort.Strings(got)
+	return got, done
+}
+
+// otgLagID returns the static LAG ID used on the ATE, which is derived from
+// the trailing number of the aggregate name, or 1 if there is none.
+func (l *LAG) otgLagID() int32 {
+	m := trailingNumRE.FindString(l.Name)
+	id, err := strconv.Atoi(m)
+	if err != nil || id == 0 {
+		return 1
+	}
+	return int32(id)
+}
+
+var trailingNumRE = regexp.MustCompile(`[0-9]+$`)
+
+// AddToOTG adds the ATE side of the LAG to a gosnappi configuration.  Each
+// member port gets a MAC address derived from a.MAC, and a device named a.Name
+// is connected to the LAG with the addresses of a, using peer as the gateway.
+func (l *LAG) AddToOTG(top gosnappi.Config, ports []*ondatra.Port, a, peer *attrs.Attributes) error {
+	agg := top.Lags().Add().SetName(a.Name)
+	if l.Type == oc.IfAggregate_AggregationType_STATIC {
+		agg.Protocol().SetChoice("static").Static().SetLagId(l.otgLagID())
+	} else {
+		agg.Protocol().SetChoice("lacp")
+	}
+	for i, p := range ports {
+		port := top.Ports().Add().SetName(p.ID())
+		mac, err := incrementMAC(a.MAC, i+1)
+		if err != nil {
+			return err
+		}
+		lagPort := agg.Ports().Add().SetPortName(port.Name())
+		lagPort.Ethernet().SetMac(mac).SetName(fmt.Sprintf("%s.%s", a.Name, p.ID()))
+		if l.Type != oc.IfAggregate_AggregationType_STATIC {
+			lagPort.Lacp().SetActorActivity("active").SetActorPortNumber(int32(i) + 1).SetActorPortPriority(1).SetLacpduTimeout(0)
+		}
+	}
+
+	dev := top.Devices().Add().SetName(agg.Name())
+	eth := dev.Ethernets().Add().SetName(a.Name + ".Eth").SetPortName(agg.Name()).SetMac(a.MAC)
+	eth.Connection().SetChoice("lag_name").SetLagName(agg.Name())
+	if a.MTU > 0 {
+		eth.SetMtu(int32(a.MTU))
+	}
+	if a.IPv4 != "" {
+		eth.Ipv4Addresses().Add().SetName(a.Name + ".IPv4").SetAddress(a.IPv4).SetGateway(peer.IPv4).SetPrefix(int32(a.IPv4Len))
+	}
+	if a.IPv6 != "" {
+		eth.Ipv6Addresses().Add().SetName(a.Name + ".IPv6").SetAddress(a.IPv6).SetGateway(peer.IPv6).SetPrefix(int32(a.IPv6Len))
+	}
+	return nil
+}
+
+// AddToATE adds the ATE side of the LAG to an ATETopology, and returns the
+// interface connected to the LAG.
+func (l *LAG) AddToATE(top *ondatra.ATETopology, ports []*ondatra.Port, a, peer *attrs.Attributes) *ondatra.Interface {
+	// Don't use WithLACPEnabled which is for emulated Ixia LACP.
+	lag := top.AddLAG(a.Name + ".LAG").WithPorts(ports...)
+	lag.LACP().WithEnabled(l.Type == oc.IfAggregate_AggregationType_LACP)
+	i := top.AddInterface(a.Name).WithLAG(lag)
+	if a.MTU > 0 {
+		i.Ethernet().WithMTU(a.MTU)
+	}
+	if a.IPv4 != "" {
+		i.IPv4().
+			WithAddress(a.IPv4CIDR()).
+			WithDefaultGateway(peer.IPv4)
+	}
+	if a.IPv6 != "" {
+		i.IPv6().
+			WithAddress(a.IPv6CIDR()).
+			WithDefaultGateway(peer.IPv6)
+	}
+	return i
+}
+
+// AwaitOTGUp waits until the named LAG on the OTG is oper-UP with at least n
+// member ports up.
+func AwaitOTGUp(t testing.TB, otg *otg.OTG, name string, timeout time.Duration, n int) {
+	t.Helper()
+	var got *otgtelemetry.Lag
+	_, ok := gnmi.Watch(t, otg, gnmi.OTG().Lag(name).State(), timeout, func(v *ygnmi.Value[*otgtelemetry.Lag]) bool {
+		got, _ = v.Val()
+		return got.GetOperStatus() == otgtelemetry.Lag_OperStatus_UP &&
+			got.GetCounters().GetMemberPortsUp() >= uint64(n)
+	}).Await(t)
+	if !ok {
+		t.Fatalf("OTG LAG %s got oper-status %v with %d member ports up, want UP with at least %d",
+			name, got.GetOperStatus(), got.GetCounters().GetMemberPortsUp(), n)
+	}
+}
+
+// incrementMAC increments the MAC by i.  Returns error if the mac cannot be
+// parsed or overflows the mac address space.
+func incrementMAC(mac string, i int) (string, error) {
+	macAddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return "", err
+	}
+	var n uint64
+	for _, b := range macAddr {
+		n = n<<8 | uint64(b)
+	}
+	n += uint64(i)
+	if n >= 1<<48 {
+		return "", fmt.Errorf("MAC %s overflows when incremented by %d", mac, i)
+	}
+	for j := len(macAddr) - 1; j >= 0; j-- {
+		macAddr[j] = byte(n)
+		n >>= 8
+	}
+	return macAddr.String(), nil
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lag
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/ondatra/gnmi/oc"
+	"google.golang.org/protobuf/testing/protocmp"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+func TestConfigOC(t *testing.T) {
+	l := &LAG{
+		Name:     "Port-Channel7",
+		Type:     oc.IfAggregate_AggregationType_LACP,
+		MinLinks: 2,
+		Members:  []string{"eth2", "eth3"},
+		Attrs: &attrs.Attributes{
+			Desc:    "dutDst",
+			IPv4:    "192.0.2.5",
+			IPv4Len: 30,
+			MAC:     "02:00:00:00:00:01",
+		},
+	}
+	root := &oc.Root{}
+	l.ConfigOC(root)
+
+	if got, want := root.GetLacp().GetInterface(l.Name).GetLacpMode(), oc.Lacp_LacpActivityType_ACTIVE; got != want {
+		t.Errorf("LACP mode got %v, want %v", got, want)
+	}
+	agg := root.GetInterface(l.Name)
+	if got, want := agg.GetType(), oc.IETFInterfaces_InterfaceType_ieee8023adLag; got != want {
+		t.Errorf("Aggregate type got %v, want %v", got, want)
+	}
+	if got, want := agg.GetAggregation().GetMinLinks(), uint16(2); got != want {
+		t.Errorf("Aggregate min-links got %d, want %d", got, want)
+	}
+	if agg.GetEthernet() != nil {
+		t.Errorf("Aggregate got ethernet config %v, want none", agg.GetEthernet())
+	}
+	if got := agg.GetSubinterface(0).GetIpv4().GetAddress("192.0.2.5"); got == nil {
+		t.Errorf("Aggregate is missing IPv4 address")
+	}
+	for _, member := range l.Members {
+		if got := root.GetInterface(member).GetEthernet().GetAggregateId(); got != l.Name {
+			t.Errorf("Member %s aggregate-id got %q, want %q", member, got, l.Name)
+		}
+	}
+
+	l.Type = oc.IfAggregate_AggregationType_STATIC
+	root = &oc.Root{}
+	l.ConfigOC(root)
+	if root.GetLacp() != nil {
+		t.Errorf("Static LAG got LACP config %v, want none", root.GetLacp())
+	}
+}
+
+func TestOTGLagID(t *testing.T) {
+	for name, want := range map[string]int32{
+		"Port-Channel7": 7,
+		"ae10":          10,
+		"Bundle-Ether":  1,
+	} {
+		l := &LAG{Name: name}
+		if got := l.otgLagID(); got != want {
+			t.Errorf("otgLagID(%q) got %d, want %d", name, got, want)
+		}
+	}
+}
+
+func TestIncrementMAC(t *testing.T) {
+	got, err := incrementMAC("02:12:01:00:00:ff", 2)
+	if err != nil {
+		t.Fatalf("incrementMAC got error: %v", err)
+	}
+	if want := "02:12:01:00:01:01"; got != want {
+		t.Errorf("incrementMAC got %q, want %q", got, want)
+	}
+	if _, err := incrementMAC("ff:ff:ff:ff:ff:ff", 1); err == nil {
+		t.Errorf("incrementMAC got no error on overflow")
+	}
+	if _, err := incrementMAC("not a mac", 1); err == nil {
+		t.Errorf("incrementMAC got no error on bad MAC")
+	}
+}
+
+func TestInterfacesUpdate(t *testing.T) {
+	l := &LAG{
+		Name:    "Port-Channel7",
+		Type:    oc.IfAggregate_AggregationType_LACP,
+		Members: []string{"eth2"},
+	}
+	root := &oc.Root{}
+	l.ConfigOC(root)
+	req, err := interfacesUpdate(root)
+	if err != nil {
+		t.Fatalf("interfacesUpdate got error: %v", err)
+	}
+	if len(req.GetUpdate()) != 1 {
+		t.Fatalf("interfacesUpdate got %d updates, want 1", len(req.GetUpdate()))
+	}
+	u := req.GetUpdate()[0]
+	if diff := cmp.Diff(&gpb.Path{Origin: "openconfig", Elem: []*gpb.PathElem{{Name: "interfaces"}}}, u.GetPath(), protocmp.Transform()); diff != "" {
+		t.Errorf("interfacesUpdate path -want, +got:\n%s", diff)
+	}
+	var val map[string][]map[string]interface{}
+	if err := json.Unmarshal(u.GetVal().GetJsonIetfVal(), &val); err != nil {
+		t.Fatalf("Cannot unmarshal the update value: %v", err)
+	}
+	var names []string
+	for _, intf := range val["openconfig-interfaces:interface"] {
+		names = append(names, intf["name"].(string))
+	}
+	sort.Strings(names)
+	if diff := cmp.Diff([]string{"Port-Channel7", "eth2"}, names); diff != "" {
+		t.Errorf("interfacesUpdate interfaces -want, +got:\n%s", diff)
+	}
+	for _, s := range []string{"lacp", `"state"`} {
+		if strings.Contains(string(u.GetVal().GetJsonIetfVal()), s) {
+			t.Errorf("interfacesUpdate got %s in %s", s, u.GetVal().GetJsonIetfVal())
+		}
+	}
+}
+
+func TestPathKey(t *testing.T) {
+	p := &gpb.Path{Elem: []*gpb.PathElem{
+		{Name: "lacp"},
+		{Name: "interfaces"},
+		{Name: "interface", Key: map[string]string{"name": "Port-Channel7"}},
+		{Name: "members"},
+		{Name: "member", Key: map[string]string{"interface": "eth2"}},
+		{Name: "state"},
+		{Name: "synchronization"},
+	}}
+	if got, want := pathKey("member", "interface")(p), "eth2"; got != want {
+		t.Errorf("pathKey(member, interface) got %q, want %q", got, want)
+	}
+	if got, want := pathKey("interface", "name")(p), "Port-Channel7"; got != want {
+		t.Errorf("pathKey(interface, name) got %q, want %q", got, want)
+	}
+	if got := pathKey("neighbor", "address")(p); got != "" {
+		t.Errorf("pathKey(neighbor, address) got %q, want empty", got)
+	}
+}
@@ -0,0 +1,274 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otgutils
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/ondatra/gnmi"
+	"github.com/openconfig/ondatra/otg"
+)
+
+const (
+	// settleTimeout is how long the assertions wait for the flow counters
+	// to settle.
+	settleTimeout = time.Minute
+	// pollInterval is the interval between polls of the flow counters.
+	pollInterval = time.Second
+)
+
+// WaitForFlowCounters waits until all flows of the config have stopped
+// transmitting and their packet counters are unchanged between two
+// consecutive polls, and returns their metrics.  It is fatal if the counters
+// do not settle within the timeout.
+func WaitForFlowCounters(t testing.TB, otg *otg.OTG, c gosnappi.Config, timeout time.Duration) []*FlowMetrics {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	var prev []*FlowMetrics
+	for {
+		metrics := GetFlowMetrics(t, otg, c)
+		if !transmitting(t, otg, c) && countersEqual(prev, metrics) {
+			return metrics
+		}
+		if time.Now().After(deadline) {
+			t.Log(flowMetricsTable(metrics))
+			t.Fatalf("OTG flow counters did not settle within %v", timeout)
+		}
+		prev = metrics
+		time.Sleep(pollInterval)
+	}
+}
+
+// transmitting returns whether any flow of the config is transmitting.
+func transmitting(t testing.TB, otg *otg.OTG, c gosnappi.Config) bool {
+	t.Helper()
+	for _, f := range c.Flows().Items() {
+		if gnmi.Get(t, otg, gnmi.OTG().Flow(f.Name()).Transmit().State()) {
+			return true
+		}
+	}
+	return false
+}
+
+// countersEqual returns whether the packet counters of two polls of the same
+// flows are equal.
+func countersEqual(a, b []*FlowMetrics) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].TxPkts != b[i].TxPkts || a[i].RxPkts != b[i].RxPkts {
+			return false
+		}
+	}
+	return true
+}
+
+// lossPct returns the percentage of the transmitted packets of the flow that
+// were not received, computed from its counters.
+func lossPct(m *FlowMetrics) float64 {
+	if m.TxPkts == 0 {
+		return 0
+	}
+	return float64(m.LostPkts) * 100 / float64(m.TxPkts)
+}
+
+// flowByName returns the metrics of the named flow, or an error if there are
+// none.
+func flowByName(metrics []*FlowMetrics, name string) (*FlowMetrics, error) {
+	for _, m := range metrics {
+		if m.Name == name {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("no metrics for OTG flow %q", name)
+}
+
+// checkLoss returns an error unless the loss of the flow is within tolerance
+// percentage points of wantPct.
+func checkLoss(metrics []*FlowMetrics, name string, wantPct, tolerance float64) error {
+	m, err := flowByName(metrics, name)
+	if err != nil {
+		return err
+	}
+	if m.TxPkts == 0 {
+		return fmt.Errorf("OTG flow %q transmitted no packets", name)
+	}
+	if got := lossPct(m); math.Abs(got-wantPct) > tolerance {
+		return fmt.Errorf("OTG flow %q lost %.3f%% of packets (%d of %d), want %.3f%% ± %.3f", name, got, m.LostPkts, m.TxPkts, wantPct, tolerance)
+	}
+	return nil
+}
+
+// checkNoLoss returns an error unless all named flows, or all flows if none
+// are named, transmitted packets and received all of them.
+func checkNoLoss(metrics []*FlowMetrics, names []string) error {
+	if len(names) == 0 {
+		for _, m := range metrics {
+			names = append(names, m.Name)
+		}
+	}
+	var lossy []string
+	for _, name := range names {
+		m, err := flowByName(metrics, name)
+		if err != nil {
+			return err
+		}
+		if m.TxPkts == 0 || m.RxPkts < m.TxPkts {
+			lossy = append(lossy, fmt.Sprintf("%s (rx %d of %d)", name, m.RxPkts, m.TxPkts))
+		}
+	}
+	if len(lossy) > 0 {
+		return fmt.Errorf("OTG flows with loss or no traffic: %q", lossy)
+	}
+	return nil
+}
+
+// checkSplit returns an error unless the share of each count in the total of
+// the counts is within tolerance percentage points of the share of its weight
+// in the total of the weights.
+func checkSplit(counts map[string]uint64, weights map[string]float64, tolerance float64) error {
+	var totalCount uint64
+	var totalWeight float64
+	for name, w := range weights {
+		if w < 0 {
+			return fmt.Errorf("weight of %q is negative: %v", name, w)
+		}
+		totalCount += counts[name]
+		totalWeight += w
+	}
+	if totalWeight == 0 {
+		return fmt.Errorf("weights %v sum to zero", weights)
+	}
+	if totalCount == 0 {
+		return fmt.Errorf("no packets received by %v", sortedKeys(weights))
+	}
+	var diffs []string
+	for _, name := range sortedKeys(weights) {
+		got := float64(counts[name]) * 100 / float64(totalCount)
+		want := weights[name] * 100 / totalWeight
+		if math.Abs(got-want) > tolerance {
+			diffs = append(diffs, fmt.Sprintf("%s received %.2f%% (%d of %d), want %.2f%% ± %.2f", name, got, counts[name], totalCount, want, tolerance))
+		}
+	}
+	if len(diffs) > 0 {
+		return fmt.Errorf("traffic split outside tolerance: %q", diffs)
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fail reports err, if any, with the table of the flow metrics.
+func fail(t testing.TB, metrics []*FlowMetrics, err error) {
+	t.Helper()
+	if err != nil {
+		t.Log(flowMetricsTable(metrics))
+		t.Error(err)
+	}
+}
+
+// AssertFlowLoss waits for the flow counters to settle and checks that the
+// named flow lost wantPct percent of its packets, within tolerance percentage
+// points.
+func AssertFlowLoss(t testing.TB, otg *otg.OTG, c gosnappi.Config, flow string, wantPct, tolerance float64) {
+	t.Helper()
+	metrics := WaitForFlowCounters(t, otg, c, settleTimeout)
+	fail(t, metrics, checkLoss(metrics, flow, wantPct, tolerance))
+}
+
+// AssertNoFlowLoss waits for the flow counters to settle and checks that the
+// named flows, or all flows of the config if none are named, transmitted
+// packets and lost none of them.
+func AssertNoFlowLoss(t testing.TB, otg *otg.OTG, c gosnappi.Config, flows ...string) {
+	t.Helper()
+	metrics := WaitForFlowCounters(t, otg, c, settleTimeout)
+	fail(t, metrics, checkNoLoss(metrics, flows))
+}
+
+// AssertFlowSplit waits for the flow counters to settle and checks that the
+// packets received by the flows, which are the keys of weights, are split in
+// the ratio of their weights, within tolerance percentage points of the
+// total.
+//
+// For example, with weights {"a": 1, "b": 3} and tolerance 2, flow a must
+// receive between 23% and 27% of the packets received by a and b.
+func AssertFlowSplit(t testing.TB, otg *otg.OTG, c gosnappi.Config, weights map[string]float64, tolerance float64) {
+	t.Helper()
+	metrics := WaitForFlowCounters(t, otg, c, settleTimeout)
+	counts := make(map[string]uint64)
+	for _, m := range metrics {
+		counts[m.Name] = m.RxPkts
+	}
+	for name := range weights {
+		if _, err := flowByName(metrics, name); err != nil {
+			fail(t, metrics, err)
+			return
+		}
+	}
+	fail(t, metrics, checkSplit(counts, weights, tolerance))
+}
+
+// AssertPortSplit waits for the flow counters to settle and checks that the
+// frames received by the ports, which are the keys of weights, are split in
+// the ratio of their weights, within tolerance percentage points of the
+// total.  The received frames include control plane traffic, which the
+// tolerance must allow for.
+func AssertPortSplit(t testing.TB, otg *otg.OTG, c gosnappi.Config, weights map[string]float64, tolerance float64) {
+	t.Helper()
+	metrics := WaitForFlowCounters(t, otg, c, settleTimeout)
+	counts := make(map[string]uint64)
+	for _, m := range GetPortMetrics(t, otg, c) {
+		counts[m.Name] = m.RxFrames
+	}
+	for name := range weights {
+		if _, ok := counts[name]; !ok {
+			fail(t, metrics, fmt.Errorf("no metrics for OTG port %q", name))
+			return
+		}
+	}
+	fail(t, metrics, checkSplit(counts, weights, tolerance))
+}
+
+// AssertFlowMinRxRate checks that the received frame rate of the named flow
+// reaches minRate frames per second within the timeout.  Unlike the other
+// assertions it must be called while the flow is transmitting.
+func AssertFlowMinRxRate(t testing.TB, otg *otg.OTG, c gosnappi.Config, flow string, minRate float32, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	var rate float32
+	for {
+		rate = gnmi.Get(t, otg, gnmi.OTG().Flow(flow).InFrameRate().State())
+		if rate >= minRate || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(pollInterval)
+	}
+	if rate < minRate {
+		fail(t, GetFlowMetrics(t, otg, c), fmt.Errorf("OTG flow %q received %v frames per second within %v, want at least %v", flow, rate, timeout, minRate))
+	}
+}
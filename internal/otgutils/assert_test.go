@@ -0,0 +1,50 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otgutils
+
+import (
+	"testing"
+)
+
+func TestChecks(t *testing.T) {
+	metrics := []*FlowMetrics{
+		{Name: "clean", TxPkts: 1000, RxPkts: 1000},
+		{Name: "lossy", TxPkts: 1000, RxPkts: 900, LostPkts: 100},
+		{Name: "idle"},
+	}
+
+	cases := []struct {
+		desc    string
+		err     error
+		wantErr bool
+	}{
+		{"loss within tolerance", checkLoss(metrics, "lossy", 9.5, 1), false},
+		{"loss outside tolerance", checkLoss(metrics, "lossy", 5, 1), true},
+		{"loss of idle flow", checkLoss(metrics, "idle", 0, 1), true},
+		{"loss of unknown flow", checkLoss(metrics, "missing", 0, 1), true},
+		{"no loss", checkNoLoss(metrics, []string{"clean"}), false},
+		{"no loss of lossy flow", checkNoLoss(metrics, []string{"clean", "lossy"}), true},
+		{"no loss of all flows", checkNoLoss(metrics, nil), true},
+		{"split within tolerance", checkSplit(map[string]uint64{"a": 260, "b": 740}, map[string]float64{"a": 1, "b": 3}, 2), false},
+		{"split outside tolerance", checkSplit(map[string]uint64{"a": 300, "b": 700}, map[string]float64{"a": 1, "b": 3}, 2), true},
+		{"split of nothing", checkSplit(map[string]uint64{}, map[string]float64{"a": 1, "b": 1}, 2), true},
+		{"split with zero weights", checkSplit(map[string]uint64{"a": 1}, map[string]float64{"a": 0}, 2), true},
+	}
+	for _, tc := range cases {
+		if gotErr := tc.err != nil; gotErr != tc.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tc.desc, tc.err, tc.wantErr)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otgutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/ondatra/otg"
+)
+
+// EnableCapture enables pcap capture of the frames received on the named
+// ports of the config, which must be pushed afterwards.
+//
+// Usage:
+//
+//	otgutils.EnableCapture(config, "port2")
+//	otg.PushConfig(t, config)
+//	otgutils.StartCapture(t, otg, config)
+//	otg.StartTraffic(t)
+//	...
+//	otg.StopTraffic(t)
+//	otgutils.StopCapture(t, otg, config)
+//	packets := otgutils.FetchCapture(t, otg, "port2")
+//	ipv4 := otgutils.SelectPackets(packets, otgutils.IPv4Packets, otgutils.DstIPPackets("198.51.100.1"))
+//	if err := otgutils.CheckDSCP(ipv4, 46); err != nil {
+//	  t.Error(err)
+//	}
+func EnableCapture(c gosnappi.Config, ports ...string) {
+	c.Captures().Add().
+		SetName("capture_" + strings.Join(ports, "_")).
+		SetPortNames(ports).
+		SetFormat(gosnappi.CaptureFormat.PCAP)
+}
+
+// CapturePorts returns the names of the ports with capture enabled in the
+// config.
+func CapturePorts(c gosnappi.Config) []string {
+	var ports []string
+	for _, capture := range c.Captures().Items() {
+		ports = append(ports, capture.PortNames()...)
+	}
+	return ports
+}
+
+// StartCapture starts capture on the ports with capture enabled in the config.
+func StartCapture(t testing.TB, otg *otg.OTG, c gosnappi.Config) {
+	t.Helper()
+	otg.StartCapture(t, CapturePorts(c)...)
+}
+
+// StopCapture stops capture on the ports with capture enabled in the config.
+func StopCapture(t testing.TB, otg *otg.OTG, c gosnappi.Config) {
+	t.Helper()
+	otg.StopCapture(t, CapturePorts(c)...)
+}
+
+// FetchCapture fetches the capture of the named port, writes it as a pcap
+// test artifact into -outputs_dir, and returns its packets.  Capture must be
+// stopped first.
+func FetchCapture(t testing.TB, otg *otg.OTG, port string) []gopacket.Packet {
+	t.Helper()
+	b := otg.FetchCapture(t, port)
+	if err := fptest.WriteOutput("capture_"+port, ".pcap", string(b)); err != nil {
+		t.Errorf("Cannot write the capture of port %s: %v", port, err)
+	}
+	packets, err := ParseCapture(b)
+	if err != nil {
+		t.Fatalf("Cannot parse the capture of port %s: %v", port, err)
+	}
+	t.Logf("Captured %d packets on port %s", len(packets), port)
+	return packets
+}
+
+// pcapngMagic is the block type of the section header that starts a pcapng
+// file.
+const pcapngMagic = 0x0a0d0d0a
+
+// ParseCapture returns the packets of a capture in the pcap or pcapng format.
+func ParseCapture(b []byte) ([]gopacket.Packet, error) {
+	if len(b) < 4 {
+		return nil, fmt.Errorf("capture of %d bytes is too short", len(b))
+	}
+	var r interface {
+		ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+	}
+	var linkType layers.LinkType
+	if binary.LittleEndian.Uint32(b) == pcapngMagic {
+		ng, err := pcapgo.NewNgReader(bytes.NewReader(b), pcapgo.DefaultNgReaderOptions)
+		if err != nil {
+			return nil, err
+		}
+		r, linkType = ng, ng.LinkType()
+	} else {
+		pr, err := pcapgo.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		r, linkType = pr, pr.LinkType()
+	}
+	var packets []gopacket.Packet
+	for {
+		data, ci, err := r.ReadPacketData()
+		if errors.Is(err, io.EOF) {
+			return packets, nil
+		}
+		if err != nil {
+			return packets, fmt.Errorf("packet %d: %w", len(packets), err)
+		}
+		p := gopacket.NewPacket(data, linkType, gopacket.Default)
+		p.Metadata().CaptureInfo = ci
+		packets = append(packets, p)
+	}
+}
+
+// PacketFilter selects packets.
+type PacketFilter func(gopacket.Packet) bool
+
+// IPv4Packets selects IPv4 packets.
+func IPv4Packets(p gopacket.Packet) bool {
+	return p.Layer(layers.LayerTypeIPv4) != nil
+}
+
+// IPv6Packets selects IPv6 packets.
+func IPv6Packets(p gopacket.Packet) bool {
+	return p.Layer(layers.LayerTypeIPv6) != nil
+}
+
+// DstIPPackets returns a filter of IPv4 or IPv6 packets to the address.
+func DstIPPackets(addr string) PacketFilter {
+	ip := net.ParseIP(addr)
+	return func(p gopacket.Packet) bool {
+		if n := p.NetworkLayer(); n != nil {
+			return net.IP(n.NetworkFlow().Dst().Raw()).Equal(ip)
+		}
+		return false
+	}
+}
+
+// SelectPackets returns the packets that all the filters select.
+func SelectPackets(packets []gopacket.Packet, filters ...PacketFilter) []gopacket.Packet {
+	var selected []gopacket.Packet
+next:
+	for _, p := range packets {
+		for _, f := range filters {
+			if !f(p) {
+				continue next
+			}
+		}
+		selected = append(selected, p)
+	}
+	return selected
+}
+
+// dscp returns the DSCP of an IPv4 or IPv6 packet.
+func dscp(p gopacket.Packet) (uint8, bool) {
+	if ip, ok := p.Layer(layers.LayerTypeIPv4).(*layers.IPv4); ok {
+		return ip.TOS >> 2, true
+	}
+	if ip, ok := p.Layer(layers.LayerTypeIPv6).(*layers.IPv6); ok {
+		return ip.TrafficClass >> 2, true
+	}
+	return 0, false
+}
+
+// CheckPackets returns an error if there are no packets or if check returns
+// an error for any of them, describing the first few failures.
+func CheckPackets(packets []gopacket.Packet, check func(gopacket.Packet) error) error {
+	const maxErrs = 5
+	if len(packets) == 0 {
+		return fmt.Errorf("no packets to check")
+	}
+	var errs []string
+	failed := 0
+	for i, p := range packets {
+		if err := check(p); err != nil {
+			failed++
+			if len(errs) < maxErrs {
+				errs = append(errs, fmt.Sprintf("packet %d: %v", i, err))
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d packets failed the check:\n  %s", failed, len(packets), strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+// CheckDSCP returns an error unless all the packets are IPv4 or IPv6 packets
+// with the DSCP.
+func CheckDSCP(packets []gopacket.Packet, want uint8) error {
+	return CheckPackets(packets, func(p gopacket.Packet) error {
+		got, ok := dscp(p)
+		switch {
+		case !ok:
+			return fmt.Errorf("not an IP packet")
+		case got != want:
+			return fmt.Errorf("got DSCP %d, want %d", got, want)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otgutils
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+func ipv4Frame(t *testing.T, dst string, tos uint8) []byte {
+	t.Helper()
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 1},
+		DstMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 2},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := &layers.IPv4{
+		Version:  4,
+		TOS:      tos,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.ParseIP("192.0.2.1"),
+		DstIP:    net.ParseIP(dst),
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, ip, gopacket.Payload("payload")); err != nil {
+		t.Fatalf("Cannot serialize frame: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCapture(t *testing.T) {
+	frames := [][]byte{
+		ipv4Frame(t, "198.51.100.1", 46<<2),
+		ipv4Frame(t, "198.51.100.1", 46<<2),
+		ipv4Frame(t, "198.51.100.2", 48<<2),
+	}
+
+	var pcap bytes.Buffer
+	w := pcapgo.NewWriter(&pcap)
+	if err := w.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
+		t.Fatalf("Cannot write pcap header: %v", err)
+	}
+	var pcapng bytes.Buffer
+	ngw, err := pcapgo.NewNgWriter(&pcapng, layers.LinkTypeEthernet)
+	if err != nil {
+		t.Fatalf("Cannot create pcapng writer: %v", err)
+	}
+	for _, f := range frames {
+		ci := gopacket.CaptureInfo{Timestamp: time.Unix(0, 0), CaptureLength: len(f), Length: len(f), InterfaceIndex: 0}
+		if err := w.WritePacket(ci, f); err != nil {
+			t.Fatalf("Cannot write pcap packet: %v", err)
+		}
+		if err := ngw.WritePacket(ci, f); err != nil {
+			t.Fatalf("Cannot write pcapng packet: %v", err)
+		}
+	}
+	if err := ngw.Flush(); err != nil {
+		t.Fatalf("Cannot flush pcapng writer: %v", err)
+	}
+
+	for _, tc := range []struct {
+		format string
+		b      []byte
+	}{{"pcap", pcap.Bytes()}, {"pcapng", pcapng.Bytes()}} {
+		t.Run(tc.format, func(t *testing.T) {
+			packets, err := ParseCapture(tc.b)
+			if err != nil {
+				t.Fatalf("ParseCapture failed: %v", err)
+			}
+			if got, want := len(packets), len(frames); got != want {
+				t.Fatalf("ParseCapture got %d packets, want %d", got, want)
+			}
+			if got := len(SelectPackets(packets, IPv6Packets)); got != 0 {
+				t.Errorf("SelectPackets of IPv6 got %d packets, want 0", got)
+			}
+			selected := SelectPackets(packets, IPv4Packets, DstIPPackets("198.51.100.1"))
+			if got, want := len(selected), 2; got != want {
+				t.Errorf("SelectPackets of IPv4 to 198.51.100.1 got %d packets, want %d", got, want)
+			}
+			if err := CheckDSCP(selected, 46); err != nil {
+				t.Errorf("CheckDSCP of selected packets got error: %v", err)
+			}
+			if err := CheckDSCP(packets, 46); err == nil {
+				t.Errorf("CheckDSCP of all packets got nil error, want error")
+			}
+			if err := CheckDSCP(nil, 46); err == nil {
+				t.Errorf("CheckDSCP of no packets got nil error, want error")
+			}
+		})
+	}
+
+	if _, err := ParseCapture([]byte{1, 2}); err == nil {
+		t.Errorf("ParseCapture of garbage got nil error, want error")
+	}
+}
@@ -0,0 +1,344 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otgutils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/featureprofiles/internal/fptest"
+	"github.com/openconfig/ondatra/gnmi"
+	"github.com/openconfig/ondatra/otg"
+	"github.com/openconfig/ygot/ygot"
+
+	otgtelemetry "github.com/openconfig/ondatra/gnmi/otg"
+)
+
+// FlowMetrics are the statistics of an OTG flow.
+//
+// The OTG telemetry does not report the latency of flows, so it is not
+// collected.
+type FlowMetrics struct {
+	Name     string  `json:"name"`
+	TxPkts   uint64  `json:"tx_pkts"`
+	RxPkts   uint64  `json:"rx_pkts"`
+	TxOctets uint64  `json:"tx_octets"`
+	RxOctets uint64  `json:"rx_octets"`
+	TxRate   float32 `json:"tx_rate"`
+	RxRate   float32 `json:"rx_rate"`
+	// LossPct is the loss percentage reported by the OTG.
+	LossPct float32 `json:"loss_pct"`
+	// LostPkts is the number of transmitted packets that were not received,
+	// or zero if more were received than transmitted.
+	LostPkts uint64 `json:"lost_pkts"`
+}
+
+// PortMetrics are the statistics of an OTG port.
+type PortMetrics struct {
+	Name     string  `json:"name"`
+	TxFrames uint64  `json:"tx_frames"`
+	RxFrames uint64  `json:"rx_frames"`
+	TxOctets uint64  `json:"tx_octets"`
+	RxOctets uint64  `json:"rx_octets"`
+	TxRate   float32 `json:"tx_rate"`
+	RxRate   float32 `json:"rx_rate"`
+	Link     string  `json:"link"`
+}
+
+// LAGMetrics are the statistics of an OTG LAG.
+type LAGMetrics struct {
+	Name          string `json:"name"`
+	OperStatus    string `json:"oper_status"`
+	TxFrames      uint64 `json:"tx_frames"`
+	RxFrames      uint64 `json:"rx_frames"`
+	MemberPortsUp uint64 `json:"member_ports_up"`
+}
+
+// LACPMetrics are the LACP states of a member port of an OTG LAG.
+type LACPMetrics struct {
+	LAG             string `json:"lag"`
+	MemberPort      string `json:"member_port"`
+	Synchronization string `json:"synchronization"`
+	Collecting      bool   `json:"collecting"`
+	Distributing    bool   `json:"distributing"`
+	SystemID        string `json:"system_id"`
+	PartnerID       string `json:"partner_id"`
+}
+
+// BGPPeerMetrics are the statistics of an OTG BGP peer.
+type BGPPeerMetrics struct {
+	Name string `json:"name"`
+	// IPVersion is 4 or 6, by the interfaces of the peer.
+	IPVersion       int    `json:"ip_version"`
+	SessionState    string `json:"session_state"`
+	Flaps           uint64 `json:"flaps"`
+	RoutesRx        uint64 `json:"routes_rx"`
+	RoutesTx        uint64 `json:"routes_tx"`
+	RouteWithdrawRx uint64 `json:"route_withdraw_rx"`
+	RouteWithdrawTx uint64 `json:"route_withdraw_tx"`
+	UpdatesRx       uint64 `json:"updates_rx"`
+	UpdatesTx       uint64 `json:"updates_tx"`
+	NotificationsRx uint64 `json:"notifications_rx"`
+	NotificationsTx uint64 `json:"notifications_tx"`
+}
+
+// ISISRouterMetrics are the statistics of an OTG IS-IS router.
+type ISISRouterMetrics struct {
+	Name           string `json:"name"`
+	L1SessionsUp   uint64 `json:"l1_sessions_up"`
+	L1SessionsFlap uint64 `json:"l1_sessions_flap"`
+	L1DatabaseSize uint64 `json:"l1_database_size"`
+	L1LSPRx        uint64 `json:"l1_lsp_rx"`
+	L1LSPTx        uint64 `json:"l1_lsp_tx"`
+	L2SessionsUp   uint64 `json:"l2_sessions_up"`
+	L2SessionsFlap uint64 `json:"l2_sessions_flap"`
+	L2DatabaseSize uint64 `json:"l2_database_size"`
+	L2LSPRx        uint64 `json:"l2_lsp_rx"`
+	L2LSPTx        uint64 `json:"l2_lsp_tx"`
+}
+
+// GetFlowMetrics returns the statistics of the flows of the config.
+func GetFlowMetrics(t testing.TB, otg *otg.OTG, c gosnappi.Config) []*FlowMetrics {
+	t.Helper()
+	var metrics []*FlowMetrics
+	for _, f := range c.Flows().Items() {
+		flow := gnmi.Get(t, otg, gnmi.OTG().Flow(f.Name()).State())
+		m := &FlowMetrics{
+			Name:     f.Name(),
+			TxPkts:   flow.GetCounters().GetOutPkts(),
+			RxPkts:   flow.GetCounters().GetInPkts(),
+			TxOctets: flow.GetCounters().GetOutOctets(),
+			RxOctets: flow.GetCounters().GetInOctets(),
+			TxRate:   ygot.BinaryToFloat32(flow.GetOutFrameRate()),
+			RxRate:   ygot.BinaryToFloat32(flow.GetInFrameRate()),
+			LossPct:  ygot.BinaryToFloat32(flow.GetLossPct()),
+		}
+		if m.TxPkts > m.RxPkts {
+			m.LostPkts = m.TxPkts - m.RxPkts
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// GetPortMetrics returns the statistics of the ports of the config.
+func GetPortMetrics(t testing.TB, otg *otg.OTG, c gosnappi.Config) []*PortMetrics {
+	t.Helper()
+	var metrics []*PortMetrics
+	for _, p := range c.Ports().Items() {
+		port := gnmi.Get(t, otg, gnmi.OTG().Port(p.Name()).State())
+		m := &PortMetrics{
+			Name:     p.Name(),
+			TxFrames: port.GetCounters().GetOutFrames(),
+			RxFrames: port.GetCounters().GetInFrames(),
+			TxOctets: port.GetCounters().GetOutOctets(),
+			RxOctets: port.GetCounters().GetInOctets(),
+			TxRate:   ygot.BinaryToFloat32(port.GetOutRate()),
+			RxRate:   ygot.BinaryToFloat32(port.GetInRate()),
+			Link:     "down",
+		}
+		if port.GetLink() == otgtelemetry.Port_Link_UP {
+			m.Link = "up"
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// GetLAGMetrics returns the statistics of the LAGs of the config.
+func GetLAGMetrics(t testing.TB, otg *otg.OTG, c gosnappi.Config) []*LAGMetrics {
+	t.Helper()
+	var metrics []*LAGMetrics
+	for _, l := range c.Lags().Items() {
+		lag := gnmi.Get(t, otg, gnmi.OTG().Lag(l.Name()).State())
+		metrics = append(metrics, &LAGMetrics{
+			Name:          l.Name(),
+			OperStatus:    lag.GetOperStatus().String(),
+			TxFrames:      lag.GetCounters().GetOutFrames(),
+			RxFrames:      lag.GetCounters().GetInFrames(),
+			MemberPortsUp: lag.GetCounters().GetMemberPortsUp(),
+		})
+	}
+	return metrics
+}
+
+// GetLACPMetrics returns the LACP states of the member ports of the LAGs of
+// the config.
+func GetLACPMetrics(t testing.TB, otg *otg.OTG, c gosnappi.Config) []*LACPMetrics {
+	t.Helper()
+	var metrics []*LACPMetrics
+	for _, l := range c.Lags().Items() {
+		for _, p := range l.Ports().Items() {
+			member := gnmi.Get(t, otg, gnmi.OTG().Lacp().LagMember(p.PortName()).State())
+			metrics = append(metrics, &LACPMetrics{
+				LAG:             l.Name(),
+				MemberPort:      p.PortName(),
+				Synchronization: member.GetSynchronization().String(),
+				Collecting:      member.GetCollecting(),
+				Distributing:    member.GetDistributing(),
+				SystemID:        member.GetSystemId(),
+				PartnerID:       member.GetPartnerId(),
+			})
+		}
+	}
+	return metrics
+}
+
+// GetBGPPeerMetrics returns the statistics of the BGPv4 and BGPv6 peers of
+// the devices of the config.
+func GetBGPPeerMetrics(t testing.TB, otg *otg.OTG, c gosnappi.Config) []*BGPPeerMetrics {
+	t.Helper()
+	var metrics []*BGPPeerMetrics
+	for _, p := range bgpPeers(c) {
+		peer := gnmi.Get(t, otg, gnmi.OTG().BgpPeer(p.name).State())
+		counters := peer.GetCounters()
+		metrics = append(metrics, &BGPPeerMetrics{
+			Name:            p.name,
+			IPVersion:       p.ipVersion,
+			SessionState:    peer.GetSessionState().String(),
+			Flaps:           counters.GetFlaps(),
+			RoutesRx:        counters.GetInRoutes(),
+			RoutesTx:        counters.GetOutRoutes(),
+			RouteWithdrawRx: counters.GetInRouteWithdraw(),
+			RouteWithdrawTx: counters.GetOutRouteWithdraw(),
+			UpdatesRx:       counters.GetInUpdates(),
+			UpdatesTx:       counters.GetOutUpdates(),
+			NotificationsRx: counters.GetInNotifications(),
+			NotificationsTx: counters.GetOutNotifications(),
+		})
+	}
+	return metrics
+}
+
+type bgpPeer struct {
+	device    string
+	name      string
+	ipVersion int
+}
+
+// bgpPeers returns the BGP peers of the devices of the config.
+func bgpPeers(c gosnappi.Config) []bgpPeer {
+	var peers []bgpPeer
+	for _, d := range c.Devices().Items() {
+		if !d.HasBgp() {
+			continue
+		}
+		for _, iface := range d.Bgp().Ipv4Interfaces().Items() {
+			for _, p := range iface.Peers().Items() {
+				peers = append(peers, bgpPeer{device: d.Name(), name: p.Name(), ipVersion: 4})
+			}
+		}
+		for _, iface := range d.Bgp().Ipv6Interfaces().Items() {
+			for _, p := range iface.Peers().Items() {
+				peers = append(peers, bgpPeer{device: d.Name(), name: p.Name(), ipVersion: 6})
+			}
+		}
+	}
+	return peers
+}
+
+// GetISISRouterMetrics returns the statistics of the IS-IS routers of the
+// devices of the config.
+func GetISISRouterMetrics(t testing.TB, otg *otg.OTG, c gosnappi.Config) []*ISISRouterMetrics {
+	t.Helper()
+	var metrics []*ISISRouterMetrics
+	for _, d := range c.Devices().Items() {
+		if !d.HasIsis() {
+			continue
+		}
+		name := d.Isis().Name()
+		router := gnmi.Get(t, otg, gnmi.OTG().IsisRouter(name).State())
+		l1 := router.GetCounters().GetLevel1()
+		l2 := router.GetCounters().GetLevel2()
+		metrics = append(metrics, &ISISRouterMetrics{
+			Name:           name,
+			L1SessionsUp:   l1.GetSessionsUp(),
+			L1SessionsFlap: l1.GetSessionsFlap(),
+			L1DatabaseSize: l1.GetDatabaseSize(),
+			L1LSPRx:        l1.GetInLsp(),
+			L1LSPTx:        l1.GetOutLsp(),
+			L2SessionsUp:   l2.GetSessionsUp(),
+			L2SessionsFlap: l2.GetSessionsFlap(),
+			L2DatabaseSize: l2.GetDatabaseSize(),
+			L2LSPRx:        l2.GetInLsp(),
+			L2LSPTx:        l2.GetOutLsp(),
+		})
+	}
+	return metrics
+}
+
+// WriteMetrics writes the metrics as JSON and CSV test artifacts named by
+// name into -outputs_dir, using fptest.WriteOutput.
+//
+// Usage:
+//
+//	if err := otgutils.WriteMetrics("flows", otgutils.GetFlowMetrics(t, otg, config)); err != nil {
+//	  t.Error(err)
+//	}
+func WriteMetrics[M any](name string, metrics []*M) error {
+	j, err := json.MarshalIndent(metrics, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal %s metrics as JSON: %w", name, err)
+	}
+	if err := fptest.WriteOutput(name, ".json", string(j)); err != nil {
+		return err
+	}
+	c, err := MetricsCSV(metrics)
+	if err != nil {
+		return fmt.Errorf("cannot marshal %s metrics as CSV: %w", name, err)
+	}
+	return fptest.WriteOutput(name, ".csv", c)
+}
+
+// MetricsCSV returns the metrics as CSV, one row per metrics struct with a
+// header row of the JSON names of its fields.
+func MetricsCSV[M any](metrics []*M) (string, error) {
+	typ := reflect.TypeOf((*M)(nil)).Elem()
+	if typ.Kind() != reflect.Struct {
+		return "", fmt.Errorf("metrics type %v is not a struct", typ)
+	}
+	var header []string
+	var fields []int
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		header = append(header, name)
+		fields = append(fields, i)
+	}
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write(header)
+	for _, m := range metrics {
+		v := reflect.ValueOf(m).Elem()
+		var row []string
+		for _, i := range fields {
+			row = append(row, fmt.Sprint(v.Field(i).Interface()))
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	return b.String(), w.Error()
+}
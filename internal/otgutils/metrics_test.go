@@ -0,0 +1,43 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otgutils
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMetricsCSV(t *testing.T) {
+	metrics := []*FlowMetrics{
+		{Name: "v4", TxPkts: 100, RxPkts: 98, TxRate: 10.5, RxRate: 10, LossPct: 2, LostPkts: 2},
+		{Name: "flow,with,commas"},
+	}
+	got, err := MetricsCSV(metrics)
+	if err != nil {
+		t.Fatalf("MetricsCSV failed: %v", err)
+	}
+	want := `name,tx_pkts,rx_pkts,tx_octets,rx_octets,tx_rate,rx_rate,loss_pct,lost_pkts
+v4,100,98,0,0,10.5,10,2,2
+"flow,with,commas",0,0,0,0,0,0,0,0
+`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("MetricsCSV -want +got:\n%s", diff)
+	}
+
+	if _, err := MetricsCSV([]*string{}); err == nil {
+		t.Errorf("MetricsCSV of strings got nil error, want error")
+	}
+}
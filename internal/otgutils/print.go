@@ -20,38 +20,32 @@ import (
 	"testing"
 
 	"github.com/open-traffic-generator/snappi/gosnappi"
-	"github.com/openconfig/ondatra/gnmi"
 	"github.com/openconfig/ondatra/otg"
-	"github.com/openconfig/ygot/ygot"
-
-	otgtelemetry "github.com/openconfig/ondatra/gnmi/otg"
 )
 
 // LogFlowMetrics displays the otg flow statistics.
 func LogFlowMetrics(t testing.TB, otg *otg.OTG, c gosnappi.Config) {
 	t.Helper()
+	t.Log(flowMetricsTable(GetFlowMetrics(t, otg, c)))
+}
+
+func flowMetricsTable(metrics []*FlowMetrics) string {
 	var out strings.Builder
 	out.WriteString("\nOTG Flow Metrics\n")
 	fmt.Fprintln(&out, strings.Repeat("-", 80))
 	out.WriteString("\n")
 	fmt.Fprintf(&out, "%-25v%-15v%-15v%-15v%-15v\n", "Name", "Frames Tx", "Frames Rx", "FPS Tx", "FPS Rx")
-	for _, f := range c.Flows().Items() {
-		flowMetrics := gnmi.Get(t, otg, gnmi.OTG().Flow(f.Name()).State())
-		rxPkts := flowMetrics.GetCounters().GetInPkts()
-		txPkts := flowMetrics.GetCounters().GetOutPkts()
-		rxRate := ygot.BinaryToFloat32(flowMetrics.GetInFrameRate())
-		txRate := ygot.BinaryToFloat32(flowMetrics.GetOutFrameRate())
-		out.WriteString(fmt.Sprintf("%-25v%-15v%-15v%-15v%-15v\n", f.Name(), txPkts, rxPkts, txRate, rxRate))
+	for _, m := range metrics {
+		out.WriteString(fmt.Sprintf("%-25v%-15v%-15v%-15v%-15v\n", m.Name, m.TxPkts, m.RxPkts, m.TxRate, m.RxRate))
 	}
 	fmt.Fprintln(&out, strings.Repeat("-", 80))
 	out.WriteString("\n\n")
-	t.Log(out.String())
+	return out.String()
 }
 
 // LogPortMetrics displays otg port stats.
 func LogPortMetrics(t testing.TB, otg *otg.OTG, c gosnappi.Config) {
 	t.Helper()
-	var link string
 	var out strings.Builder
 	out.WriteString("\nOTG Port Metrics\n")
 	fmt.Fprintln(&out, strings.Repeat("-", 120))
@@ -59,21 +53,10 @@ func LogPortMetrics(t testing.TB, otg *otg.OTG, c gosnappi.Config) {
 	fmt.Fprintf(&out,
 		"%-25s%-15s%-15s%-15s%-15s%-15s%-15s%-15s\n",
 		"Name", "Frames Tx", "Frames Rx", "Bytes Tx", "Bytes Rx", "FPS Tx", "FPS Rx", "Link")
-	for _, p := range c.Ports().Items() {
-		portMetrics := gnmi.Get(t, otg, gnmi.OTG().Port(p.Name()).State())
-		rxFrames := portMetrics.GetCounters().GetInFrames()
-		txFrames := portMetrics.GetCounters().GetOutFrames()
-		rxRate := ygot.BinaryToFloat32(portMetrics.GetInRate())
-		txRate := ygot.BinaryToFloat32(portMetrics.GetOutRate())
-		rxBytes := portMetrics.GetCounters().GetInOctets()
-		txBytes := portMetrics.GetCounters().GetOutOctets()
-		link = "down"
-		if portMetrics.GetLink() == otgtelemetry.Port_Link_UP {
-			link = "up"
-		}
+	for _, m := range GetPortMetrics(t, otg, c) {
 		out.WriteString(fmt.Sprintf(
 			"%-25v%-15v%-15v%-15v%-15v%-15v%-15v%-15v\n",
-			p.Name(), txFrames, rxFrames, txBytes, rxBytes, txRate, rxRate, link,
+			m.Name, m.TxFrames, m.RxFrames, m.TxOctets, m.RxOctets, m.TxRate, m.RxRate, m.Link,
 		))
 	}
 	fmt.Fprintln(&out, strings.Repeat("-", 120))
@@ -91,15 +74,10 @@ func LogLAGMetrics(t testing.TB, otg *otg.OTG, c gosnappi.Config) {
 	fmt.Fprintf(&out,
 		"%-25s%-15s%-15s%-15s%-20s\n",
 		"Name", "Oper Status", "Frames Tx", "Frames Rx", "Member Ports UP")
-	for _, lag := range c.Lags().Items() {
-		lagMetrics := gnmi.Get(t, otg, gnmi.OTG().Lag(lag.Name()).State())
-		operStatus := lagMetrics.GetOperStatus().String()
-		memberPortsUP := lagMetrics.GetCounters().GetMemberPortsUp()
-		framesTx := lagMetrics.GetCounters().GetOutFrames()
-		framesRx := lagMetrics.GetCounters().GetInFrames()
+	for _, m := range GetLAGMetrics(t, otg, c) {
 		out.WriteString(fmt.Sprintf(
 			"%-25v%-15v%-15v%-15v%-20v\n",
-			lag.Name(), operStatus, framesTx, framesRx, memberPortsUP,
+			m.Name, m.OperStatus, m.TxFrames, m.RxFrames, m.MemberPortsUp,
 		))
 	}
 	fmt.Fprintln(&out, strings.Repeat("-", 120))
@@ -124,21 +102,11 @@ func LogLACPMetrics(t testing.TB, otg *otg.OTG, c gosnappi.Config) {
 		"System Id",
 		"Partner Id")
 
-	for _, lag := range c.Lags().Items() {
-		lagPorts := lag.Ports().Items()
-		for _, lagPort := range lagPorts {
-			lacpMetric := gnmi.Get(t, otg, gnmi.OTG().Lacp().LagMember(lagPort.PortName()).State())
-			synchronization := lacpMetric.GetSynchronization().String()
-			collecting := lacpMetric.GetCollecting()
-			distributing := lacpMetric.GetDistributing()
-			systemID := lacpMetric.GetSystemId()
-			partnerID := lacpMetric.GetPartnerId()
-			out.WriteString(fmt.Sprintf(
-				"%-10v%-15v%-18v%-15v%-15v%-20v%-20v\n",
-				lag.Name(), lagPort.PortName(), synchronization, collecting, distributing, systemID, partnerID,
-			))
-
-		}
+	for _, m := range GetLACPMetrics(t, otg, c) {
+		out.WriteString(fmt.Sprintf(
+			"%-10v%-15v%-18v%-15v%-15v%-20v%-20v\n",
+			m.LAG, m.MemberPort, m.Synchronization, m.Collecting, m.Distributing, m.SystemID, m.PartnerID,
+		))
 	}
 	fmt.Fprintln(&out, strings.Repeat("-", 120))
 	out.WriteString("\n\n")
@@ -0,0 +1,320 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otgutils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/ondatra/gnmi"
+	"github.com/openconfig/ondatra/otg"
+	"github.com/openconfig/ygnmi/ygnmi"
+
+	otgtelemetry "github.com/openconfig/ondatra/gnmi/otg"
+)
+
+// readiness is a condition of an OTG device that must hold before traffic
+// is started.  It is either polled with ready or watched with watch.
+type readiness struct {
+	// device is the name of the device, or of the LAG for LACP.
+	device string
+	// desc describes the condition, e.g. "BGP peer p1 established".
+	desc  string
+	ready func(t testing.TB) bool
+	// watch starts watching for the condition for up to timeout, and returns
+	// a function that waits for the watch and returns whether it held.
+	watch func(t testing.TB, timeout time.Duration) (await func() bool)
+}
+
+// awaitReady watches or polls the conditions until they all hold or the
+// timeout expires, and returns an error naming each device with a condition
+// that never held.
+func awaitReady(t testing.TB, conds []*readiness, timeout time.Duration) error {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	awaits := make(map[*readiness]func() bool)
+	var polled []*readiness
+	for _, c := range conds {
+		if c.watch != nil {
+			awaits[c] = c.watch(t, timeout)
+		} else {
+			polled = append(polled, c)
+		}
+	}
+	failed := make(map[*readiness]bool)
+	for {
+		var pending []*readiness
+		for _, c := range polled {
+			if !c.ready(t) {
+				pending = append(pending, c)
+			}
+		}
+		if len(pending) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			for _, c := range pending {
+				failed[c] = true
+			}
+			break
+		}
+		polled = pending
+		time.Sleep(pollInterval)
+	}
+	for c, await := range awaits {
+		if !await() {
+			failed[c] = true
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	var pending []*readiness
+	for _, c := range conds {
+		if failed[c] {
+			pending = append(pending, c)
+		}
+	}
+	return notReadyError(pending, timeout)
+}
+
+// notReadyError returns an error naming each device with pending conditions,
+// in the order of the conditions.
+func notReadyError(pending []*readiness, timeout time.Duration) error {
+	var devices []string
+	descs := make(map[string][]string)
+	for _, c := range pending {
+		if _, ok := descs[c.device]; !ok {
+			devices = append(devices, c.device)
+		}
+		descs[c.device] = append(descs[c.device], c.desc)
+	}
+	var lines []string
+	for _, d := range devices {
+		lines = append(lines, fmt.Sprintf("%s: %s", d, strings.Join(descs[d], ", ")))
+	}
+	return fmt.Errorf("%d OTG devices not ready within %v:\n  %s", len(devices), timeout, strings.Join(lines, "\n  "))
+}
+
+// arpReadiness returns that the IPv4 gateways of the devices are resolved.
+func arpReadiness(otg *otg.OTG, c gosnappi.Config) []*readiness {
+	var conds []*readiness
+	for _, d := range c.Devices().Items() {
+		for _, eth := range d.Ethernets().Items() {
+			eth := eth
+			for _, ip := range eth.Ipv4Addresses().Items() {
+				gw := ip.Gateway()
+				conds = append(conds, &readiness{
+					device: d.Name(),
+					desc:   fmt.Sprintf("ARP for %s on %s", gw, eth.Name()),
+					watch: func(t testing.TB, timeout time.Duration) func() bool {
+						w := gnmi.WatchAll(t, otg, gnmi.OTG().Interface(eth.Name()).Ipv4NeighborAny().LinkLayerAddress().State(), timeout, func(v *ygnmi.Value[string]) bool {
+							return neighborResolved(v, "ipv4-address", gw)
+						})
+						return func() bool {
+							_, ok := w.Await(t)
+							return ok
+						}
+					},
+				})
+			}
+		}
+	}
+	return conds
+}
+
+// neighborResolved returns whether the value is a non-empty link layer
+// address of the neighbor whose key named by addrKey is addr.
+func neighborResolved(v *ygnmi.Value[string], addrKey, addr string) bool {
+	mac, ok := v.Val()
+	if !ok || mac == "" {
+		return false
+	}
+	for _, e := range v.Path.GetElem() {
+		if a, ok := e.GetKey()[addrKey]; ok {
+			return a == addr
+		}
+	}
+	return false
+}
+
+// ndReadiness returns that the IPv6 gateways of the devices are resolved.
+func ndReadiness(otg *otg.OTG, c gosnappi.Config) []*readiness {
+	var conds []*readiness
+	for _, d := range c.Devices().Items() {
+		for _, eth := range d.Ethernets().Items() {
+			eth := eth
+			for _, ip := range eth.Ipv6Addresses().Items() {
+				gw := ip.Gateway()
+				conds = append(conds, &readiness{
+					device: d.Name(),
+					desc:   fmt.Sprintf("ND for %s on %s", gw, eth.Name()),
+					watch: func(t testing.TB, timeout time.Duration) func() bool {
+						w := gnmi.WatchAll(t, otg, gnmi.OTG().Interface(eth.Name()).Ipv6NeighborAny().LinkLayerAddress().State(), timeout, func(v *ygnmi.Value[string]) bool {
+							return neighborResolved(v, "ipv6-address", gw)
+						})
+						return func() bool {
+							_, ok := w.Await(t)
+							return ok
+						}
+					},
+				})
+			}
+		}
+	}
+	return conds
+}
+
+// bgpReadiness returns that the BGP peers of the devices are established.
+func bgpReadiness(otg *otg.OTG, c gosnappi.Config) []*readiness {
+	var conds []*readiness
+	for _, p := range bgpPeers(c) {
+		name := p.name
+		conds = append(conds, &readiness{
+			device: p.device,
+			desc:   fmt.Sprintf("BGP peer %s established", name),
+			ready: func(t testing.TB) bool {
+				v := gnmi.Lookup(t, otg, gnmi.OTG().BgpPeer(name).SessionState().State())
+				state, ok := v.Val()
+				return ok && state == otgtelemetry.BgpPeer_SessionState_ESTABLISHED
+			},
+		})
+	}
+	return conds
+}
+
+// isisSessions returns the number of level 1 and level 2 sessions that an
+// IS-IS router has when all of its interfaces have an adjacency.
+func isisSessions(r gosnappi.DeviceIsisRouter) (l1, l2 uint64) {
+	for _, intf := range r.Interfaces().Items() {
+		switch intf.LevelType() {
+		case gosnappi.IsisInterfaceLevelType.LEVEL_1:
+			l1++
+		case gosnappi.IsisInterfaceLevelType.LEVEL_2:
+			l2++
+		case gosnappi.IsisInterfaceLevelType.LEVEL_1_2:
+			l1++
+			l2++
+		}
+	}
+	return l1, l2
+}
+
+// isisReadiness returns that the IS-IS routers of the devices have at least
+// as many sessions up at each level as they have interfaces at that level.
+func isisReadiness(otg *otg.OTG, c gosnappi.Config) []*readiness {
+	var conds []*readiness
+	for _, d := range c.Devices().Items() {
+		if !d.HasIsis() {
+			continue
+		}
+		name := d.Isis().Name()
+		l1, l2 := isisSessions(d.Isis())
+		conds = append(conds, &readiness{
+			device: d.Name(),
+			desc:   fmt.Sprintf("IS-IS router %s with %d L1 and %d L2 adjacencies", name, l1, l2),
+			ready: func(t testing.TB) bool {
+				v := gnmi.Lookup(t, otg, gnmi.OTG().IsisRouter(name).Counters().State())
+				counters, ok := v.Val()
+				return ok && counters.GetLevel1().GetSessionsUp() >= l1 && counters.GetLevel2().GetSessionsUp() >= l2
+			},
+		})
+	}
+	return conds
+}
+
+// lacpReadiness returns that the member ports of the LAGs are in sync.
+func lacpReadiness(otg *otg.OTG, c gosnappi.Config) []*readiness {
+	var conds []*readiness
+	for _, l := range c.Lags().Items() {
+		for _, p := range l.Ports().Items() {
+			port := p.PortName()
+			conds = append(conds, &readiness{
+				device: l.Name(),
+				desc:   fmt.Sprintf("LACP member %s in sync", port),
+				ready: func(t testing.TB) bool {
+					v := gnmi.Lookup(t, otg, gnmi.OTG().Lacp().LagMember(port).Synchronization().State())
+					sync, ok := v.Val()
+					return ok && sync == otgtelemetry.Lacp_LacpSynchronizationType_IN_SYNC
+				},
+			})
+		}
+	}
+	return conds
+}
+
+// WaitForARP waits until the IPv4 gateways of all devices of the config are
+// resolved, and returns an error naming each device with an unresolved
+// gateway.
+func WaitForARP(t testing.TB, otg *otg.OTG, c gosnappi.Config, timeout time.Duration) error {
+	t.Helper()
+	return awaitReady(t, arpReadiness(otg, c), timeout)
+}
+
+// WaitForND waits until the IPv6 gateways of all devices of the config are
+// resolved, and returns an error naming each device with an unresolved
+// gateway.
+func WaitForND(t testing.TB, otg *otg.OTG, c gosnappi.Config, timeout time.Duration) error {
+	t.Helper()
+	return awaitReady(t, ndReadiness(otg, c), timeout)
+}
+
+// WaitForBGP waits until the BGPv4 and BGPv6 peers of all devices of the
+// config are established, and returns an error naming each device with a
+// peer that is not.
+func WaitForBGP(t testing.TB, otg *otg.OTG, c gosnappi.Config, timeout time.Duration) error {
+	t.Helper()
+	return awaitReady(t, bgpReadiness(otg, c), timeout)
+}
+
+// WaitForISIS waits until the IS-IS routers of all devices of the config
+// have an adjacency per interface and level, and returns an error naming each device
+// whose router does not.
+func WaitForISIS(t testing.TB, otg *otg.OTG, c gosnappi.Config, timeout time.Duration) error {
+	t.Helper()
+	return awaitReady(t, isisReadiness(otg, c), timeout)
+}
+
+// WaitForLACP waits until the member ports of all LAGs of the config are in
+// sync, and returns an error naming each LAG with a member that is not.
+func WaitForLACP(t testing.TB, otg *otg.OTG, c gosnappi.Config, timeout time.Duration) error {
+	t.Helper()
+	return awaitReady(t, lacpReadiness(otg, c), timeout)
+}
+
+// WaitForProtocols waits, within one timeout, for ARP, ND, BGP, IS-IS and
+// LACP readiness of everything that the config defines, and returns one
+// error naming each device that did not become ready.
+//
+// Usage:
+//
+//	otg.PushConfig(t, config)
+//	otg.StartProtocols(t)
+//	if err := otgutils.WaitForProtocols(t, otg, config, 2*time.Minute); err != nil {
+//	  t.Fatal(err)
+//	}
+func WaitForProtocols(t testing.TB, otg *otg.OTG, c gosnappi.Config, timeout time.Duration) error {
+	t.Helper()
+	var conds []*readiness
+	conds = append(conds, lacpReadiness(otg, c)...)
+	conds = append(conds, arpReadiness(otg, c)...)
+	conds = append(conds, ndReadiness(otg, c)...)
+	conds = append(conds, bgpReadiness(otg, c)...)
+	conds = append(conds, isisReadiness(otg, c)...)
+	return awaitReady(t, conds, timeout)
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package otgutils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/ygnmi/ygnmi"
+
+	gpb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+func TestAwaitReady(t *testing.T) {
+	ready := func(testing.TB) bool { return true }
+	notReady := func(testing.TB) bool { return false }
+	watched := func(ok bool) func(testing.TB, time.Duration) func() bool {
+		return func(testing.TB, time.Duration) func() bool {
+			return func() bool { return ok }
+		}
+	}
+
+	if err := awaitReady(t, []*readiness{{device: "d1", desc: "ARP", ready: ready}}, 0); err != nil {
+		t.Errorf("awaitReady of ready conditions got error: %v", err)
+	}
+
+	err := awaitReady(t, []*readiness{
+		{device: "d1", desc: "ARP for 192.0.2.0", watch: watched(true)},
+		{device: "d2", desc: "ARP for 192.0.2.2", watch: watched(false)},
+		{device: "d1", desc: "BGP peer p1 established", ready: ready},
+		{device: "d3", desc: "BGP peer p3 established", ready: notReady},
+		{device: "d2", desc: "BGP peer p2 established", ready: notReady},
+	}, 0)
+	if err == nil {
+		t.Fatalf("awaitReady of pending conditions got nil error")
+	}
+	want := "2 OTG devices not ready within 0s:\n" +
+		"  d2: ARP for 192.0.2.2, BGP peer p2 established\n" +
+		"  d3: BGP peer p3 established"
+	if got := err.Error(); got != want {
+		t.Errorf("awaitReady got error %q, want %q", got, want)
+	}
+	if strings.Contains(err.Error(), "d1") {
+		t.Errorf("awaitReady error names ready device d1: %v", err)
+	}
+}
+
+func TestNeighborResolved(t *testing.T) {
+	path := func(addr string) *gpb.Path {
+		return &gpb.Path{Elem: []*gpb.PathElem{
+			{Name: "interfaces"},
+			{Name: "interface", Key: map[string]string{"name": "port1.Eth"}},
+			{Name: "ipv4-neighbors"},
+			{Name: "ipv4-neighbor", Key: map[string]string{"ipv4-address": addr}},
+			{Name: "state"},
+			{Name: "link-layer-address"},
+		}}
+	}
+	tests := []struct {
+		desc string
+		v    *ygnmi.Value[string]
+		want bool
+	}{{
+		desc: "resolved",
+		v:    (&ygnmi.Value[string]{Path: path("192.0.2.1")}).SetVal("02:00:00:00:00:01"),
+		want: true,
+	}, {
+		desc: "other neighbor",
+		v:    (&ygnmi.Value[string]{Path: path("192.0.2.3")}).SetVal("02:00:00:00:00:03"),
+	}, {
+		desc: "empty address",
+		v:    (&ygnmi.Value[string]{Path: path("192.0.2.1")}).SetVal(""),
+	}, {
+		desc: "no value",
+		v:    &ygnmi.Value[string]{Path: path("192.0.2.1")},
+	}}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := neighborResolved(tc.v, "ipv4-address", "192.0.2.1"); got != tc.want {
+				t.Errorf("neighborResolved() got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestISISSessions(t *testing.T) {
+	r := gosnappi.NewConfig().Devices().Add().Isis()
+	r.Interfaces().Add().SetLevelType(gosnappi.IsisInterfaceLevelType.LEVEL_1)
+	r.Interfaces().Add().SetLevelType(gosnappi.IsisInterfaceLevelType.LEVEL_1_2)
+	r.Interfaces().Add() // LEVEL_2 by default.
+	if l1, l2 := isisSessions(r); l1 != 2 || l2 != 2 {
+		t.Errorf("isisSessions() got %d L1 and %d L2 sessions, want 2 and 2", l1, l2)
+	}
+}
@@ -246,6 +246,8 @@ func dutsInfo(ctx context.Context, m map[string]string, resv *binding.Reservatio
 			glog.Errorf("Could not create ygnmi.Client for dut %s: %v", dut.Name(), err)
 			continue
 		}
-		newDUTInfo(ctx, components.Y{Client: yc}).put(m, id)
+		y := components.Y{Client: yc}
+		newDUTInfo(ctx, y).put(m, id)
+		inventoryInfo(ctx, m, id, y)
 	}
 }
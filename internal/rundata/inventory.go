@@ -0,0 +1,178 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rundata
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/openconfig/featureprofiles/internal/components"
+	"github.com/openconfig/ondatra/binding"
+	"github.com/openconfig/ondatra/gnmi/oc"
+)
+
+var (
+	dutInventory = flag.Bool("dut_inventory", false,
+		"Record an inventory snapshot of each DUT from its components: linecards, transceivers, integrated circuits and software, as properties and as a JSON artifact in -outputs_dir.")
+	dutInventoryMaxItems = flag.Int("dut_inventory_max_items", 100,
+		"The maximum number of inventory properties recorded per DUT with -dut_inventory.")
+	dutInventoryMaxBytes = flag.Int("dut_inventory_max_bytes", 1<<20,
+		"The maximum size of the inventory artifact written per DUT with -dut_inventory; larger inventories are not written.")
+)
+
+// inventoryTypes are the types of the components recorded as inventory
+// properties.  Components of other types are recorded only if they report a
+// firmware version.
+var inventoryTypes = map[oc.Component_Type_Union]bool{
+	oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_CONTROLLER_CARD:         true,
+	oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_FABRIC:                  true,
+	oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_INTEGRATED_CIRCUIT:      true,
+	oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_LINECARD:                true,
+	oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_TRANSCEIVER:             true,
+	oc.PlatformTypes_OPENCONFIG_SOFTWARE_COMPONENT_BIOS:                    true,
+	oc.PlatformTypes_OPENCONFIG_SOFTWARE_COMPONENT_BOOT_LOADER:             true,
+	oc.PlatformTypes_OPENCONFIG_SOFTWARE_COMPONENT_OPERATING_SYSTEM:        true,
+	oc.PlatformTypes_OPENCONFIG_SOFTWARE_COMPONENT_OPERATING_SYSTEM_UPDATE: true,
+	oc.PlatformTypes_OPENCONFIG_SOFTWARE_COMPONENT_SOFTWARE_MODULE:         true,
+}
+
+// putInventory exports the inventory of the component tree to a map with
+// the given dut ID, one property per component named by the component, up
+// to maxItems properties.
+//
+//   - id.inventory.components - the number of components in the tree.
+//   - id.inventory.<name> - the type and versions of the component, e.g.
+//     "LINECARD part_no=LC-1 serial_no=S1 firmware_version=1.2".
+//   - id.inventory.truncated - true if there were more than maxItems.
+func putInventory(m map[string]string, id string, tr *components.Tree, maxItems int) {
+	m[id+".inventory.components"] = fmt.Sprint(tr.Len())
+	n := 0
+	for _, root := range tr.Roots() {
+		root.Walk(func(c *components.Node) {
+			if !inventoryTypes[c.GetType()] && c.GetFirmwareVersion() == "" {
+				return
+			}
+			if n >= maxItems {
+				m[id+".inventory.truncated"] = "true"
+				return
+			}
+			n++
+			m[id+".inventory."+c.GetName()] = inventoryValue(c)
+		})
+	}
+}
+
+// inventoryValue summarizes the type and versions of a component.
+func inventoryValue(c *components.Node) string {
+	parts := []string{c.TypeName()}
+	for _, a := range []struct{ k, v string }{
+		{"part_no", c.GetPartNo()},
+		{"serial_no", c.GetSerialNo()},
+		{"hardware_version", c.GetHardwareVersion()},
+		{"firmware_version", c.GetFirmwareVersion()},
+		{"software_version", c.GetSoftwareVersion()},
+	} {
+		if a.v != "" {
+			parts = append(parts, a.k+"="+a.v)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// writeInventory writes the inventory of the component tree as a JSON
+// artifact into dir, unless it is larger than maxBytes, and returns the
+// path of the artifact.
+func writeInventory(dir, id string, tr *components.Tree, maxBytes int) (string, error) {
+	b, err := tr.InventoryJSON()
+	if err != nil {
+		return "", err
+	}
+	if len(b) > maxBytes {
+		return "", fmt.Errorf("inventory of %d bytes exceeds the limit of %d bytes", len(b), maxBytes)
+	}
+	f, err := os.CreateTemp(dir, "inventory."+id+".*.json")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(b); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// outputsDir returns the value of the -outputs_dir flag, which is defined by
+// fptest, or empty if it is not defined.
+func outputsDir() string {
+	if f := flag.Lookup("outputs_dir"); f != nil {
+		return f.Value.String()
+	}
+	return ""
+}
+
+// inventoryInfo populates the inventory properties of a DUT and writes its
+// inventory artifact, if -dut_inventory is set.
+func inventoryInfo(ctx context.Context, m map[string]string, id string, y components.Y) {
+	if !*dutInventory {
+		return
+	}
+	tr, err := y.Tree(ctx)
+	if err != nil {
+		glog.Errorf("Could not get the components of dut %s: %v", id, err)
+		return
+	}
+	putInventory(m, id, tr, *dutInventoryMaxItems)
+	dir := outputsDir()
+	if dir == "" {
+		return
+	}
+	path, err := writeInventory(dir, id, tr, *dutInventoryMaxBytes)
+	if err != nil {
+		glog.Errorf("Could not write the inventory of dut %s: %v", id, err)
+		return
+	}
+	m[id+".inventory.artifact"] = path
+}
+
+// atesInfo populates the ATE properties for all ATEs in the reservation from
+// the binding, and the version of the OTG client library.
+func atesInfo(m map[string]string, resv *binding.Reservation) {
+	for id, ate := range resv.ATEs {
+		if v := ate.Vendor(); v != 0 {
+			m[id+".vendor"] = v.String()
+		}
+		if model := ate.HardwareModel(); model != "" {
+			m[id+".model"] = model
+		}
+		if ver := ate.SoftwareVersion(); ver != "" {
+			m[id+".os_version"] = ver
+		}
+	}
+	if len(resv.ATEs) == 0 {
+		return
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range bi.Deps {
+			if dep.Path == "github.com/open-traffic-generator/snappi/gosnappi" {
+				m["otg.gosnappi_version"] = dep.Version
+			}
+		}
+	}
+}
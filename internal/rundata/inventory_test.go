@@ -0,0 +1,151 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rundata
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/openconfig/featureprofiles/internal/components"
+	"github.com/openconfig/ondatra/binding"
+	"github.com/openconfig/ondatra/gnmi/oc"
+	"github.com/openconfig/ygot/ygot"
+
+	opb "github.com/openconfig/ondatra/proto"
+)
+
+func inventoryTree() *components.Tree {
+	return components.NewTree([]*oc.Component{{
+		Name: ygot.String("Chassis"),
+		Type: oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_CHASSIS,
+	}, {
+		Name:            ygot.String("Linecard0"),
+		Parent:          ygot.String("Chassis"),
+		Type:            oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_LINECARD,
+		PartNo:          ygot.String("LC-1"),
+		SerialNo:        ygot.String("S1"),
+		FirmwareVersion: ygot.String("1.2"),
+	}, {
+		Name:   ygot.String("Ethernet0"),
+		Parent: ygot.String("Linecard0"),
+		Type:   oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_PORT,
+	}, {
+		Name:   ygot.String("Transceiver0"),
+		Parent: ygot.String("Ethernet0"),
+		Type:   oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_TRANSCEIVER,
+		PartNo: ygot.String("QSFP-1"),
+	}, {
+		Name:            ygot.String("Fan0"),
+		Parent:          ygot.String("Chassis"),
+		Type:            oc.PlatformTypes_OPENCONFIG_HARDWARE_COMPONENT_FAN,
+		FirmwareVersion: ygot.String("0.9"),
+	}, {
+		Name:            ygot.String("OS"),
+		Type:            oc.PlatformTypes_OPENCONFIG_SOFTWARE_COMPONENT_OPERATING_SYSTEM,
+		SoftwareVersion: ygot.String("7.7.1"),
+	}})
+}
+
+func TestPutInventory(t *testing.T) {
+	cases := []struct {
+		name     string
+		maxItems int
+		want     map[string]string
+	}{{
+		name:     "all",
+		maxItems: 10,
+		want: map[string]string{
+			"dut.inventory.components":   "6",
+			"dut.inventory.Fan0":         "FAN firmware_version=0.9",
+			"dut.inventory.Linecard0":    "LINECARD part_no=LC-1 serial_no=S1 firmware_version=1.2",
+			"dut.inventory.Transceiver0": "TRANSCEIVER part_no=QSFP-1",
+			"dut.inventory.OS":           "OPERATING_SYSTEM software_version=7.7.1",
+		},
+	}, {
+		name:     "truncated",
+		maxItems: 2,
+		want: map[string]string{
+			"dut.inventory.components": "6",
+			"dut.inventory.Fan0":       "FAN firmware_version=0.9",
+			"dut.inventory.Linecard0":  "LINECARD part_no=LC-1 serial_no=S1 firmware_version=1.2",
+			"dut.inventory.truncated":  "true",
+		},
+	}}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := make(map[string]string)
+			putInventory(got, "dut", inventoryTree(), c.maxItems)
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("putInventory -want,+got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWriteInventory(t *testing.T) {
+	dir := t.TempDir()
+	tr := inventoryTree()
+
+	path, err := writeInventory(dir, "dut", tr, 1<<20)
+	if err != nil {
+		t.Fatalf("writeInventory failed: %v", err)
+	}
+	if got := filepath.Dir(path); got != dir {
+		t.Errorf("writeInventory wrote to %q, want %q", got, dir)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Cannot read inventory artifact: %v", err)
+	}
+	var items []*components.InventoryItem
+	if err := json.Unmarshal(b, &items); err != nil {
+		t.Fatalf("Cannot unmarshal inventory artifact: %v", err)
+	}
+	if diff := cmp.Diff(tr.Inventory(), items); diff != "" {
+		t.Errorf("Inventory artifact -want,+got:\n%s", diff)
+	}
+
+	if _, err := writeInventory(dir, "dut", tr, 10); err == nil {
+		t.Errorf("writeInventory over the size limit got nil error, want error")
+	}
+}
+
+func TestATEsInfo(t *testing.T) {
+	resv := &binding.Reservation{
+		ATEs: map[string]binding.ATE{
+			"ate": &binding.AbstractATE{
+				Dims: &binding.Dims{
+					Vendor:          opb.Device_IXIA,
+					HardwareModel:   "Novus",
+					SoftwareVersion: "9.20",
+				},
+			},
+		},
+	}
+	got := make(map[string]string)
+	atesInfo(got, resv)
+	for k, want := range map[string]string{
+		"ate.vendor":     "IXIA",
+		"ate.model":      "Novus",
+		"ate.os_version": "9.20",
+	} {
+		if got[k] != want {
+			t.Errorf("Property %s got %q, want %q", k, got[k], want)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rundata
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+)
+
+var runRecord = flag.String("run_record", "",
+	"Path of the JSON run record to write at the end of the test run.  If empty, the record is written into -outputs_dir, if set.  Run with -test.v to record passing and skipped tests.")
+
+// Record is the machine-readable record of a test run, which aggregates the
+// properties of the run with the outcome of each test and subtest.
+type Record struct {
+	TestPath   string                `json:"test_path,omitempty"`
+	TestPlanID string                `json:"test_plan_id,omitempty"`
+	GitCommit  string                `json:"git_commit,omitempty"`
+	DUTs       map[string]*DUTRecord `json:"duts,omitempty"`
+	// Deviations maps the names of the deviation flags that were set to
+	// their values.
+	Deviations map[string]string `json:"deviations,omitempty"`
+	Tests      []*TestOutcome    `json:"tests,omitempty"`
+	// Properties are all properties of the run.
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+// DUTRecord is the DUT information in a Record.
+type DUTRecord struct {
+	Vendor    string `json:"vendor,omitempty"`
+	Model     string `json:"model,omitempty"`
+	OSVersion string `json:"os_version,omitempty"`
+}
+
+// TestOutcome is the outcome of a test or subtest.
+type TestOutcome struct {
+	// Name is the full name of the test, e.g. "TestFoo/bar".
+	Name string `json:"name"`
+	// Outcome is PASS, FAIL or SKIP.
+	Outcome         string  `json:"outcome"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// Status returns the status of the run: FAIL if any test failed, PASS if
+// none failed and at least one passed, or UNKNOWN if no test passed or failed,
+// e.g. because no outcomes were recorded.
+func (r *Record) Status() string {
+	status := "UNKNOWN"
+	for _, t := range r.Tests {
+		switch t.Outcome {
+		case "FAIL":
+			return "FAIL"
+		case "PASS":
+			status = "PASS"
+		}
+	}
+	return status
+}
+
+// Passed returns whether no test failed and at least one passed.
+func (r *Record) Passed() bool {
+	return r.Status() == "PASS"
+}
+
+// NewRecord builds a record from the properties of a run, the IDs of its
+// DUTs and the outcomes of its tests.
+func NewRecord(props map[string]string, dutIDs []string, tests []*TestOutcome) *Record {
+	r := &Record{
+		TestPath:   props["test.path"],
+		TestPlanID: props["test.plan_id"],
+		GitCommit:  props["git.commit"],
+		Tests:      tests,
+		Properties: props,
+	}
+	for _, id := range dutIDs {
+		if r.DUTs == nil {
+			r.DUTs = make(map[string]*DUTRecord)
+		}
+		r.DUTs[id] = &DUTRecord{
+			Vendor:    props[id+".vendor"],
+			Model:     props[id+".model"],
+			OSVersion: props[id+".os_version"],
+		}
+	}
+	for k, v := range props {
+		if name := strings.TrimPrefix(k, "deviation."); name != k {
+			if r.Deviations == nil {
+				r.Deviations = make(map[string]string)
+			}
+			r.Deviations[name] = v
+		}
+	}
+	return r
+}
+
+// outcomeRE matches the line that the testing package prints at the end of
+// a test or subtest.
+var outcomeRE = regexp.MustCompile(`^\s*--- (PASS|FAIL|SKIP): (\S+) \(([0-9.]+)s\)`)
+
+// ParseTestLog returns the outcomes of the tests in the output of a test
+// binary.  Passing and skipped tests are only printed with -test.v.
+func ParseTestLog(r io.Reader) ([]*TestOutcome, error) {
+	var tests []*TestOutcome
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		if t := parseOutcome(s.Text()); t != nil {
+			tests = append(tests, t)
+		}
+	}
+	return tests, s.Err()
+}
+
+func parseOutcome(line string) *TestOutcome {
+	m := outcomeRE.FindStringSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	d, _ := strconv.ParseFloat(m[3], 64)
+	return &TestOutcome{Name: m[2], Outcome: m[1], DurationSeconds: d}
+}
+
+// ParseJUnitXML returns the outcomes of the test cases in a JUnit XML
+// report, such as the one written by Ondatra with -xml.
+func ParseJUnitXML(r io.Reader) ([]*TestOutcome, error) {
+	var report struct {
+		Suites []struct {
+			Cases []struct {
+				Name    string    `xml:"name,attr"`
+				Time    string    `xml:"time,attr"`
+				Failure *struct{} `xml:"failure"`
+				Error   *struct{} `xml:"error"`
+				Skipped *struct{} `xml:"skipped"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.NewDecoder(r).Decode(&report); err != nil {
+		return nil, err
+	}
+	var tests []*TestOutcome
+	for _, s := range report.Suites {
+		for _, c := range s.Cases {
+			t := &TestOutcome{Name: c.Name, Outcome: "PASS"}
+			t.DurationSeconds, _ = strconv.ParseFloat(c.Time, 64)
+			switch {
+			case c.Failure != nil || c.Error != nil:
+				t.Outcome = "FAIL"
+			case c.Skipped != nil:
+				t.Outcome = "SKIP"
+			}
+			tests = append(tests, t)
+		}
+	}
+	return tests, nil
+}
+
+// recorder collects the run record between StartRecord and FinishRecord.
+var recorder struct {
+	mu     sync.Mutex
+	props  map[string]string
+	dutIDs []string
+	// tee, if set, copies stdout to the original stdout while collecting
+	// the test outcomes.
+	tee *outcomeTee
+}
+
+// outcomeTee replaces os.Stdout with a pipe that it copies to the original
+// stdout, collecting the test outcomes printed along the way.
+type outcomeTee struct {
+	orig  *os.File
+	w     *os.File
+	done  chan struct{}
+	tests []*TestOutcome
+}
+
+func startTee() (*outcomeTee, error) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	t := &outcomeTee{orig: os.Stdout, w: pw, done: make(chan struct{})}
+	go func() {
+		defer close(t.done)
+		r := io.TeeReader(pr, t.orig)
+		var err error
+		if t.tests, err = ParseTestLog(r); err != nil {
+			glog.Errorf("Could not parse the test log: %v", err)
+		}
+		io.Copy(io.Discard, r) // Keep copying after a parse error.
+	}()
+	os.Stdout = pw
+	return t, nil
+}
+
+// stop restores os.Stdout and returns the test outcomes.
+func (t *outcomeTee) stop() []*TestOutcome {
+	os.Stdout = t.orig
+	t.w.Close()
+	<-t.done
+	return t.tests
+}
+
+// StartRecord starts recording the run with the given properties and DUT IDs,
+// which later calls replace.  Unless Ondatra writes a JUnit XML report, the
+// test outcomes are collected from stdout.  It is a no-op unless -run_record
+// or -outputs_dir is set.
+func StartRecord(props map[string]string, dutIDs []string) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if recordPath() == "" && outputsDir() == "" {
+		return
+	}
+	recorder.props = props
+	recorder.dutIDs = dutIDs
+	if recorder.tee != nil || junitXMLPath() != "" {
+		return
+	}
+	tee, err := startTee()
+	if err != nil {
+		glog.Errorf("Could not collect the test outcomes: %v", err)
+		return
+	}
+	recorder.tee = tee
+}
+
+// FinishRecord writes the run record, with the given additional properties,
+// e.g. from Timing, and returns its path.  It returns an empty path if
+// StartRecord did not start recording.
+func FinishRecord(extra map[string]string) (string, error) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if recorder.props == nil {
+		return "", nil
+	}
+	props := make(map[string]string)
+	for _, m := range []map[string]string{recorder.props, extra} {
+		for k, v := range m {
+			props[k] = v
+		}
+	}
+	recorder.props = nil
+
+	var tests []*TestOutcome
+	if recorder.tee != nil {
+		tests = recorder.tee.stop()
+		recorder.tee = nil
+	} else if path := junitXMLPath(); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		if tests, err = ParseJUnitXML(f); err != nil {
+			return "", fmt.Errorf("could not parse JUnit XML %s: %w", path, err)
+		}
+	}
+	return writeRecord(NewRecord(props, recorder.dutIDs, tests))
+}
+
+func writeRecord(r *Record) (string, error) {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if path := recordPath(); path != "" {
+		return path, os.WriteFile(path, b, 0o644)
+	}
+	f, err := os.CreateTemp(outputsDir(), "run_record.*.json")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	_, err = f.Write(b)
+	return f.Name(), err
+}
+
+func recordPath() string {
+	return *runRecord
+}
+
+// junitXMLPath returns the value of the Ondatra -xml flag, or empty if it is
+// not defined.
+func junitXMLPath() string {
+	if f := flag.Lookup("xml"); f != nil {
+		return f.Value.String()
+	}
+	return ""
+}
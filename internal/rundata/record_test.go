@@ -0,0 +1,164 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rundata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseTestLog(t *testing.T) {
+	const log = `=== RUN   TestFoo
+=== RUN   TestFoo/bar
+    foo_test.go:10: some log
+--- FAIL: TestFoo (1.50s)
+    --- FAIL: TestFoo/bar (1.00s)
+    --- SKIP: TestFoo/baz (0.00s)
+--- PASS: TestQux (0.25s)
+FAIL
+`
+	got, err := ParseTestLog(strings.NewReader(log))
+	if err != nil {
+		t.Fatalf("ParseTestLog failed: %v", err)
+	}
+	want := []*TestOutcome{
+		{Name: "TestFoo", Outcome: "FAIL", DurationSeconds: 1.5},
+		{Name: "TestFoo/bar", Outcome: "FAIL", DurationSeconds: 1},
+		{Name: "TestFoo/baz", Outcome: "SKIP"},
+		{Name: "TestQux", Outcome: "PASS", DurationSeconds: 0.25},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseTestLog -want,+got:\n%s", diff)
+	}
+}
+
+func TestParseJUnitXML(t *testing.T) {
+	const report = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+	<testsuite name="example" tests="3">
+		<properties><property name="test.plan_id" value="RT-1.1"></property></properties>
+		<testcase name="TestFoo" classname="example" time="1.500"><failure message="Failed"></failure></testcase>
+		<testcase name="TestFoo/baz" classname="example" time="0.000"><skipped message="Skipped"></skipped></testcase>
+		<testcase name="TestQux" classname="example" time="0.250"></testcase>
+	</testsuite>
+</testsuites>
+`
+	got, err := ParseJUnitXML(strings.NewReader(report))
+	if err != nil {
+		t.Fatalf("ParseJUnitXML failed: %v", err)
+	}
+	want := []*TestOutcome{
+		{Name: "TestFoo", Outcome: "FAIL", DurationSeconds: 1.5},
+		{Name: "TestFoo/baz", Outcome: "SKIP"},
+		{Name: "TestQux", Outcome: "PASS", DurationSeconds: 0.25},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseJUnitXML -want,+got:\n%s", diff)
+	}
+}
+
+func TestNewRecord(t *testing.T) {
+	props := map[string]string{
+		"test.path":               "feature/foo/tests/foo_test",
+		"test.plan_id":            "RT-1.1",
+		"git.commit":              "abc123",
+		"dut.vendor":              "ARISTA",
+		"dut.model":               "DCS-7280CR3K-32D4",
+		"dut.os_version":          "4.29.0F",
+		"ate.vendor":              "IXIA",
+		"deviation.interface_mtu": "true",
+	}
+	tests := []*TestOutcome{{Name: "TestFoo", Outcome: "PASS"}}
+	got := NewRecord(props, []string{"dut"}, tests)
+	want := &Record{
+		TestPath:   "feature/foo/tests/foo_test",
+		TestPlanID: "RT-1.1",
+		GitCommit:  "abc123",
+		DUTs: map[string]*DUTRecord{
+			"dut": {Vendor: "ARISTA", Model: "DCS-7280CR3K-32D4", OSVersion: "4.29.0F"},
+		},
+		Deviations: map[string]string{"interface_mtu": "true"},
+		Tests:      tests,
+		Properties: props,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("NewRecord -want,+got:\n%s", diff)
+	}
+	if !got.Passed() {
+		t.Errorf("Passed got false, want true")
+	}
+}
+
+func TestRecordStatus(t *testing.T) {
+	tests := []struct {
+		desc     string
+		outcomes []string
+		want     string
+	}{
+		{desc: "no outcomes", want: "UNKNOWN"},
+		{desc: "skipped", outcomes: []string{"SKIP"}, want: "UNKNOWN"},
+		{desc: "passed", outcomes: []string{"PASS", "SKIP"}, want: "PASS"},
+		{desc: "failed", outcomes: []string{"PASS", "FAIL"}, want: "FAIL"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := &Record{}
+			for _, o := range tc.outcomes {
+				r.Tests = append(r.Tests, &TestOutcome{Name: "TestFoo", Outcome: o})
+			}
+			if got := r.Status(); got != tc.want {
+				t.Errorf("Status got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRecordTee(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "record.json")
+	*runRecord = path
+	defer func() { *runRecord = "" }()
+
+	StartRecord(map[string]string{"test.plan_id": "RT-1.1"}, nil)
+	fmt.Println("--- PASS: TestFoo (0.10s)")
+	got, err := FinishRecord(map[string]string{"time.end": "1"})
+	if err != nil {
+		t.Fatalf("FinishRecord failed: %v", err)
+	}
+	if got != path {
+		t.Errorf("FinishRecord got path %q, want %q", got, path)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Cannot read run record: %v", err)
+	}
+	var r Record
+	if err := json.Unmarshal(b, &r); err != nil {
+		t.Fatalf("Cannot unmarshal run record: %v", err)
+	}
+	want := Record{
+		TestPlanID: "RT-1.1",
+		Tests:      []*TestOutcome{{Name: "TestFoo", Outcome: "PASS", DurationSeconds: 0.1}},
+		Properties: map[string]string{"test.plan_id": "RT-1.1", "time.end": "1"},
+	}
+	if diff := cmp.Diff(want, r); diff != "" {
+		t.Errorf("Run record -want,+got:\n%s", diff)
+	}
+}
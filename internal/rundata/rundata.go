@@ -47,6 +47,17 @@
 //   - dut.vendor - the vendor of the DUT.
 //   - dut.model - the vendor model name of the DUT.
 //   - dut.os_version - the OS version running on the DUT.
+//   - dut.inventory.* - with -dut_inventory, a size-bounded inventory of the
+//     linecards, transceivers, integrated circuits and software components of
+//     the DUT, and the path of its JSON artifact in -outputs_dir.
+//   - ate.vendor, ate.model, ate.os_version - the ATE details reported by the
+//     binding.
+//   - otg.gosnappi_version - the version of the OTG client library, if the
+//     testbed has an ATE.
+//
+// With -run_record or -outputs_dir, the binding also writes a JSON Record of
+// the run at the end of the test run, with the outcome of each test.  The
+// tools/compliance_matrix command aggregates these records.
 package rundata
 
 import (
@@ -106,6 +117,7 @@ func Properties(ctx context.Context, resv *binding.Reservation) map[string]strin
 	if resv != nil {
 		m["topology"] = topology(resv)
 		dutsInfo(ctx, m, resv)
+		atesInfo(m, resv)
 	}
 
 	return m
@@ -0,0 +1,111 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"testing"
+
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/ixnet"
+)
+
+// ConfigATE renders the topology to an ATETopology and ATE flows.  The
+// topology is not pushed.
+func (top *Topology) ConfigATE(t testing.TB, ate *ondatra.ATEDevice) (*ondatra.ATETopology, []*ondatra.Flow) {
+	t.Helper()
+	flows, err := top.resolve()
+	if err != nil {
+		t.Fatalf("Invalid traffic topology: %v", err)
+	}
+
+	at := ate.Topology().New()
+	eps := make(map[string]ondatra.Endpoint)
+	for _, d := range top.Devices {
+		i := d.Attrs.AddToATE(at, d.Port, d.Peer)
+		eps[d.Attrs.Name] = i
+		if d.BGP != nil {
+			for _, p := range d.BGP.Peers {
+				peer := i.BGP().AddPeer().WithPeerAddress(p.PeerAddress).WithLocalASN(p.AS)
+				if p.Internal {
+					peer.WithTypeInternal()
+				} else {
+					peer.WithTypeExternal()
+				}
+				ipv6 := peerFamily(p)
+				nh, _ := (&endpoint{dev: d}).ipName(ipv6)
+				for _, r := range p.Routes {
+					n := addATENetwork(i, r)
+					n.BGP().WithNextHopAddress(nh)
+					eps[r.Name] = n
+				}
+			}
+		}
+		if d.ISIS != nil {
+			isis := i.ISIS().WithAreaID(d.ISIS.AreaAddress).WithWideMetricEnabled(true)
+			if d.ISIS.Level == 1 {
+				isis.WithLevelL1()
+			} else {
+				isis.WithLevelL2()
+			}
+			if d.ISIS.PointToPoint {
+				isis.WithNetworkTypePointToPoint()
+			} else {
+				isis.WithNetworkTypeBroadcast()
+			}
+			if d.ISIS.Metric != 0 {
+				isis.WithMetric(d.ISIS.Metric)
+			}
+			for _, r := range d.ISIS.Routes {
+				n := addATENetwork(i, r)
+				n.ISIS()
+				eps[r.Name] = n
+			}
+		}
+	}
+
+	var atf []*ondatra.Flow
+	for _, f := range flows {
+		srcAddr, _ := f.src.ipName(f.ipv6)
+		start, step, count := f.dst.dstRange(f.ipv6)
+		var ip ondatra.Header
+		if f.ipv6 {
+			h := ondatra.NewIPv6Header().WithSrcAddress(srcAddr).WithDSCP(f.DSCP)
+			h.DstAddressRange().WithMin(start).WithStep(step).WithCount(count)
+			ip = h
+		} else {
+			h := ondatra.NewIPv4Header().WithSrcAddress(srcAddr).WithDSCP(f.DSCP)
+			h.DstAddressRange().WithMin(start).WithStep(step).WithCount(count)
+			ip = h
+		}
+		atf = append(atf, ate.Traffic().NewFlow(f.Name).
+			WithSrcEndpoints(eps[f.Src]).
+			WithDstEndpoints(eps[f.Dst]).
+			WithHeaders(ondatra.NewEthernetHeader(), ip).
+			WithFrameSize(f.frameSize()).
+			WithFrameRateFPS(f.ratePPS()))
+	}
+	return at, atf
+}
+
+// addATENetwork adds a route range as a network behind the interface.
+func addATENetwork(i *ondatra.Interface, r *Routes) *ixnet.Network {
+	n := i.AddNetwork(r.Name)
+	if r.isIPv6() {
+		n.IPv6().WithAddress(r.Prefix).WithCount(r.count())
+	} else {
+		n.IPv4().WithAddress(r.Prefix).WithCount(r.count())
+	}
+	return n
+}
@@ -0,0 +1,171 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"testing"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+	"github.com/openconfig/ondatra"
+	"github.com/openconfig/ondatra/gnmi"
+)
+
+// Generator runs a topology on an ATE with the selected backend.
+type Generator struct {
+	Backend Backend
+	ate     *ondatra.ATEDevice
+
+	// Only one of these is populated, depending on the backend.
+	ateTop   *ondatra.ATETopology
+	ateFlows []*ondatra.Flow
+	otgCfg   gosnappi.Config
+}
+
+// New renders the topology for the backend selected by the -traffic_backend
+// flag.
+func New(t testing.TB, ate *ondatra.ATEDevice, top *Topology) *Generator {
+	t.Helper()
+	b, err := DefaultBackend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewWithBackend(t, ate, top, b)
+}
+
+// NewWithBackend renders the topology for the given backend.
+func NewWithBackend(t testing.TB, ate *ondatra.ATEDevice, top *Topology, b Backend) *Generator {
+	t.Helper()
+	g := &Generator{Backend: b, ate: ate}
+	switch b {
+	case ATE:
+		g.ateTop, g.ateFlows = top.ConfigATE(t, ate)
+	case OTG:
+		g.otgCfg = ate.OTG().NewConfig(t)
+		top.ConfigOTG(t, g.otgCfg)
+	default:
+		t.Fatalf("Unknown traffic backend %q", b)
+	}
+	return g
+}
+
+// ATETopology returns the rendered ATETopology, or nil for OTG.  Tests may
+// use it for settings that this package does not cover.
+func (g *Generator) ATETopology() *ondatra.ATETopology {
+	return g.ateTop
+}
+
+// OTGConfig returns the rendered OTG configuration, or nil for ATE.  Tests
+// may use it for settings that this package does not cover.
+func (g *Generator) OTGConfig() gosnappi.Config {
+	return g.otgCfg
+}
+
+// Push pushes the configuration to the ATE and starts the protocols.
+func (g *Generator) Push(t testing.TB) {
+	t.Helper()
+	if g.Backend == ATE {
+		g.ateTop.Push(t).StartProtocols(t)
+		return
+	}
+	otg := g.ate.OTG()
+	otg.PushConfig(t, g.otgCfg)
+	otg.StartProtocols(t)
+}
+
+// StopProtocols stops the protocols on the ATE.
+func (g *Generator) StopProtocols(t testing.TB) {
+	t.Helper()
+	if g.Backend == ATE {
+		g.ateTop.StopProtocols(t)
+		return
+	}
+	g.ate.OTG().StopProtocols(t)
+}
+
+// StartTraffic starts all flows.
+func (g *Generator) StartTraffic(t testing.TB) {
+	t.Helper()
+	if g.Backend == ATE {
+		g.ate.Traffic().Start(t, g.ateFlows...)
+		return
+	}
+	g.ate.OTG().StartTraffic(t)
+}
+
+// StopTraffic stops all flows.
+func (g *Generator) StopTraffic(t testing.TB) {
+	t.Helper()
+	if g.Backend == ATE {
+		g.ate.Traffic().Stop(t)
+		return
+	}
+	g.ate.OTG().StopTraffic(t)
+}
+
+// Packets returns the number of packets transmitted and received by a flow.
+func (g *Generator) Packets(t testing.TB, flow string) (tx, rx uint64) {
+	t.Helper()
+	if g.Backend == ATE {
+		counters := gnmi.Get(t, g.ate, gnmi.OC().Flow(flow).Counters().State())
+		return counters.GetOutPkts(), counters.GetInPkts()
+	}
+	otg := g.ate.OTG()
+	counters := gnmi.Get(t, otg, gnmi.OTG().Flow(flow).Counters().State())
+	return counters.GetOutPkts(), counters.GetInPkts()
+}
+
+// Octets returns the number of octets transmitted and received by a flow.
+func (g *Generator) Octets(t testing.TB, flow string) (tx, rx uint64) {
+	t.Helper()
+	if g.Backend == ATE {
+		counters := gnmi.Get(t, g.ate, gnmi.OC().Flow(flow).Counters().State())
+		return counters.GetOutOctets(), counters.GetInOctets()
+	}
+	otg := g.ate.OTG()
+	counters := gnmi.Get(t, otg, gnmi.OTG().Flow(flow).Counters().State())
+	return counters.GetOutOctets(), counters.GetInOctets()
+}
+
+// FrameRates returns the current rates in frames per second at which a flow
+// is transmitted and received.  They are 0 once the traffic is stopped.
+func (g *Generator) FrameRates(t testing.TB, flow string) (tx, rx float32) {
+	t.Helper()
+	if g.Backend == ATE {
+		return gnmi.Get(t, g.ate, gnmi.OC().Flow(flow).OutFrameRate().State()),
+			gnmi.Get(t, g.ate, gnmi.OC().Flow(flow).InFrameRate().State())
+	}
+	otg := g.ate.OTG()
+	return gnmi.Get(t, otg, gnmi.OTG().Flow(flow).OutFrameRate().State()),
+		gnmi.Get(t, otg, gnmi.OTG().Flow(flow).InFrameRate().State())
+}
+
+// LossPct returns the percentage of packets of a flow that were lost.  It is
+// 0 if no packets were transmitted.
+func (g *Generator) LossPct(t testing.TB, flow string) float32 {
+	t.Helper()
+	if g.Backend == ATE {
+		return gnmi.Get(t, g.ate, gnmi.OC().Flow(flow).LossPct().State())
+	}
+	tx, rx := g.Packets(t, flow)
+	return lossPct(tx, rx)
+}
+
+// lossPct computes the loss percentage from the packet counts.
+func lossPct(tx, rx uint64) float32 {
+	if tx == 0 || rx >= tx {
+		return 0
+	}
+	return float32(tx-rx) * 100 / float32(tx)
+}
@@ -0,0 +1,178 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/open-traffic-generator/snappi/gosnappi"
+)
+
+// ConfigOTG renders the topology into an OTG configuration, which would
+// typically be empty and obtained from otg.NewConfig.  The configuration is
+// not pushed.
+func (top *Topology) ConfigOTG(t testing.TB, cfg gosnappi.Config) {
+	t.Helper()
+	flows, err := top.resolve()
+	if err != nil {
+		t.Fatalf("Invalid traffic topology: %v", err)
+	}
+
+	for _, d := range top.Devices {
+		d.Attrs.AddToOTG(cfg, d.Port, d.Peer)
+		var dev gosnappi.Device
+		for _, item := range cfg.Devices().Items() {
+			if item.Name() == d.Attrs.Name {
+				dev = item
+			}
+		}
+		if d.BGP != nil {
+			addOTGBGP(dev, d)
+		}
+		if d.ISIS != nil {
+			addOTGISIS(dev, d)
+		}
+	}
+
+	for _, f := range flows {
+		srcAddr, srcName := f.src.ipName(f.ipv6)
+		dstName := f.Dst
+		if f.dst.routes == nil {
+			_, dstName = f.dst.ipName(f.ipv6)
+		}
+		start, step, count := f.dst.dstRange(f.ipv6)
+
+		flow := cfg.Flows().Add().SetName(f.Name)
+		flow.Metrics().SetEnable(true)
+		flow.TxRx().Device().
+			SetTxNames([]string{srcName}).
+			SetRxNames([]string{dstName})
+		flow.Size().SetFixed(int32(f.frameSize()))
+		flow.Rate().SetPps(int64(f.ratePPS()))
+		flow.Duration().SetChoice("continuous")
+		flow.Packet().Add().Ethernet().Src().SetValue(f.src.dev.Attrs.MAC)
+		if f.ipv6 {
+			v6 := flow.Packet().Add().Ipv6()
+			v6.Src().SetValue(srcAddr)
+			v6.Dst().Increment().SetStart(start).SetStep(step).SetCount(int32(count))
+			v6.TrafficClass().SetValue(int32(f.DSCP) << 2)
+		} else {
+			v4 := flow.Packet().Add().Ipv4()
+			v4.Src().SetValue(srcAddr)
+			v4.Dst().Increment().SetStart(start).SetStep(step).SetCount(int32(count))
+			v4.Priority().Dscp().Phb().SetValue(int32(f.DSCP))
+		}
+	}
+}
+
+// addOTGBGP adds the BGP peers and routes of the device.
+func addOTGBGP(dev gosnappi.Device, d *Device) {
+	routerID := d.BGP.RouterID
+	if routerID == "" {
+		routerID = d.Attrs.IPv4
+	}
+	bgp := dev.Bgp().SetRouterId(routerID)
+	var v4 gosnappi.BgpV4Interface
+	var v6 gosnappi.BgpV6Interface
+	for _, p := range d.BGP.Peers {
+		if peerFamily(p) {
+			if v6 == nil {
+				v6 = bgp.Ipv6Interfaces().Add().SetIpv6Name(d.Attrs.Name + ".IPv6")
+			}
+			peer := v6.Peers().Add().SetName(p.Name).
+				SetPeerAddress(p.PeerAddress).
+				SetAsNumber(int32(p.AS)).
+				SetAsType(gosnappi.BgpV6PeerAsType.EBGP)
+			if p.Internal {
+				peer.SetAsType(gosnappi.BgpV6PeerAsType.IBGP)
+			}
+			for _, r := range p.Routes {
+				pfx := netip.MustParsePrefix(r.Prefix).Masked()
+				rr := peer.V6Routes().Add().SetName(r.Name).
+					SetNextHopIpv6Address(d.Attrs.IPv6).
+					SetNextHopAddressType(gosnappi.BgpV6RouteRangeNextHopAddressType.IPV6).
+					SetNextHopMode(gosnappi.BgpV6RouteRangeNextHopMode.MANUAL)
+				rr.Addresses().Add().
+					SetAddress(pfx.Addr().String()).
+					SetPrefix(int32(pfx.Bits())).
+					SetCount(int32(r.count()))
+			}
+			continue
+		}
+		if v4 == nil {
+			v4 = bgp.Ipv4Interfaces().Add().SetIpv4Name(d.Attrs.Name + ".IPv4")
+		}
+		peer := v4.Peers().Add().SetName(p.Name).
+			SetPeerAddress(p.PeerAddress).
+			SetAsNumber(int32(p.AS)).
+			SetAsType(gosnappi.BgpV4PeerAsType.EBGP)
+		if p.Internal {
+			peer.SetAsType(gosnappi.BgpV4PeerAsType.IBGP)
+		}
+		for _, r := range p.Routes {
+			pfx := netip.MustParsePrefix(r.Prefix).Masked()
+			rr := peer.V4Routes().Add().SetName(r.Name).
+				SetNextHopIpv4Address(d.Attrs.IPv4).
+				SetNextHopAddressType(gosnappi.BgpV4RouteRangeNextHopAddressType.IPV4).
+				SetNextHopMode(gosnappi.BgpV4RouteRangeNextHopMode.MANUAL)
+			rr.Addresses().Add().
+				SetAddress(pfx.Addr().String()).
+				SetPrefix(int32(pfx.Bits())).
+				SetCount(int32(r.count()))
+		}
+	}
+}
+
+// addOTGISIS adds the IS-IS router and routes of the device.
+func addOTGISIS(dev gosnappi.Device, d *Device) {
+	name := d.Attrs.Name + ".ISIS"
+	systemID, _ := d.isisSystemID() // Checked by resolve.
+	isis := dev.Isis().SetName(name).SetSystemId(systemID)
+	isis.Basic().SetHostname(name)
+	isis.Advanced().SetAreaAddresses([]string{strings.ReplaceAll(d.ISIS.AreaAddress, ".", "")})
+
+	intf := isis.Interfaces().Add().
+		SetEthName(d.Attrs.Name + ".Eth").
+		SetName(name + ".Intf").
+		SetNetworkType(gosnappi.IsisInterfaceNetworkType.BROADCAST).
+		SetLevelType(gosnappi.IsisInterfaceLevelType.LEVEL_2)
+	if d.ISIS.PointToPoint {
+		intf.SetNetworkType(gosnappi.IsisInterfaceNetworkType.POINT_TO_POINT)
+	}
+	if d.ISIS.Level == 1 {
+		intf.SetLevelType(gosnappi.IsisInterfaceLevelType.LEVEL_1)
+	}
+	if d.ISIS.Metric != 0 {
+		intf.SetMetric(int32(d.ISIS.Metric))
+	}
+	intf.Advanced().SetAutoAdjustMtu(true).SetAutoAdjustArea(true).SetAutoAdjustSupportedProtocols(true)
+
+	for _, r := range d.ISIS.Routes {
+		pfx := netip.MustParsePrefix(r.Prefix).Masked()
+		if r.isIPv6() {
+			isis.V6Routes().Add().SetName(r.Name).Addresses().Add().
+				SetAddress(pfx.Addr().String()).
+				SetPrefix(int32(pfx.Bits())).
+				SetCount(int32(r.count()))
+		} else {
+			isis.V4Routes().Add().SetName(r.Name).Addresses().Add().
+				SetAddress(pfx.Addr().String()).
+				SetPrefix(int32(pfx.Bits())).
+				SetCount(int32(r.count()))
+		}
+	}
+}
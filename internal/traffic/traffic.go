@@ -0,0 +1,352 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package traffic defines ATE devices, protocols, flows and their metrics once,
+// independently of the traffic generator API, and renders them either to an
+// ondatra.ATETopology or to a gosnappi.Config.  This lets a single test source
+// serve both the ATE and the OTG backends instead of being written twice under
+// ate_tests/ and otg_tests/.
+//
+// Usage:
+//
+//	top := &traffic.Topology{
+//	  Devices: []*traffic.Device{{
+//	    Port: ate.Port(t, "port1"), Attrs: &ateSrc, Peer: &dutSrc,
+//	  }, {
+//	    Port: ate.Port(t, "port2"), Attrs: &ateDst, Peer: &dutDst,
+//	    BGP: &traffic.BGP{Peers: []*traffic.BGPPeer{{
+//	      Name: "ateDst.BGP4", PeerAddress: dutDst.IPv4, AS: ateAS,
+//	      Routes: []*traffic.Routes{{Name: "ateDst.RR4", Prefix: "198.51.100.0/24", Count: 10}},
+//	    }}},
+//	  }},
+//	  Flows: []*traffic.Flow{{Name: "v4", Src: ateSrc.Name, Dst: "ateDst.RR4", FrameSize: 512, RatePPS: 100}},
+//	}
+//	g := traffic.New(t, ate, top)
+//	g.Push(t)
+//	g.StartTraffic(t)
+//	time.Sleep(15 * time.Second)
+//	g.StopTraffic(t)
+//	if loss := g.LossPct(t, "v4"); loss > 1 {
+//	  t.Errorf(...)
+//	}
+//
+// The backend is selected with the -traffic_backend flag, which must match
+// the kind of ATE that the binding provides.
+package traffic
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+	"github.com/openconfig/ondatra"
+)
+
+// Backend is the traffic generator API used to configure the ATE.
+type Backend string
+
+const (
+	// ATE is the ondatra ATETopology and Traffic API.
+	ATE Backend = "ate"
+	// OTG is the Open Traffic Generator API.
+	OTG Backend = "otg"
+)
+
+var backend = flag.String("traffic_backend", string(OTG),
+	"Traffic generator API of the ATE in the binding, either \"ate\" or \"otg\".")
+
+// DefaultBackend returns the backend selected by the -traffic_backend flag.
+func DefaultBackend() (Backend, error) {
+	switch b := Backend(*backend); b {
+	case ATE, OTG:
+		return b, nil
+	default:
+		return "", fmt.Errorf("unknown traffic backend %q", *backend)
+	}
+}
+
+// Topology is a traffic generator configuration: emulated devices with
+// their protocols, and the flows between them.
+type Topology struct {
+	Devices []*Device
+	Flows   []*Flow
+}
+
+// Device is an emulated device connected to an ATE port.  Each device must
+// be on a different port.
+type Device struct {
+	Port *ondatra.Port
+	// Attrs are the attributes of the ATE interface.  Attrs.Name is the
+	// device name which flows refer to.
+	Attrs *attrs.Attributes
+	// Peer are the attributes of the DUT interface, used as the gateway.
+	Peer *attrs.Attributes
+	BGP  *BGP
+	ISIS *ISIS
+}
+
+// BGP is the BGP configuration of a device.
+type BGP struct {
+	// RouterID defaults to the IPv4 address of the device.
+	RouterID string
+	Peers    []*BGPPeer
+}
+
+// BGPPeer is a BGP session from the device to the DUT.
+type BGPPeer struct {
+	// Name identifies the peer in the OTG telemetry.
+	Name string
+	// PeerAddress is the DUT address.  The session is established over the
+	// device address of the same family.
+	PeerAddress string
+	// AS is the local AS number of the device.
+	AS uint32
+	// Internal makes this an IBGP session; the default is EBGP.
+	Internal bool
+	// Routes are advertised to the peer with the device address of the
+	// same family as next hop.
+	Routes []*Routes
+}
+
+// ISIS is the IS-IS configuration of a device.
+type ISIS struct {
+	// SystemID is the 6-byte system ID in hex, e.g. "640000000001" or
+	// "6400.0000.0001".  Empty means the MAC address of the device.  It is
+	// only used by OTG, since the ATE API cannot set it; the ATE derives its
+	// own.
+	SystemID string
+	// AreaAddress is the area in dotted notation, e.g. "49.0002".
+	AreaAddress string
+	// Level is 1 or 2.  Zero means level 2.
+	Level int
+	// PointToPoint selects a point-to-point circuit instead of broadcast.
+	PointToPoint bool
+	// Metric is the interface metric.  Zero means the backend default.
+	Metric uint32
+	// Routes are advertised as IP reachability.
+	Routes []*Routes
+}
+
+// Routes is a range of consecutive prefixes advertised by a protocol.  Flows
+// may use the name of a route range as their destination.
+type Routes struct {
+	Name string
+	// Prefix is the first prefix in CIDR notation, e.g. "198.51.100.0/24".
+	Prefix string
+	// Count is the number of prefixes.  Zero means 1.
+	Count uint32
+}
+
+// Flow is a traffic flow between two endpoints.  The address family of the
+// flow is given by the destination: the family of the route range, or IPv6
+// when IPv6 is set and the destination is a device.
+type Flow struct {
+	Name string
+	// Src is the name of the source device.
+	Src string
+	// Dst is the name of a destination device or route range.
+	Dst  string
+	IPv6 bool
+	// FrameSize is the fixed frame size in bytes.  Zero means 512.
+	FrameSize uint32
+	// RatePPS is the frame rate in packets per second.  Zero means 100.
+	RatePPS uint64
+	DSCP    uint8
+}
+
+const (
+	defaultFrameSize = 512
+	defaultRatePPS   = 100
+)
+
+// endpoint is a resolved flow endpoint.
+type endpoint struct {
+	dev    *Device
+	routes *Routes // nil if the endpoint is the device itself.
+}
+
+// ipName returns the address of the device in the given family and the name
+// of the corresponding IP endpoint in OTG, as created by attrs.AddToOTG.
+func (ep *endpoint) ipName(ipv6 bool) (string, string) {
+	a := ep.dev.Attrs
+	if ipv6 {
+		return a.IPv6, a.Name + ".IPv6"
+	}
+	return a.IPv4, a.Name + ".IPv4"
+}
+
+// dstRange returns the first destination address, the increment between
+// addresses and the number of addresses that a flow to this endpoint cycles
+// through, so that every prefix of a route range receives traffic.
+func (ep *endpoint) dstRange(ipv6 bool) (start, step string, count uint32) {
+	if ep.routes == nil {
+		addr, _ := ep.ipName(ipv6)
+		if ipv6 {
+			return addr, "::1", 1
+		}
+		return addr, "0.0.0.1", 1
+	}
+	pfx := netip.MustParsePrefix(ep.routes.Prefix).Masked()
+	b := make([]byte, pfx.Addr().BitLen()/8)
+	if bits := pfx.Bits(); bits > 0 {
+		i := (bits - 1) / 8
+		b[i] = 1 << (7 - (bits-1)%8)
+	}
+	stepAddr, _ := netip.AddrFromSlice(b)
+	return pfx.Addr().String(), stepAddr.String(), ep.routes.count()
+}
+
+func (r *Routes) count() uint32 {
+	if r.Count == 0 {
+		return 1
+	}
+	return r.Count
+}
+
+// isIPv6 reports whether the routes are IPv6 prefixes.
+func (r *Routes) isIPv6() bool {
+	pfx, err := netip.ParsePrefix(r.Prefix)
+	return err == nil && pfx.Addr().Is6()
+}
+
+func (f *Flow) frameSize() uint32 {
+	if f.FrameSize == 0 {
+		return defaultFrameSize
+	}
+	return f.FrameSize
+}
+
+func (f *Flow) ratePPS() uint64 {
+	if f.RatePPS == 0 {
+		return defaultRatePPS
+	}
+	return f.RatePPS
+}
+
+// resolved is a flow with its endpoints looked up.
+type resolved struct {
+	*Flow
+	src, dst *endpoint
+	ipv6     bool
+}
+
+// resolve checks the topology and looks up the endpoints of every flow.
+func (top *Topology) resolve() ([]*resolved, error) {
+	eps := make(map[string]*endpoint)
+	ports := make(map[*ondatra.Port]string)
+	add := func(name string, ep *endpoint) error {
+		if name == "" {
+			return fmt.Errorf("device %s has a protocol or route range without a name", ep.dev.Attrs.Name)
+		}
+		if _, ok := eps[name]; ok {
+			return fmt.Errorf("duplicate name %s", name)
+		}
+		eps[name] = ep
+		return nil
+	}
+	for _, d := range top.Devices {
+		if d.Attrs == nil || d.Attrs.Name == "" {
+			return nil, fmt.Errorf("device is missing a name")
+		}
+		if d.Peer == nil {
+			return nil, fmt.Errorf("device %s is missing the DUT peer attributes", d.Attrs.Name)
+		}
+		if other, ok := ports[d.Port]; ok && d.Port != nil {
+			return nil, fmt.Errorf("devices %s and %s are on the same port", other, d.Attrs.Name)
+		}
+		ports[d.Port] = d.Attrs.Name
+		if err := add(d.Attrs.Name, &endpoint{dev: d}); err != nil {
+			return nil, err
+		}
+		var routes []*Routes
+		if d.BGP != nil {
+			for _, p := range d.BGP.Peers {
+				if _, err := netip.ParseAddr(p.PeerAddress); err != nil {
+					return nil, fmt.Errorf("BGP peer %s: %w", p.Name, err)
+				}
+				if err := add(p.Name, &endpoint{dev: d}); err != nil {
+					return nil, err
+				}
+				routes = append(routes, p.Routes...)
+			}
+		}
+		if d.ISIS != nil {
+			if _, err := d.isisSystemID(); err != nil {
+				return nil, err
+			}
+			routes = append(routes, d.ISIS.Routes...)
+		}
+		for _, r := range routes {
+			if _, err := netip.ParsePrefix(r.Prefix); err != nil {
+				return nil, fmt.Errorf("routes %s: %w", r.Name, err)
+			}
+			if err := add(r.Name, &endpoint{dev: d, routes: r}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var flows []*resolved
+	names := make(map[string]bool)
+	for _, f := range top.Flows {
+		if names[f.Name] {
+			return nil, fmt.Errorf("duplicate flow %s", f.Name)
+		}
+		names[f.Name] = true
+		src, ok := eps[f.Src]
+		if !ok || src.routes != nil {
+			return nil, fmt.Errorf("flow %s: unknown source device %q", f.Name, f.Src)
+		}
+		dst, ok := eps[f.Dst]
+		if !ok {
+			return nil, fmt.Errorf("flow %s: unknown destination %q", f.Name, f.Dst)
+		}
+		r := &resolved{Flow: f, src: src, dst: dst, ipv6: f.IPv6}
+		if dst.routes != nil {
+			r.ipv6 = dst.routes.isIPv6()
+		}
+		if srcAddr, _ := src.ipName(r.ipv6); srcAddr == "" {
+			return nil, fmt.Errorf("flow %s: source device %s has no address of the flow family", f.Name, f.Src)
+		}
+		flows = append(flows, r)
+	}
+	return flows, nil
+}
+
+// isisSystemID returns the IS-IS system ID of the device as 12 hex digits,
+// which defaults to its MAC address.
+func (d *Device) isisSystemID() (string, error) {
+	id := strings.ReplaceAll(d.ISIS.SystemID, ".", "")
+	if id == "" {
+		mac, err := net.ParseMAC(d.Attrs.MAC)
+		if err != nil || len(mac) != 6 {
+			return "", fmt.Errorf("device %s needs an IS-IS SystemID or a MAC address", d.Attrs.Name)
+		}
+		return hex.EncodeToString(mac), nil
+	}
+	if b, err := hex.DecodeString(id); err != nil || len(b) != 6 {
+		return "", fmt.Errorf("device %s has an invalid IS-IS SystemID %q, want 6 bytes in hex", d.Attrs.Name, d.ISIS.SystemID)
+	}
+	return strings.ToLower(id), nil
+}
+
+// peerFamily reports whether a BGP peer address is IPv6.
+func peerFamily(p *BGPPeer) (ipv6 bool) {
+	addr, err := netip.ParseAddr(p.PeerAddress)
+	return err == nil && addr.Is6()
+}
@@ -0,0 +1,177 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package traffic
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openconfig/featureprofiles/internal/attrs"
+)
+
+var (
+	ateSrc = &attrs.Attributes{Name: "ateSrc", IPv4: "192.0.2.2", IPv4Len: 30}
+	dutSrc = &attrs.Attributes{IPv4: "192.0.2.1", IPv4Len: 30}
+	ateDst = &attrs.Attributes{Name: "ateDst", MAC: "02:00:02:01:01:01", IPv4: "192.0.2.6", IPv4Len: 30, IPv6: "2001:db8::6", IPv6Len: 126}
+	dutDst = &attrs.Attributes{IPv4: "192.0.2.5", IPv4Len: 30, IPv6: "2001:db8::5", IPv6Len: 126}
+)
+
+func newTopology() *Topology {
+	return &Topology{
+		Devices: []*Device{{
+			Attrs: ateSrc, Peer: dutSrc,
+		}, {
+			Attrs: ateDst, Peer: dutDst,
+			BGP: &BGP{Peers: []*BGPPeer{{
+				Name: "ateDst.BGP4", PeerAddress: dutDst.IPv4, AS: 65001,
+				Routes: []*Routes{{Name: "ateDst.RR4", Prefix: "198.51.100.0/24", Count: 4}},
+			}, {
+				Name: "ateDst.BGP6", PeerAddress: dutDst.IPv6, AS: 65001,
+				Routes: []*Routes{{Name: "ateDst.RR6", Prefix: "2001:db8:1::/48", Count: 2}},
+			}}},
+			ISIS: &ISIS{Routes: []*Routes{{Name: "ateDst.ISIS4", Prefix: "203.0.113.1/32"}}},
+		}},
+		Flows: []*Flow{
+			{Name: "toRoutes", Src: "ateSrc", Dst: "ateDst.RR4"},
+			{Name: "toDevice", Src: "ateSrc", Dst: "ateDst"},
+		},
+	}
+}
+
+func TestResolve(t *testing.T) {
+	flows, err := newTopology().resolve()
+	if err != nil {
+		t.Fatalf("resolve got error: %v", err)
+	}
+	type dst struct {
+		start, step string
+		count       uint32
+	}
+	want := []dst{
+		{"198.51.100.0", "0.0.1.0", 4},
+		{"192.0.2.6", "0.0.0.1", 1},
+	}
+	for i, f := range flows {
+		var got dst
+		got.start, got.step, got.count = f.dst.dstRange(f.ipv6)
+		if got != want[i] {
+			t.Errorf("Flow %s dstRange got %v, want %v", f.Name, got, want[i])
+		}
+	}
+}
+
+func TestResolveIPv6Routes(t *testing.T) {
+	top := newTopology()
+	top.Flows = []*Flow{{Name: "v6", Src: "ateDst", Dst: "ateDst.RR6"}}
+	flows, err := top.resolve()
+	if err != nil {
+		t.Fatalf("resolve got error: %v", err)
+	}
+	if !flows[0].ipv6 {
+		t.Fatalf("Flow to IPv6 routes is not IPv6")
+	}
+	start, step, count := flows[0].dst.dstRange(true)
+	if start != "2001:db8:1::" || step != "0:0:1::" || count != 2 {
+		t.Errorf("dstRange got %s, %s, %d, want 2001:db8:1::, 0:0:1::, 2", start, step, count)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	cases := []struct {
+		desc    string
+		modify  func(*Topology)
+		wantErr string
+	}{{
+		desc:    "duplicate route name",
+		modify:  func(top *Topology) { top.Devices[1].ISIS.Routes[0].Name = "ateDst.RR4" },
+		wantErr: "duplicate name",
+	}, {
+		desc:    "duplicate flow",
+		modify:  func(top *Topology) { top.Flows[1].Name = "toRoutes" },
+		wantErr: "duplicate flow",
+	}, {
+		desc:    "unknown destination",
+		modify:  func(top *Topology) { top.Flows[0].Dst = "nowhere" },
+		wantErr: "unknown destination",
+	}, {
+		desc:    "routes as source",
+		modify:  func(top *Topology) { top.Flows[0].Src = "ateDst.RR4" },
+		wantErr: "unknown source",
+	}, {
+		desc:    "missing source family",
+		modify:  func(top *Topology) { top.Flows[0].Dst = "ateDst.RR6" },
+		wantErr: "no address of the flow family",
+	}, {
+		desc:    "bad peer address",
+		modify:  func(top *Topology) { top.Devices[1].BGP.Peers[0].PeerAddress = "dut" },
+		wantErr: "BGP peer ateDst.BGP4",
+	}, {
+		desc:    "bad IS-IS system ID",
+		modify:  func(top *Topology) { top.Devices[1].ISIS.SystemID = "6400.0000" },
+		wantErr: "invalid IS-IS SystemID",
+	}, {
+		desc:    "missing IS-IS system ID",
+		modify:  func(top *Topology) { top.Devices[0].ISIS = &ISIS{} },
+		wantErr: "needs an IS-IS SystemID",
+	}, {
+		desc:    "missing peer",
+		modify:  func(top *Topology) { top.Devices[0].Peer = nil },
+		wantErr: "missing the DUT peer",
+	}}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			top := newTopology()
+			c.modify(top)
+			_, err := top.resolve()
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("resolve got error %v, want %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestISISSystemID(t *testing.T) {
+	for _, c := range []struct {
+		systemID, want string
+	}{
+		{"", "020002010101"},
+		{"6400.0000.0001", "640000000001"},
+		{"6400000000AB", "6400000000ab"},
+	} {
+		d := &Device{Attrs: ateDst, ISIS: &ISIS{SystemID: c.systemID}}
+		got, err := d.isisSystemID()
+		if err != nil {
+			t.Errorf("isisSystemID(%q) got error: %v", c.systemID, err)
+		} else if got != c.want {
+			t.Errorf("isisSystemID(%q) got %q, want %q", c.systemID, got, c.want)
+		}
+	}
+}
+
+func TestLossPct(t *testing.T) {
+	for _, c := range []struct {
+		tx, rx uint64
+		want   float32
+	}{
+		{0, 0, 0},
+		{100, 100, 0},
+		{100, 75, 25},
+		{100, 120, 0},
+	} {
+		if got := lossPct(c.tx, c.rx); got != c.want {
+			t.Errorf("lossPct(%d, %d) got %v, want %v", c.tx, c.rx, got, c.want)
+		}
+	}
+}
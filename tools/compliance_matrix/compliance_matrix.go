@@ -0,0 +1,336 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// compliance_matrix aggregates the JSON run records written by the tests with
+// -run_record or -outputs_dir into a matrix of test plan IDs by DUT vendor and
+// OS version.  Each cell shows how many runs passed and which deviations they
+// used.  Runs without a passing or failing test, e.g. without recorded
+// outcomes, are counted as unknown rather than as passed or failed.
+//
+// Usage:
+//
+//	go run ./tools/compliance_matrix -format=markdown -out=matrix.md records/*.json
+//
+// Directories given as arguments are searched for run_record.*.json files.
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	log "github.com/golang/glog"
+	"github.com/openconfig/featureprofiles/internal/rundata"
+)
+
+var (
+	formatFlag = flag.String("format", "markdown", "output format: markdown, html or csv")
+	outFlag    = flag.String("out", "", "output file, or stdout if empty")
+	dutFlag    = flag.String("dut", "dut", "ID of the DUT whose vendor and OS version are the column of a run; the first DUT is used if a run has no DUT with this ID")
+)
+
+// cell aggregates the runs of a test plan on a vendor and OS version.
+type cell struct {
+	// runs counts the runs that passed or failed, and unknown those that
+	// did neither.
+	runs, passed, unknown int
+	// deviations are the names of the deviations used by any run.
+	deviations map[string]bool
+}
+
+func (c *cell) status() string {
+	switch {
+	case c.runs == 0:
+		return "UNKNOWN"
+	case c.passed == c.runs:
+		return "PASS"
+	case c.passed == 0:
+		return "FAIL"
+	}
+	return "FLAKY"
+}
+
+func (c *cell) deviationNames() []string {
+	var names []string
+	for name := range c.deviations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *cell) String() string {
+	s := fmt.Sprintf("%s %d/%d", c.status(), c.passed, c.runs)
+	if c.unknown > 0 {
+		s += fmt.Sprintf(" (%d unknown)", c.unknown)
+	}
+	if names := c.deviationNames(); len(names) > 0 {
+		s += " (deviations: " + strings.Join(names, ", ") + ")"
+	}
+	return s
+}
+
+// matrix is the compliance matrix of test plans by vendor and OS version.
+type matrix struct {
+	plans   []string
+	vendors []string
+	cells   map[string]map[string]*cell // plan -> vendor -> cell
+}
+
+// vendor returns the vendor and OS version of the DUT of the run, e.g.
+// "ARISTA 4.29.0F", so that the runs of different releases are not merged.
+func vendor(r *rundata.Record, dutID string) string {
+	d, ok := r.DUTs[dutID]
+	if !ok {
+		var ids []string
+		for id := range r.DUTs {
+			ids = append(ids, id)
+		}
+		if len(ids) == 0 {
+			return "UNKNOWN"
+		}
+		sort.Strings(ids)
+		d = r.DUTs[ids[0]]
+	}
+	v := d.Vendor
+	if v == "" {
+		v = "UNKNOWN"
+	}
+	if d.OSVersion != "" {
+		v += " " + d.OSVersion
+	}
+	return v
+}
+
+func newMatrix(records []*rundata.Record, dutID string) *matrix {
+	m := &matrix{cells: make(map[string]map[string]*cell)}
+	vendors := make(map[string]bool)
+	for _, r := range records {
+		plan := r.TestPlanID
+		if plan == "" {
+			plan = r.TestPath
+		}
+		v := vendor(r, dutID)
+		vendors[v] = true
+		if m.cells[plan] == nil {
+			m.cells[plan] = make(map[string]*cell)
+			m.plans = append(m.plans, plan)
+		}
+		c := m.cells[plan][v]
+		if c == nil {
+			c = &cell{deviations: make(map[string]bool)}
+			m.cells[plan][v] = c
+		}
+		switch r.Status() {
+		case "PASS":
+			c.runs++
+			c.passed++
+		case "FAIL":
+			c.runs++
+		default:
+			c.unknown++
+		}
+		for name := range r.Deviations {
+			c.deviations[name] = true
+		}
+	}
+	sort.Strings(m.plans)
+	for v := range vendors {
+		m.vendors = append(m.vendors, v)
+	}
+	sort.Strings(m.vendors)
+	return m
+}
+
+// rows returns the text of the matrix as rows of cells, with a header row.
+func (m *matrix) rows() [][]string {
+	rows := [][]string{append([]string{"Test Plan ID"}, m.vendors...)}
+	for _, plan := range m.plans {
+		row := []string{plan}
+		for _, v := range m.vendors {
+			if c := m.cells[plan][v]; c != nil {
+				row = append(row, c.String())
+			} else {
+				row = append(row, "")
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (m *matrix) writeMarkdown(w io.Writer) error {
+	rows := m.rows()
+	for i, row := range rows {
+		for j := range row {
+			row[j] = strings.ReplaceAll(row[j], "|", `\|`)
+		}
+		if _, err := fmt.Fprintf(w, "| %s |\n", strings.Join(row, " | ")); err != nil {
+			return err
+		}
+		if i == 0 {
+			sep := strings.Repeat("--- | ", len(row))
+			if _, err := fmt.Fprintf(w, "| %s\n", strings.TrimSuffix(sep, " ")); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *matrix) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.WriteAll(m.rows())
+	return cw.Error()
+}
+
+var htmlTmpl = template.Must(template.New("matrix").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Compliance Matrix</title>
+<style>
+table { border-collapse: collapse; }
+th, td { border: 1px solid #999; padding: 4px 8px; }
+.PASS { background: #cfc; } .FAIL { background: #fcc; } .FLAKY { background: #ffc; } .UNKNOWN { background: #ddd; }
+</style>
+</head>
+<body>
+<table>
+<tr><th>Test Plan ID</th>{{range .Vendors}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr><th>{{.Plan}}</th>{{range .Cells}}{{if .}}<td class="{{.Status}}">{{.Status}} {{.Passed}}/{{.Runs}}{{if .Unknown}} ({{.Unknown}} unknown){{end}}{{if .Deviations}}<br><small>{{range .Deviations}}{{.}}<br>{{end}}</small>{{end}}</td>{{else}}<td></td>{{end}}{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type htmlCell struct {
+	Status                string
+	Passed, Runs, Unknown int
+	Deviations            []string
+}
+
+type htmlRow struct {
+	Plan  string
+	Cells []*htmlCell
+}
+
+func (m *matrix) writeHTML(w io.Writer) error {
+	data := struct {
+		Vendors []string
+		Rows    []htmlRow
+	}{Vendors: m.vendors}
+	for _, plan := range m.plans {
+		row := htmlRow{Plan: plan}
+		for _, v := range m.vendors {
+			var hc *htmlCell
+			if c := m.cells[plan][v]; c != nil {
+				hc = &htmlCell{Status: c.status(), Passed: c.passed, Runs: c.runs, Unknown: c.unknown, Deviations: c.deviationNames()}
+			}
+			row.Cells = append(row.Cells, hc)
+		}
+		data.Rows = append(data.Rows, row)
+	}
+	return htmlTmpl.Execute(w, data)
+}
+
+// recordFiles expands the arguments into run record files.
+func recordFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasPrefix(d.Name(), "run_record.") && strings.HasSuffix(d.Name(), ".json") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func readRecord(path string) (*rundata.Record, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &rundata.Record{}
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+func main() {
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Exit("Specify run record files or directories as arguments.")
+	}
+	files, err := recordFiles(flag.Args())
+	if err != nil {
+		log.Exit(err)
+	}
+	var records []*rundata.Record
+	for _, f := range files {
+		r, err := readRecord(f)
+		if err != nil {
+			log.Exit(err)
+		}
+		records = append(records, r)
+	}
+	log.Infof("Read %d run records", len(records))
+	m := newMatrix(records, *dutFlag)
+
+	w := os.Stdout
+	if *outFlag != "" {
+		f, err := os.Create(*outFlag)
+		if err != nil {
+			log.Exit(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	switch *formatFlag {
+	case "markdown":
+		err = m.writeMarkdown(w)
+	case "html":
+		err = m.writeHTML(w)
+	case "csv":
+		err = m.writeCSV(w)
+	default:
+		err = fmt.Errorf("unknown format %q", *formatFlag)
+	}
+	if err != nil {
+		log.Exit(err)
+	}
+}
@@ -0,0 +1,378 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// list_tests inventories the tests of the Feature Profiles with their test
+// plan IDs from the README.md headers and from rundata.TestPlanID in the test
+// code, and outputs the inventory in CSV or JSON.
+//
+// It also checks that the tree is consistent, and exits non-zero if it is
+// not:
+//
+//   - every test directory has a README.md with a "# ID: Title" header,
+//   - every README.md with a header has test code,
+//   - no two tests share an ID, except for the ATE and OTG variants of the
+//     same test,
+//   - the ATE and OTG variants of a test have the same ID,
+//   - rundata.TestPlanID, if set, matches the README.md ID.
+//
+// Known inconsistencies of the tree are listed in the allowlist file, one
+// per line as the check reports them, so that new ones are caught.  Lines of
+// the allowlist that no longer match an inconsistency are reported too, so
+// that it shrinks as the tree is fixed.  Symbolic links are followed.
+//
+// Usage, from the root of the repo:
+//
+//	go run ./tools/list_tests -format=csv > tests.csv
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/golang/glog"
+)
+
+var (
+	rootFlag   = flag.String("root", ".", "root directory of the featureprofiles repo")
+	formatFlag = flag.String("format", "csv", "output format: csv or json")
+	checkFlag  = flag.Bool("check", true, "exit non-zero if the tree is inconsistent")
+	allowFlag  = flag.String("allowlist", "tools/list_tests/allowlist.txt", "file of known inconsistencies to ignore, relative to the root unless absolute; empty for none")
+)
+
+// searchDirs are the directories under the root that contain tests.
+var searchDirs = []string{"feature", "testing/feature"}
+
+// test is a test directory or a README.md of a test.
+type test struct {
+	Feature string `json:"feature"`
+	ID      string `json:"id,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Path    string `json:"path"`
+	// Kind is ate, otg or tests, by the directory that contains the test.
+	Kind      string `json:"kind"`
+	HasReadme bool   `json:"has_readme"`
+	HasCode   bool   `json:"has_code"`
+	// PlanIDs are the values of rundata.TestPlanID set by the test code.
+	PlanIDs []string `json:"plan_ids,omitempty"`
+}
+
+var (
+	// headerRE matches the header of a test README.md, e.g.
+	// "# RT-1.1: Base BGP Session Parameters".
+	headerRE = regexp.MustCompile(`^#\s*([A-Za-z0-9]+-[0-9]+(?:\.[0-9]+)*)\s*:\s*(.*?)\s*$`)
+	// planIDRE matches the assignment of rundata.TestPlanID.
+	planIDRE = regexp.MustCompile(`TestPlanID\s*=\s*"([^"]*)"`)
+)
+
+// parseReadme returns the ID and title from the first header of a README.md,
+// or empty strings if its first header is not a test header.
+func parseReadme(r io.Reader) (id, title string, err error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		if m := headerRE.FindStringSubmatch(line); m != nil {
+			return m[1], m[2], nil
+		}
+		return "", "", nil
+	}
+	return "", "", s.Err()
+}
+
+// kindAndFeature returns the kind and the feature of a test path.
+func kindAndFeature(path string) (kind, feature string) {
+	feature = path
+	for _, k := range []string{"ate", "otg", ""} {
+		dir := "/" + k + "_tests/"
+		if k == "" {
+			dir = "/tests/"
+			k = "tests"
+		}
+		if i := strings.Index(path, dir); i >= 0 {
+			kind, feature = k, path[:i]
+			break
+		}
+	}
+	feature = strings.TrimPrefix(feature, "testing/")
+	feature = strings.TrimPrefix(feature, "feature/")
+	return kind, feature
+}
+
+// pairKey returns a key that is the same for the ATE and OTG variants of a
+// test.
+func pairKey(path string) string {
+	path = strings.Replace(path, "/ate_tests/", "/*_tests/", 1)
+	return strings.Replace(path, "/otg_tests/", "/*_tests/", 1)
+}
+
+// scan inventories the tests under the search directories of the root.
+func scan(root string) ([]*test, error) {
+	tests := make(map[string]*test)
+	get := func(dir string) *test {
+		if t, ok := tests[dir]; ok {
+			return t
+		}
+		kind, feature := kindAndFeature(dir)
+		t := &test{Path: dir, Kind: kind, Feature: feature}
+		tests[dir] = t
+		return t
+	}
+	visit := func(path string) error {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		dir := filepath.ToSlash(filepath.Dir(rel))
+		switch name := filepath.Base(path); {
+		case name == "README.md":
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			id, title, err := parseReadme(f)
+			if err != nil {
+				return fmt.Errorf("%s: %w", rel, err)
+			}
+			if id == "" && !strings.Contains(dir, "tests/") {
+				return nil // Not a test README.md, e.g. of a feature.
+			}
+			t := get(dir)
+			t.HasReadme, t.ID, t.Title = true, id, title
+		case strings.HasSuffix(name, "_test.go"):
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			t := get(dir)
+			t.HasCode = true
+			for _, m := range planIDRE.FindAllSubmatch(b, -1) {
+				t.PlanIDs = append(t.PlanIDs, string(m[1]))
+			}
+		}
+		return nil
+	}
+	visited := make(map[string]bool)
+	for _, sd := range searchDirs {
+		start := filepath.Join(root, sd)
+		if _, err := os.Stat(start); os.IsNotExist(err) {
+			continue
+		}
+		if err := walkFiles(start, visited, visit); err != nil {
+			return nil, err
+		}
+	}
+	var list []*test
+	for _, t := range tests {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list, nil
+}
+
+// walkFiles calls visit for every file under dir, following symbolic links
+// like "find -L".  Directories in visited, by their resolved path, are
+// skipped, so that symbolic link loops terminate.
+func walkFiles(dir string, visited map[string]bool, visit func(path string) error) error {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	if visited[resolved] {
+		return nil
+	}
+	visited[resolved] = true
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		info, err := os.Stat(path) // Follows symbolic links.
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			err = walkFiles(path, visited, visit)
+		} else {
+			err = visit(path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check returns the inconsistencies of the inventory.
+func check(tests []*test) []string {
+	var errs []string
+	byID := make(map[string]map[string][]string) // ID -> pair key -> paths
+	byPair := make(map[string][]*test)
+	for _, t := range tests {
+		switch {
+		case !t.HasReadme:
+			errs = append(errs, fmt.Sprintf("%s: test without README.md", t.Path))
+		case !t.HasCode:
+			errs = append(errs, fmt.Sprintf("%s: README.md without test code", t.Path))
+		}
+		if t.HasReadme && t.ID == "" {
+			errs = append(errs, fmt.Sprintf("%s: README.md without a \"# ID: Title\" header", t.Path))
+		}
+		for _, id := range t.PlanIDs {
+			if t.ID != "" && id != t.ID {
+				errs = append(errs, fmt.Sprintf("%s: rundata.TestPlanID %q does not match README.md ID %q", t.Path, id, t.ID))
+			}
+		}
+		if t.ID == "" {
+			continue
+		}
+		key := pairKey(t.Path)
+		if byID[t.ID] == nil {
+			byID[t.ID] = make(map[string][]string)
+		}
+		byID[t.ID][key] = append(byID[t.ID][key], t.Path)
+		if t.Kind == "ate" || t.Kind == "otg" {
+			byPair[key] = append(byPair[key], t)
+		}
+	}
+	for id, keys := range byID {
+		if len(keys) < 2 {
+			continue
+		}
+		var paths []string
+		for _, p := range keys {
+			paths = append(paths, p...)
+		}
+		sort.Strings(paths)
+		errs = append(errs, fmt.Sprintf("duplicate ID %s: %s", id, strings.Join(paths, ", ")))
+	}
+	for _, pair := range byPair {
+		if len(pair) == 2 && pair[0].ID != pair[1].ID {
+			errs = append(errs, fmt.Sprintf("mismatched ATE and OTG IDs: %s is %s, %s is %s", pair[0].Path, pair[0].ID, pair[1].Path, pair[1].ID))
+		}
+	}
+	sort.Strings(errs)
+	return errs
+}
+
+// readAllowlist returns the inconsistencies listed in an allowlist, one per
+// line, ignoring blank lines and comments starting with "#".
+func readAllowlist(r io.Reader) (map[string]bool, error) {
+	allowed := make(map[string]bool)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		allowed[line] = true
+	}
+	return allowed, s.Err()
+}
+
+// filterAllowed returns the inconsistencies that are not allowed, followed by
+// the allowed ones that were not found.
+func filterAllowed(errs []string, allowed map[string]bool) []string {
+	var out []string
+	found := make(map[string]bool)
+	for _, e := range errs {
+		if allowed[e] {
+			found[e] = true
+			continue
+		}
+		out = append(out, e)
+	}
+	var stale []string
+	for a := range allowed {
+		if !found[a] {
+			stale = append(stale, fmt.Sprintf("allowlisted inconsistency not found: %s", a))
+		}
+	}
+	sort.Strings(stale)
+	return append(out, stale...)
+}
+
+func writeCSV(w io.Writer, tests []*test) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"Feature", "ID", "Desc", "Test Path", "Kind", "Has README", "Has Code", "TestPlanID"})
+	for _, t := range tests {
+		cw.Write([]string{
+			t.Feature, t.ID, t.Title, t.Path, t.Kind,
+			fmt.Sprint(t.HasReadme), fmt.Sprint(t.HasCode), strings.Join(t.PlanIDs, " "),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeJSON(w io.Writer, tests []*test) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(tests)
+}
+
+func main() {
+	flag.Parse()
+	tests, err := scan(*rootFlag)
+	if err != nil {
+		log.Exit(err)
+	}
+	switch *formatFlag {
+	case "csv":
+		err = writeCSV(os.Stdout, tests)
+	case "json":
+		err = writeJSON(os.Stdout, tests)
+	default:
+		err = fmt.Errorf("unknown format %q", *formatFlag)
+	}
+	if err != nil {
+		log.Exit(err)
+	}
+	if !*checkFlag {
+		return
+	}
+	allowed := make(map[string]bool)
+	if path := *allowFlag; path != "" {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(*rootFlag, path)
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			log.Exit(err)
+		}
+		allowed, err = readAllowlist(f)
+		f.Close()
+		if err != nil {
+			log.Exitf("%s: %v", path, err)
+		}
+	}
+	if errs := filterAllowed(check(tests), allowed); len(errs) > 0 {
+		for _, e := range errs {
+			fmt.Fprintln(os.Stderr, e)
+		}
+		log.Exitf("Found %d inconsistencies in %d tests.", len(errs), len(tests))
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2022 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseReadme(t *testing.T) {
+	tests := []struct {
+		desc      string
+		text      string
+		wantID    string
+		wantTitle string
+	}{{
+		desc:      "header",
+		text:      "# RT-1.1: Base BGP Session Parameters\n\n## Summary\n",
+		wantID:    "RT-1.1",
+		wantTitle: "Base BGP Session Parameters",
+	}, {
+		desc:      "text before the header",
+		text:      "<!-- comment -->\n#gNOI-3.3 : Supervisor Switchover  \n",
+		wantID:    "gNOI-3.3",
+		wantTitle: "Supervisor Switchover",
+	}, {
+		desc: "first header is not a test header",
+		text: "# BGP\n\n# RT-1.1: Base BGP Session Parameters\n",
+	}, {
+		desc: "no header",
+		text: "Some text.\n",
+	}}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			id, title, err := parseReadme(strings.NewReader(tc.text))
+			if err != nil {
+				t.Fatalf("parseReadme got error: %v", err)
+			}
+			if id != tc.wantID || title != tc.wantTitle {
+				t.Errorf("parseReadme got %q, %q, want %q, %q", id, title, tc.wantID, tc.wantTitle)
+			}
+		})
+	}
+}
+
+func TestKindAndFeature(t *testing.T) {
+	tests := []struct {
+		path        string
+		wantKind    string
+		wantFeature string
+	}{
+		{"feature/bgp/ate_tests/bgp_test", "ate", "bgp"},
+		{"feature/bgp/otg_tests/bgp_test", "otg", "bgp"},
+		{"feature/system/tests", "", "system/tests"},
+		{"feature/system/ntp/tests/ntp_test", "tests", "system/ntp"},
+		{"testing/feature/gnmi/tests/gnmi_test", "tests", "gnmi"},
+		{"feature/experimental/qos/ate_tests/qos_test", "ate", "experimental/qos"},
+	}
+	for _, tc := range tests {
+		kind, feature := kindAndFeature(tc.path)
+		if kind != tc.wantKind || feature != tc.wantFeature {
+			t.Errorf("kindAndFeature(%q) got %q, %q, want %q, %q", tc.path, kind, feature, tc.wantKind, tc.wantFeature)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		desc  string
+		tests []*test
+		want  []string
+	}{{
+		desc: "consistent",
+		tests: []*test{
+			{Path: "feature/a/ate_tests/a_test", Kind: "ate", ID: "A-1.1", HasReadme: true, HasCode: true, PlanIDs: []string{"A-1.1"}},
+			{Path: "feature/a/otg_tests/a_test", Kind: "otg", ID: "A-1.1", HasReadme: true, HasCode: true},
+		},
+	}, {
+		desc: "missing README.md and code",
+		tests: []*test{
+			{Path: "feature/a/tests/a_test", Kind: "tests", HasCode: true},
+			{Path: "feature/b/tests/b_test", Kind: "tests", ID: "B-1.1", HasReadme: true},
+		},
+		want: []string{
+			"feature/a/tests/a_test: test without README.md",
+			"feature/b/tests/b_test: README.md without test code",
+		},
+	}, {
+		desc: "README.md without header",
+		tests: []*test{
+			{Path: "feature/a/tests/a_test", Kind: "tests", HasReadme: true, HasCode: true},
+		},
+		want: []string{`feature/a/tests/a_test: README.md without a "# ID: Title" header`},
+	}, {
+		desc: "duplicate ID",
+		tests: []*test{
+			{Path: "feature/a/tests/a_test", Kind: "tests", ID: "A-1.1", HasReadme: true, HasCode: true},
+			{Path: "feature/b/tests/b_test", Kind: "tests", ID: "A-1.1", HasReadme: true, HasCode: true},
+		},
+		want: []string{"duplicate ID A-1.1: feature/a/tests/a_test, feature/b/tests/b_test"},
+	}, {
+		desc: "mismatched ATE and OTG IDs",
+		tests: []*test{
+			{Path: "feature/a/ate_tests/a_test", Kind: "ate", ID: "A-1.1", HasReadme: true, HasCode: true},
+			{Path: "feature/a/otg_tests/a_test", Kind: "otg", ID: "A-1.2", HasReadme: true, HasCode: true},
+		},
+		want: []string{"mismatched ATE and OTG IDs: feature/a/ate_tests/a_test is A-1.1, feature/a/otg_tests/a_test is A-1.2"},
+	}, {
+		desc: "mismatched TestPlanID",
+		tests: []*test{
+			{Path: "feature/a/tests/a_test", Kind: "tests", ID: "A-1.1", HasReadme: true, HasCode: true, PlanIDs: []string{"A-1.2"}},
+		},
+		want: []string{`feature/a/tests/a_test: rundata.TestPlanID "A-1.2" does not match README.md ID "A-1.1"`},
+	}}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, check(tc.tests)); diff != "" {
+				t.Errorf("check -want,+got:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFilterAllowed(t *testing.T) {
+	allowed, err := readAllowlist(strings.NewReader("# Known issues.\n\nerr a\nerr c\n"))
+	if err != nil {
+		t.Fatalf("readAllowlist got error: %v", err)
+	}
+	got := filterAllowed([]string{"err a", "err b"}, allowed)
+	want := []string{"err b", "allowlisted inconsistency not found: err c"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("filterAllowed -want,+got:\n%s", diff)
+	}
+}
+
+func TestScanFollowsSymlinks(t *testing.T) {
+	root := t.TempDir()
+	write := func(path, text string) {
+		t.Helper()
+		path = filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("shared/a_test/README.md", "# A-1.1: A\n")
+	write("shared/a_test/a_test.go", "package a_test\n")
+	if err := os.MkdirAll(filepath.Join(root, "feature/a/tests"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "shared/a_test"), filepath.Join(root, "feature/a/tests/a_test")); err != nil {
+		t.Skipf("Cannot create symbolic link: %v", err)
+	}
+	// A loop must not be followed forever.
+	if err := os.Symlink(filepath.Join(root, "feature"), filepath.Join(root, "feature/a/loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests, err := scan(root)
+	if err != nil {
+		t.Fatalf("scan got error: %v", err)
+	}
+	want := []*test{{
+		Feature: "a", ID: "A-1.1", Title: "A", Path: "feature/a/tests/a_test", Kind: "tests", HasReadme: true, HasCode: true,
+	}}
+	if diff := cmp.Diff(want, tests); diff != "" {
+		t.Errorf("scan -want,+got:\n%s", diff)
+	}
+}
@@ -18,9 +18,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
+	log "github.com/golang/glog"
 	"github.com/open-traffic-generator/snappi/gosnappi"
 	"github.com/openconfig/ondatra"
 	"github.com/openconfig/ondatra/binding"
@@ -88,6 +90,11 @@ func (b *staticBind) Release(ctx context.Context) error {
 	for k, v := range m {
 		ondatra.Report().AddSuiteProperty(k, v)
 	}
+	if path, err := rundata.FinishRecord(m); err != nil {
+		log.Errorf("Could not write the run record: %v", err)
+	} else if path != "" {
+		log.Infof("Run record written: %s", path)
+	}
 	if b.resv == nil {
 		return errors.New("no reservation")
 	}
@@ -113,6 +120,12 @@ func (b *staticBind) afterReserve(ctx context.Context) error {
 	for k, v := range m {
 		ondatra.Report().AddSuiteProperty(k, v)
 	}
+	var dutIDs []string
+	for id := range b.resv.DUTs {
+		dutIDs = append(dutIDs, id)
+	}
+	sort.Strings(dutIDs)
+	rundata.StartRecord(m, dutIDs)
 
 	if !b.pushConfig {
 		return nil